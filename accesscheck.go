@@ -2,6 +2,8 @@ package winacl
 
 import (
 	"fmt"
+	"math/bits"
+	"sort"
 )
 
 // AccessCheckResult represents the result of an access check operation
@@ -11,6 +13,10 @@ type AccessCheckResult struct {
 	Ace     *ACE           // The ACE that determined the result, if any
 	Access  uint32         // Access mask that was granted
 	Details []CheckDetails // Detailed reasoning about the check
+
+	// Rights records how each access right was decided, ordered by bit.
+	// Only set when MAXIMUM_ALLOWED was requested
+	Rights []AccessRightDecision
 }
 
 // CheckDetails provides detailed information about a step in the access check
@@ -25,6 +31,44 @@ type TokenUser struct {
 	UserSID SID
 	Groups  []SID
 	Flags   uint32 // Flags that control how groups are used
+
+	// GroupAttributes holds the attributes of Groups, keyed by SID.
+	// Groups without an entry have DefaultGroupAttributes
+	GroupAttributes map[SIDKey]GroupAttributes
+
+	// RestrictedSIDs holds the restricting SIDs of a restricted token.
+	// When WriteRestricted is set, they only restrict write access
+	RestrictedSIDs  []SID
+	WriteRestricted bool
+
+	// AppContainerSID is the package SID of an AppContainer token, which is
+	// also checked against the package SID and its Capabilities. Less
+	// privileged AppContainers are not granted access given to ALL
+	// APPLICATION PACKAGES
+	AppContainerSID            *SID
+	Capabilities               []SID
+	LessPrivilegedAppContainer bool
+
+	pass        tokenPass // Which of the token's SIDs the current access check pass matches
+	objectOwner bool      // Whether the token owns the object being checked
+
+	// Claims and device groups used by conditional ACEs. Attributes are
+	// looked up by name, case-insensitively
+	UserClaims   map[string]ClaimValues // @User. attributes
+	DeviceClaims map[string]ClaimValues // @Device. attributes
+	LocalClaims  map[string]ClaimValues // Attributes without a prefix, such as WIN://SYSAPPID
+	DeviceGroups []SID                  // Groups checked by Device_Member_of
+
+	// Privileges holds the names of the token's enabled privileges, such
+	// as SeBackupPrivilege
+	Privileges []string
+
+	// Owner, PrimaryGroup and DefaultDACL are what CreatePrivateObjectSecurity
+	// gives new objects that neither the creator nor the parent describe.
+	// A nil Owner stands for UserSID
+	Owner        *SID
+	PrimaryGroup *SID
+	DefaultDACL  *ACL
 }
 
 // NewTokenUser creates a new TokenUser object
@@ -38,12 +82,23 @@ func NewTokenUser(userSID SID, groups []SID) *TokenUser {
 
 // AccessCheckOptions provides configuration for access checks
 type AccessCheckOptions struct {
-	IgnoreObjectType bool // Skip object type checks for object ACEs
-	CheckIntegrity   bool // Check integrity levels
-	IntegrityPolicy  IntegrityLevelPolicy
-	SubjectIntegrity IntegrityLevel // Subject's integrity level
-	ObjectIntegrity  IntegrityLevel // Object's integrity level
+	IgnoreObjectType bool                 // Skip object type checks for object ACEs
+	CheckIntegrity   bool                 // Check integrity levels
+	IntegrityPolicy  IntegrityLevelPolicy // Object's policy, unless the descriptor has a mandatory label
+	SubjectIntegrity IntegrityLevel       // Subject's integrity level
+	ObjectIntegrity  IntegrityLevel       // Object's integrity level, unless the descriptor has a mandatory label
 	GenericMapping   map[uint32]uint32
+	ObjectType       SecurableObjectType // When set, selects GenericMapping from GenericMappings
+	BackupIntent     bool                // Let SeBackupPrivilege and SeRestorePrivilege grant access, as FILE_FLAG_BACKUP_SEMANTICS does
+
+	// PrincipalSelfSID is the SID of the object being accessed when it is
+	// a principal, such as a user or computer account. It replaces the
+	// PRINCIPAL SELF SID (S-1-5-10) in ACEs
+	PrincipalSelfSID *SID
+
+	// ResourceAttributes holds the object's @Resource. attributes for
+	// conditional ACEs. When nil, they are read from the descriptor
+	ResourceAttributes map[string]ClaimValues
 }
 
 // DefaultAccessCheckOptions returns a default set of access check options
@@ -52,19 +107,42 @@ func DefaultAccessCheckOptions() *AccessCheckOptions {
 		IgnoreObjectType: true,
 		CheckIntegrity:   false,
 		IntegrityPolicy:  PolicyNoWriteUp,
-		GenericMapping: map[uint32]uint32{
-			AccessMaskGenericRead:    AccessMaskReadControl,
-			AccessMaskGenericWrite:   AccessMaskWriteDACL | AccessMaskWriteOwner,
-			AccessMaskGenericExecute: AccessMaskSynchronize,
-			AccessMaskGenericAll:     0xFFFFFFFF,
-		},
+		SubjectIntegrity: IntegrityLevelMedium,
+		ObjectIntegrity:  IntegrityLevelMedium,
+		GenericMapping:   defaultGenericMapping(),
 	}
 }
 
 // AccessCheck simulates the Windows access check algorithm
 // Returns whether the requested access is granted and additional details
+//
+// ACEs are evaluated in stored order and each right is decided by the first
+// applicable ACE that allows or denies it. Decisions that a canonically
+// ordered DACL would have reversed are flagged with a NonCanonicalOrder
+// step in Details
+//
+// When desiredAccess includes MAXIMUM_ALLOWED, Access holds every right
+// the token is granted and Rights records which ACE decided each of them
+//
+// Restricted tokens are checked a second time against their restricting
+// SIDs, and AppContainer tokens against their package and capability SIDs.
+// Only access granted by every check is granted
 func AccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
 	desiredAccess uint32, options *AccessCheckOptions,
+) *AccessCheckResult {
+	result := accessCheck(securityDescriptor, token, desiredAccess, options)
+	if token.IsRestricted() {
+		result = restrictedAccessCheck(securityDescriptor, token, desiredAccess, options, result)
+	}
+	if token.IsAppContainer() {
+		result = appContainerAccessCheck(securityDescriptor, token, desiredAccess, options, result)
+	}
+	return result
+}
+
+// accessCheck runs a single access check pass for the token's SIDs
+func accessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
+	desiredAccess uint32, options *AccessCheckOptions,
 ) *AccessCheckResult {
 	result := &AccessCheckResult{
 		Granted: false,
@@ -73,170 +151,154 @@ func AccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
 		Details: make([]CheckDetails, 0),
 	}
 
-	if options == nil {
-		options = DefaultAccessCheckOptions()
-	}
+	options = resolveAccessCheckOptions(securityDescriptor, options)
+	token = token.forObject(securityDescriptor)
 
 	// Map generic access rights if provided
 	mappedAccess := MapGenericAccess(desiredAccess, options.GenericMapping)
 
+	if desiredAccess&AccessMaskMaximumAllowed != 0 {
+		return maximumAllowedCheck(securityDescriptor, token, mappedAccess, options)
+	}
+
 	// Check integrity level policy if enabled
 	if options.CheckIntegrity {
-		integrityCheck := options.SubjectIntegrity.CheckAccess(
-			options.ObjectIntegrity,
-			options.IntegrityPolicy,
-			mappedAccess)
-
-		result.Details = append(result.Details, CheckDetails{
-			Step: "IntegrityLevel",
-			Description: fmt.Sprintf("Checking if integrity level %s can access %s with policy %d",
-				options.SubjectIntegrity, options.ObjectIntegrity, options.IntegrityPolicy),
-			Outcome: integrityCheck,
-		})
+		detail := checkIntegrity(securityDescriptor, token, options, mappedAccess)
+		result.Details = append(result.Details, detail)
 
-		if !integrityCheck {
+		if !detail.Outcome {
 			result.Reason = "Access denied by integrity level policy"
 			return result
 		}
 	}
 
+	// Privileges grant access regardless of the DACL
+	grants := privilegeGrants(token, options, mappedAccess)
+	result.Details = append(result.Details, privilegeDetails(grants)...)
+
+	// Only an explicit request needs the privilege; generic mappings that
+	// cover every bit are not treated as asking for ACCESS_SYSTEM_SECURITY
+	if desiredAccess&AccessMaskSystemSecurity != 0 {
+		detail := checkSecurityPrivilege(grants, mappedAccess)
+		result.Details = append(result.Details, detail)
+
+		if !detail.Outcome {
+			result.Reason = fmt.Sprintf("ACCESS_SYSTEM_SECURITY requires %s", SeSecurityPrivilege)
+			return result
+		}
+	}
+
 	// Check if the requested resource has a DACL
-	if len(securityDescriptor.DACL.Aces) == 0 {
-		// No DACL means full access (Windows rule)
+	switch securityDescriptor.DACLState() {
+	case ACLStateAbsent, ACLStateNull:
+		// No DACL or a NULL DACL means full access (Windows rule)
+		state := "No DACL"
+		if securityDescriptor.DACLState() == ACLStateNull {
+			state = "NULL DACL"
+		}
+
 		result.Granted = true
-		result.Reason = "No DACL present (full access)"
+		result.Reason = fmt.Sprintf("%s present (full access)", state)
 		result.Access = mappedAccess
 
 		result.Details = append(result.Details, CheckDetails{
 			Step:        "EmptyDACL",
-			Description: "No DACL present; full access granted",
+			Description: fmt.Sprintf("%s present; full access granted", state),
 			Outcome:     true,
 		})
 
 		return result
 	}
 
-	// Check owner access - owner always has READ_CONTROL and WRITE_DAC rights
-	isOwner := token.UserSID.String() == securityDescriptor.Owner.String()
-	ownerRights := uint32(AccessMaskReadControl | AccessMaskWriteDACL)
+	// Check owner access - the owner has READ_CONTROL and WRITE_DAC rights
+	// unless the DACL holds OWNER RIGHTS ACEs
+	ownerRights, ownerReason := token.implicitOwnerRights(securityDescriptor)
 
 	result.Details = append(result.Details, CheckDetails{
 		Step:        "OwnerCheck",
-		Description: fmt.Sprintf("Checking if user is owner: %v", isOwner),
-		Outcome:     isOwner,
+		Description: fmt.Sprintf("Checking if user is owner: %v - %s", token.objectOwner, ownerReason),
+		Outcome:     ownerRights != 0,
 	})
 
-	// If only requesting owner rights and user is owner, grant access
-	if isOwner && (mappedAccess & ^ownerRights) == 0 {
-		result.Granted = true
-		result.Reason = "Access granted to owner"
-		result.Access = mappedAccess & ownerRights
+	// Rights granted by ownership or privilege are decided before the DACL
+	implicitAccess := privilegeAccess(grants) | ownerRights
 
+	// Windows walks the DACL in stored order, and each requested right is
+	// decided by the first applicable ACE that allows or denies it
+	order := newACEOrderTracker(securityDescriptor.DACL.Aces)
+	grantedAccess := order.decideImplicit(mappedAccess & implicitAccess)
+	deniedAccess := uint32(0)
+	denyingACE := -1
+
+	if grantedAccess != 0 {
 		result.Details = append(result.Details, CheckDetails{
 			Step:        "OwnerRights",
-			Description: "Access granted based on ownership",
+			Description: fmt.Sprintf("Access mask 0x%08X granted based on ownership or privilege", grantedAccess),
 			Outcome:     true,
 		})
-
-		return result
 	}
 
-	// Process ACEs in order (Windows processes ACEs until a match is found)
-	grantedAccess := uint32(0)
-	deniedAccess := uint32(0)
-
-	// First process deny ACEs
 	for i, ace := range securityDescriptor.DACL.Aces {
-		// Skip non-deny ACEs in the first pass
-		if ace.Header.Type != AceTypeAccessDenied {
+		allow := isAllowAceType(ace.Header.Type)
+		deny := isDenyAceType(ace.Header.Type)
+		if (!allow && !deny) || ace.Header.Flags&ACEHeaderFlagsInheritOnlyAce != 0 || hasObjectType(ace) {
 			continue
 		}
 
+		step := fmt.Sprintf("AllowACE[%d]", i)
+		if deny {
+			step = fmt.Sprintf("DenyACE[%d]", i)
+		}
+
 		// Check if this ACE applies to the token
 		applies, reason := aceAppliesToToken(ace, token, options)
 
 		result.Details = append(result.Details, CheckDetails{
-			Step:        fmt.Sprintf("DenyACE[%d]", i),
-			Description: fmt.Sprintf("Checking if deny ACE applies: %v - %s", applies, reason),
+			Step:        step,
+			Description: fmt.Sprintf("Checking if %s ACE applies: %v - %s", ace.GetTypeString(), applies, reason),
 			Outcome:     applies,
 		})
 
-		if applies {
-			// Map the ACE's access mask using the same mapping
-			aceMappedAccess := MapGenericAccess(ace.AccessMask.Raw(), options.GenericMapping)
-
-			// If any requested access is explicitly denied, deny the entire request
-			// For debugging:
-			// fmt.Printf("ACE denies: 0x%08X (mapped: 0x%08X), requested: 0x%08X\n",
-			//    ace.AccessMask.Raw(), aceMappedAccess, mappedAccess)
-
-			if aceMappedAccess&mappedAccess != 0 {
-				result.Reason = fmt.Sprintf("Access explicitly denied by ACE %d", i)
-				deniedAccess |= (aceMappedAccess & mappedAccess)
-
-				result.Details = append(result.Details, CheckDetails{
-					Step: fmt.Sprintf("DenyACE[%d]Match", i),
-					Description: fmt.Sprintf(
-						"Access denied by ACE - access mask 0x%08X",
-						aceMappedAccess&mappedAccess,
-					),
-					Outcome: false,
-				})
-
-				// If all requested access is denied, return immediately
-				if deniedAccess == mappedAccess {
-					ace := ace // Copy to avoid issues with loop variable in closures
-					result.Ace = &ace
-					return result
-				}
-			}
+		if !applies {
+			continue
 		}
-	}
 
-	// Then process allow ACEs
-	for i, ace := range securityDescriptor.DACL.Aces {
-		// Skip non-allow ACEs in the second pass
-		if ace.Header.Type != AceTypeAccessAllowed {
+		// Map the ACE's access mask using the same mapping
+		aceMappedAccess := MapGenericAccess(ace.AccessMask.Raw(), options.GenericMapping) & mappedAccess
+
+		// Rights already decided by an earlier ACE are left alone, but
+		// are flagged when only the DACL's non-canonical order decided them
+		decided, conflicts := order.apply(i, aceMappedAccess, allow)
+		result.Details = append(result.Details, conflicts...)
+
+		if decided == 0 {
 			continue
 		}
 
-		// Check if this ACE applies to the token
-		applies, reason := aceAppliesToToken(ace, token, options)
+		if allow {
+			grantedAccess |= decided
+
+			result.Details = append(result.Details, CheckDetails{
+				Step:        step + "Match",
+				Description: fmt.Sprintf("Access allowed by ACE - access mask 0x%08X", decided),
+				Outcome:     true,
+			})
+			continue
+		}
+
+		deniedAccess |= decided
 
 		result.Details = append(result.Details, CheckDetails{
-			Step:        fmt.Sprintf("AllowACE[%d]", i),
-			Description: fmt.Sprintf("Checking if allow ACE applies: %v - %s", applies, reason),
-			Outcome:     applies,
+			Step:        step + "Match",
+			Description: fmt.Sprintf("Access denied by ACE - access mask 0x%08X", decided),
+			Outcome:     false,
 		})
 
-		if applies {
-			// Map the ACE's access mask using the same mapping
-			aceMappedAccess := MapGenericAccess(ace.AccessMask.Raw(), options.GenericMapping)
-
-			// Mark any explicitly allowed access rights
-			allowedByThisAce := aceMappedAccess & mappedAccess & ^deniedAccess
-
-			// For debugging:
-			// fmt.Printf("ACE allows: 0x%08X (mapped: 0x%08X), requested: 0x%08X, denied: 0x%08X, allowed: 0x%08X\n",
-			//    ace.AccessMask.Raw(), aceMappedAccess, mappedAccess, deniedAccess, allowedByThisAce)
-
-			if allowedByThisAce != 0 {
-				grantedAccess |= allowedByThisAce
-
-				result.Details = append(result.Details, CheckDetails{
-					Step: fmt.Sprintf("AllowACE[%d]Match", i),
-					Description: fmt.Sprintf(
-						"Access allowed by ACE - access mask 0x%08X",
-						allowedByThisAce,
-					),
-					Outcome: true,
-				})
-
-				// If all requested access is granted, we can return immediately
-				if (grantedAccess | deniedAccess) == mappedAccess {
-					break
-				}
-			}
+		// The first ACE that denies a requested right determines the result
+		if result.Ace == nil {
+			ace := ace // Copy to avoid issues with loop variable in closures
+			result.Ace = &ace
+			denyingACE = i
 		}
 	}
 
@@ -251,6 +313,9 @@ func AccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
 		// All requested access was granted
 		result.Granted = true
 		result.Reason = "Access granted by ACL"
+		if grantedAccess&^implicitAccess == 0 {
+			result.Reason = "Access granted by ownership or privilege"
+		}
 		result.Access = grantedAccess
 
 		result.Details = append(result.Details, CheckDetails{
@@ -262,7 +327,9 @@ func AccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
 		// Some access was not granted
 		result.Granted = false
 
-		if deniedAccess != 0 {
+		if result.Ace != nil {
+			result.Reason = fmt.Sprintf("Access explicitly denied by ACE %d", denyingACE)
+		} else if deniedAccess != 0 {
 			result.Reason = "Some requested access was explicitly denied"
 		} else {
 			result.Reason = "Some requested access was not granted by any ACE"
@@ -282,8 +349,197 @@ func AccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
 	return result
 }
 
-// aceAppliesToToken determines if an ACE applies to the given security token
+// OwnerRightsSID is the OWNER RIGHTS SID. ACEs for it apply to the owner
+// of an object and replace the owner's implicit rights
+const OwnerRightsSID = "S-1-3-4"
+
+// PrincipalSelfSID is the PRINCIPAL SELF SID. ACEs for it apply when the
+// caller is the object being accessed, as given by the PrincipalSelfSID
+// access check option
+const PrincipalSelfSID = "S-1-5-10"
+
+// creatorPlaceholderSIDs are the CREATOR OWNER and CREATOR GROUP SIDs,
+// which inheritance replaces with the new object's owner and group
+var creatorPlaceholderSIDs = map[string]bool{
+	"S-1-3-0": true, // CREATOR OWNER
+	"S-1-3-1": true, // CREATOR GROUP
+	"S-1-3-2": true, // CREATOR OWNER SERVER
+	"S-1-3-3": true, // CREATOR GROUP SERVER
+}
+
+// withACESID returns a copy of the ACE with its SID replaced
+func withACESID(ace ACE, sid SID) ACE {
+	switch oa := ace.ObjectAce.(type) {
+	case BasicAce:
+		oa.SecurityIdentifier = sid
+		ace.ObjectAce = oa
+	case AdvancedAce:
+		oa.SecurityIdentifier = sid
+		ace.ObjectAce = oa
+	}
+	return ace
+}
+
+// forObject returns a copy of the token that records whether it owns the
+// descriptor, so that OWNER RIGHTS ACEs can be matched
+func (t *TokenUser) forObject(securityDescriptor *NtSecurityDescriptor) *TokenUser {
+	view := *t
+	view.objectOwner = t.ownsObject(securityDescriptor)
+	return &view
+}
+
+// ownsObject reports whether the token is the owner of the descriptor,
+// either as its user or through one of its enabled groups
+func (t *TokenUser) ownsObject(securityDescriptor *NtSecurityDescriptor) bool {
+	if securityDescriptor.Owner == nil {
+		return false
+	}
+	owner := *securityDescriptor.Owner
+
+	if t.pass != tokenPassNormal {
+		return containsSID(t.passSIDs(), owner)
+	}
+	if t.UserSID.Equal(owner) {
+		return true
+	}
+	for _, group := range t.Groups {
+		if !group.Equal(owner) {
+			continue
+		}
+		if matches, _ := t.groupMatches(group, false); matches {
+			return true
+		}
+	}
+	return false
+}
+
+// implicitOwnerRights returns the READ_CONTROL and WRITE_DAC rights an
+// owner is implicitly granted, which any OWNER RIGHTS ACE in the DACL
+// suppresses
+func (t *TokenUser) implicitOwnerRights(securityDescriptor *NtSecurityDescriptor) (uint32, string) {
+	if !t.ownsObject(securityDescriptor) {
+		return 0, "Not the owner"
+	}
+
+	if securityDescriptor.DACL != nil {
+		for _, ace := range securityDescriptor.DACL.Aces {
+			if ace.Header.Flags&ACEHeaderFlagsInheritOnlyAce != 0 {
+				continue
+			}
+			if ace.ObjectAce != nil && ace.ObjectAce.GetPrincipal().String() == OwnerRightsSID {
+				return 0, "OWNER RIGHTS ACEs replace the implicit owner rights"
+			}
+		}
+	}
+	return AccessMaskReadControl | AccessMaskWriteDACL, "Owner implicitly granted READ_CONTROL and WRITE_DAC"
+}
+
+// containsSID reports whether sids holds sid
+func containsSID(sids []SID, sid SID) bool {
+	for _, s := range sids {
+		if s.Equal(sid) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAccessCheckOptions fills in the defaults for missing options.
+// The object type's generic mapping replaces GenericMapping, and resource
+// attributes default to those held in the descriptor's SACL
+func resolveAccessCheckOptions(securityDescriptor *NtSecurityDescriptor, options *AccessCheckOptions) *AccessCheckOptions {
+	if options == nil {
+		options = DefaultAccessCheckOptions()
+	}
+
+	if options.ObjectType != SecurableGeneric {
+		withMapping := *options
+		withMapping.GenericMapping = GenericMappingFor(options.ObjectType)
+		options = &withMapping
+	}
+
+	if options.ResourceAttributes == nil {
+		withAttributes := *options
+		withAttributes.ResourceAttributes = securityDescriptor.ResourceAttributes()
+		options = &withAttributes
+	}
+	return options
+}
+
+// checkIntegrity applies the mandatory integrity policy. A mandatory label
+// in the descriptor takes precedence over the object integrity in the
+// options, and the token's integrity group over the subject integrity.
+// The policy blocks the specific rights of the options' generic mapping
+func checkIntegrity(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
+	options *AccessCheckOptions, mappedAccess uint32,
+) CheckDetails {
+	objectIntegrity, integrityPolicy := options.ObjectIntegrity, options.IntegrityPolicy
+	source := "options"
+	if level, policy, ok := securityDescriptor.MandatoryLabel(); ok {
+		objectIntegrity, integrityPolicy = level, policy
+		source = "mandatory label"
+	}
+
+	subjectIntegrity := options.SubjectIntegrity
+	subjectSource := "options"
+	if level, ok := token.IntegrityLevel(); ok {
+		subjectIntegrity = level
+		subjectSource = "token"
+	}
+
+	integrityCheck := subjectIntegrity.CheckAccessWithMapping(
+		objectIntegrity,
+		integrityPolicy,
+		mappedAccess,
+		options.GenericMapping)
+
+	return CheckDetails{
+		Step: "IntegrityLevel",
+		Description: fmt.Sprintf("Checking if integrity level %s (from %s) can access %s with policy %d (from %s)",
+			subjectIntegrity, subjectSource, objectIntegrity, integrityPolicy, source),
+		Outcome: integrityCheck,
+	}
+}
+
+// aceAppliesToToken determines if an ACE applies to the given security token.
+// Callback ACEs must also satisfy their condition: allow ACEs apply only
+// when it is TRUE, while deny ACEs apply unless it is FALSE
 func aceAppliesToToken(ace ACE, token *TokenUser, options *AccessCheckOptions) (bool, string) {
+	applies, reason := aceSIDAppliesToToken(ace, token, options)
+	if !applies || !ace.IsCallback() {
+		return applies, reason
+	}
+
+	cond := ace.GetCondition()
+	result := ConditionalTrue
+	switch {
+	case cond != nil:
+		result = cond.evaluate(token, options.ResourceAttributes, isDenyAceType(ace.Header.Type))
+	case hasApplicationData(ace):
+		// Opaque callback data can only be interpreted by the application
+		result = ConditionalUnknown
+	}
+
+	deny := isDenyAceType(ace.Header.Type)
+	applies = result == ConditionalTrue || (deny && result == ConditionalUnknown)
+	return applies, fmt.Sprintf("%s; condition is %s", reason, result)
+}
+
+// hasApplicationData reports whether a callback ACE carries data other
+// than a conditional expression
+func hasApplicationData(ace ACE) bool {
+	switch oa := ace.ObjectAce.(type) {
+	case BasicAce:
+		return len(oa.ApplicationData) > 0
+	case AdvancedAce:
+		return len(oa.ApplicationData) > 0
+	}
+	return false
+}
+
+// aceSIDAppliesToToken determines if an ACE's SID matches the given
+// security token
+func aceSIDAppliesToToken(ace ACE, token *TokenUser, options *AccessCheckOptions) (bool, string) {
 	// Get the SID from the ACE
 	var aceSID SID
 
@@ -293,12 +549,8 @@ func aceAppliesToToken(ace ACE, token *TokenUser, options *AccessCheckOptions) (
 	case AdvancedAce:
 		aceSID = oa.SecurityIdentifier
 
-		// Check if this is an object ACE and we need to check object types
-		if !options.IgnoreObjectType {
-			// If this is an Object ACE, further checks for object type would be done here
-			// This would involve checking if the requested object's type matches the ACE's object type
-			// For this implementation, we're simplifying by ignoring object types if requested
-		}
+		// Object types are matched against an object type list by
+		// AccessCheckByType; only the SID is checked here
 	default:
 		return false, "Unknown ACE object type"
 	}
@@ -306,33 +558,154 @@ func aceAppliesToToken(ace ACE, token *TokenUser, options *AccessCheckOptions) (
 	// Check for well-known SIDs
 	aceSIDStr := aceSID.String()
 
+	// Creator SIDs are replaced when an ACE is inherited and never match
+	if creatorPlaceholderSIDs[aceSIDStr] {
+		return false, fmt.Sprintf("%s (%s) is an inheritance placeholder and never matches a token",
+			WellKnownSIDs[aceSIDStr], aceSIDStr)
+	}
+
+	// PRINCIPAL SELF stands for the object being accessed, when it is a
+	// principal itself
+	if aceSIDStr == PrincipalSelfSID {
+		if options.PrincipalSelfSID == nil || options.PrincipalSelfSID.String() == PrincipalSelfSID {
+			return false, "PRINCIPAL SELF SID does not match without a principal self SID in the options"
+		}
+		applies, reason := aceSIDAppliesToToken(withACESID(ace, *options.PrincipalSelfSID), token, options)
+		return applies, fmt.Sprintf("PRINCIPAL SELF is %s: %s", options.PrincipalSelfSID, reason)
+	}
+
+	// OWNER RIGHTS matches the owner of the object
+	if aceSIDStr == OwnerRightsSID {
+		if token.objectOwner {
+			return true, "OWNER RIGHTS SID matches the object owner"
+		}
+		return false, "OWNER RIGHTS SID does not match a non-owner"
+	}
+
+	// The restricting SIDs and AppContainer passes only match their own SIDs
+	if token.pass != tokenPassNormal {
+		if containsSID(token.passSIDs(), aceSID) {
+			return true, fmt.Sprintf("Matches a %s SID", token.pass)
+		}
+		return false, fmt.Sprintf("No %s SID match found", token.pass)
+	}
+
 	// For debugging:
 	// fmt.Printf("Checking ACE SID: %s vs user SID: %s\n", aceSIDStr, token.UserSID.String())
 	// fmt.Printf("User groups: %v\n", token.Groups)
 
-	// Everyone (S-1-1-0) always matches all tokens
-	if aceSIDStr == "S-1-1-0" {
+	// Everyone (S-1-1-0) always matches all tokens, unless the token holds
+	// it as a group with attributes of its own
+	if _, held := token.GroupAttributes[aceSID.Key()]; aceSIDStr == "S-1-1-0" && !held {
 		return true, "Everyone SID matches all tokens"
 	}
 
 	// Check if the SID matches the user directly
-	if aceSIDStr == token.UserSID.String() {
+	if aceSID.Equal(token.UserSID) {
 		return true, "Directly matches user SID"
 	}
 
 	// Check if the SID matches any of the user's groups
 	for _, group := range token.Groups {
-		groupStr := group.String()
-		// For debugging:
-		// fmt.Printf("  Comparing to group: %s\n", groupStr)
-		if aceSIDStr == groupStr {
-			return true, "Matches a group SID"
+		if aceSID.Equal(group) {
+			return token.groupMatches(group, isDenyAceType(ace.Header.Type))
 		}
 	}
 
 	return false, "No SID match found"
 }
 
+// aceOrderTracker records which ACE decided each access right. Windows
+// expects a canonical DACL: explicit deny ACEs, then explicit allow ACEs,
+// then inherited deny and inherited allow ACEs. When a DACL is out of that
+// order, a right may be decided by an ACE that a later one would have
+// overridden had the DACL been canonical
+type aceOrderTracker struct {
+	aces     []ACE
+	deciders [32]int // Index of the ACE that decided each bit, or a negative value
+	allowed  uint32  // Decided bits that were allowed
+}
+
+// Deciders that are not ACEs
+const (
+	aceOrderUndecided = -1
+	aceOrderImplicit  = -2 // Granted by ownership or privilege
+)
+
+func newACEOrderTracker(aces []ACE) *aceOrderTracker {
+	t := &aceOrderTracker{aces: aces}
+	for bit := range t.deciders {
+		t.deciders[bit] = aceOrderUndecided
+	}
+	return t
+}
+
+// decideImplicit marks rights granted by ownership or privilege as
+// allowed and returns them
+func (t *aceOrderTracker) decideImplicit(mask uint32) uint32 {
+	for _, right := range accessRights(mask) {
+		t.deciders[bits.TrailingZeros32(right)] = aceOrderImplicit
+	}
+	t.allowed |= mask
+	return mask
+}
+
+// apply records ACE i allowing or denying mask. It returns the rights no
+// earlier ACE decided, along with a detail for each earlier ACE whose
+// decision this one would have overridden in a canonical DACL
+func (t *aceOrderTracker) apply(i int, mask uint32, allow bool) (uint32, []CheckDetails) {
+	decided := uint32(0)
+	overridden := make(map[int]uint32)
+	rank := canonicalACERank(t.aces[i])
+
+	for _, right := range accessRights(mask) {
+		bit := bits.TrailingZeros32(right)
+		earlier := t.deciders[bit]
+
+		switch {
+		case earlier == aceOrderUndecided:
+			t.deciders[bit] = i
+			decided |= right
+			if allow {
+				t.allowed |= right
+			}
+		case earlier >= 0 && (t.allowed&right != 0) != allow && canonicalACERank(t.aces[earlier]) > rank:
+			overridden[earlier] |= right
+		}
+	}
+
+	earlierACEs := make([]int, 0, len(overridden))
+	for earlier := range overridden {
+		earlierACEs = append(earlierACEs, earlier)
+	}
+	sort.Ints(earlierACEs)
+
+	details := make([]CheckDetails, 0, len(earlierACEs))
+	for _, earlier := range earlierACEs {
+		details = append(details, CheckDetails{
+			Step: "NonCanonicalOrder",
+			Description: fmt.Sprintf(
+				"ACE[%d] decided access mask 0x%08X before ACE[%d] (%s), which precedes it in canonical order",
+				earlier, overridden[earlier], i, t.aces[i].GetTypeString()),
+			Outcome: false,
+		})
+	}
+	return decided, details
+}
+
+// canonicalACERank orders ACEs as a canonical DACL would: explicit deny,
+// explicit allow, inherited deny, inherited allow
+func canonicalACERank(ace ACE) int {
+	rank := 0
+	if ace.Header.Flags&ACEHeaderFlagsInheritedAce != 0 {
+		rank = 2
+	}
+	if !isDenyAceType(ace.Header.Type) {
+		rank++
+	}
+	return rank
+}
+
 // MapGenericAccess maps generic access rights to specific rights
 func MapGenericAccess(access uint32, mapping map[uint32]uint32) uint32 {
 	if mapping == nil {
@@ -1,6 +1,7 @@
 package winacl
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -11,13 +12,13 @@ func TestAccessCheck(t *testing.T) {
 	systemSID, _ := NewSIDFromString("S-1-5-18") // System
 	everyoneSID, _ := NewSIDFromString("S-1-1-0") // Everyone
 
-	// Test case 1: Empty DACL - Full access
-	t.Run("EmptyDACL", func(t *testing.T) {
-		// Create a security descriptor with an empty DACL
+	// Test case 1: No DACL - Full access
+	t.Run("NoDACL", func(t *testing.T) {
+		// Create a security descriptor without a DACL
 		sd := &NtSecurityDescriptor{
-			Owner: adminSID,
-			Group: adminSID,
-			DACL:  ACL{},
+			Owner: &adminSID,
+			Group: &adminSID,
+			DACL:  nil,
 		}
 
 		// Create a token for a regular user
@@ -26,9 +27,29 @@ func TestAccessCheck(t *testing.T) {
 		// Request read access
 		result := AccessCheck(sd, token, AccessMaskGenericRead, nil)
 
-		// Empty DACL should grant full access
+		// A missing DACL should grant full access
 		if !result.Granted {
-			t.Errorf("Empty DACL should grant access, but got: %s", result.Reason)
+			t.Errorf("Missing DACL should grant access, but got: %s", result.Reason)
+		}
+	})
+
+	// Test case 1b: NULL DACL grants access, empty DACL denies it
+	t.Run("NullVersusEmptyDACL", func(t *testing.T) {
+		token := NewTokenUser(userSID, []SID{everyoneSID})
+
+		sd := &NtSecurityDescriptor{
+			Header: NtSecurityDescriptorHeader{Control: DACLPresent},
+			Owner:  &adminSID,
+		}
+		result := AccessCheck(sd, token, AccessMaskGenericRead, nil)
+		if !result.Granted {
+			t.Errorf("NULL DACL should grant access, but got: %s", result.Reason)
+		}
+
+		sd.DACL = &ACL{}
+		result = AccessCheck(sd, token, AccessMaskGenericRead, nil)
+		if result.Granted {
+			t.Errorf("Empty DACL should deny access, but was granted")
 		}
 	})
 
@@ -36,9 +57,9 @@ func TestAccessCheck(t *testing.T) {
 	t.Run("OwnerAccess", func(t *testing.T) {
 		// Create a security descriptor with a DACL that has no ACEs for the user
 		sd := &NtSecurityDescriptor{
-			Owner: userSID, // User is the owner
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &userSID, // User is the owner
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{
 					// No ACEs that would grant access to the user
 				},
@@ -64,9 +85,9 @@ func TestAccessCheck(t *testing.T) {
 
 		// Create a security descriptor with a DACL containing the allow ACE
 		sd := &NtSecurityDescriptor{
-			Owner: adminSID,
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &adminSID,
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -93,9 +114,9 @@ func TestAccessCheck(t *testing.T) {
 
 		// Create a security descriptor with a DACL containing both ACEs
 		sd := &NtSecurityDescriptor{
-			Owner: adminSID,
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &adminSID,
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{denyAce, allowAce},
 			},
 		}
@@ -130,9 +151,9 @@ func TestAccessCheck(t *testing.T) {
 
 		// Create a security descriptor with a DACL containing both ACEs
 		sd := &NtSecurityDescriptor{
-			Owner: adminSID,
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &adminSID,
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{denyAce, allowAce},
 			},
 		}
@@ -156,9 +177,9 @@ func TestAccessCheck(t *testing.T) {
 
 		// Create a security descriptor with a DACL containing the allow ACE
 		sd := &NtSecurityDescriptor{
-			Owner: adminSID,
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &adminSID,
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -182,9 +203,9 @@ func TestAccessCheck(t *testing.T) {
 
 		// Create a security descriptor with a DACL containing the allow ACE
 		sd := &NtSecurityDescriptor{
-			Owner: systemSID,
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &systemSID,
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -211,9 +232,9 @@ func TestAccessCheck(t *testing.T) {
 			AccessMaskGenericRead | AccessMaskGenericWrite)
 		
 		sd := &NtSecurityDescriptor{
-			Owner: adminSID,
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &adminSID,
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -270,9 +291,9 @@ func TestAccessCheck(t *testing.T) {
 		allowAce := NewAccessAllowedACE(everyoneSID, specificRights)
 		
 		sd := &NtSecurityDescriptor{
-			Owner: adminSID,
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &adminSID,
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -311,9 +332,9 @@ func TestAccessCheck(t *testing.T) {
 		allowAce := NewAccessAllowedACE(everyoneSID, AccessMaskGenericRead | AccessMaskReadControl)
 		
 		sd := &NtSecurityDescriptor{
-			Owner: adminSID,
-			Group: adminSID,
-			DACL: ACL{
+			Owner: &adminSID,
+			Group: &adminSID,
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -494,9 +515,9 @@ func TestIntegrityLevelCheck(t *testing.T) {
 			AccessMaskWriteDACL | AccessMaskReadControl | AccessMaskDelete | 0xFFFF)
 		
 		sd := &NtSecurityDescriptor{
-			Owner: NewSIDFromStringOrPanic("S-1-5-18"), // System
-			Group: NewSIDFromStringOrPanic("S-1-5-32-544"), // Administrators
-			DACL: ACL{
+			Owner: sidPtr("S-1-5-18"), // System
+			Group: sidPtr("S-1-5-32-544"), // Administrators
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -537,9 +558,9 @@ func TestIntegrityLevelCheck(t *testing.T) {
 			AccessMaskWriteDACL | AccessMaskReadControl | AccessMaskDelete | 0xFFFF)
 		
 		sd := &NtSecurityDescriptor{
-			Owner: NewSIDFromStringOrPanic("S-1-5-18"), // System
-			Group: NewSIDFromStringOrPanic("S-1-5-32-544"), // Administrators
-			DACL: ACL{
+			Owner: sidPtr("S-1-5-18"), // System
+			Group: sidPtr("S-1-5-32-544"), // Administrators
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -580,9 +601,9 @@ func TestIntegrityLevelCheck(t *testing.T) {
 			AccessMaskWriteDACL | AccessMaskReadControl | AccessMaskDelete | 0xFFFF)
 		
 		sd := &NtSecurityDescriptor{
-			Owner: NewSIDFromStringOrPanic("S-1-5-18"), // System
-			Group: NewSIDFromStringOrPanic("S-1-5-32-544"), // Administrators
-			DACL: ACL{
+			Owner: sidPtr("S-1-5-18"), // System
+			Group: sidPtr("S-1-5-32-544"), // Administrators
+			DACL: &ACL{
 				Aces: []ACE{allowAce},
 			},
 		}
@@ -624,6 +645,12 @@ func NewSIDFromStringOrPanic(sidString string) SID {
 	return sid
 }
 
+// Helper function to take the address of a SID parsed from a string
+func sidPtr(sidString string) *SID {
+	sid := NewSIDFromStringOrPanic(sidString)
+	return &sid
+}
+
 // Helper functions for creating ACEs more easily
 func NewAccessAllowedACE(sid SID, value uint32) ACE {
 	return ACE{
@@ -650,3 +677,368 @@ func NewAccessDeniedACE(sid SID, value uint32) ACE {
 		},
 	}
 }
+
+func TestAccessCheckConditionalACEs(t *testing.T) {
+	userSID := NewSIDFromStringOrPanic("S-1-5-21-1234567890-1234567890-1234567890-1001")
+
+	newSD := func(t *testing.T, sddl string) *NtSecurityDescriptor {
+		sd, err := NewNtSecurityDescriptorFromSDDL(sddl)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", sddl, err)
+		}
+		return &sd
+	}
+
+	t.Run("ConditionalAllowRequiresTrue", func(t *testing.T) {
+		sd := newSD(t, `O:BAD:(XA;;0x1;;;WD;(@User.Department == "Finance"))`)
+
+		token := NewTokenUser(userSID, nil)
+		token.UserClaims = map[string]ClaimValues{"department": NewStringClaim("finance")}
+		if result := AccessCheck(sd, token, 0x1, nil); !result.Granted {
+			t.Errorf("Matching claim should grant access, but got: %s", result.Reason)
+		}
+
+		token.UserClaims = map[string]ClaimValues{"Department": NewStringClaim("Sales")}
+		if result := AccessCheck(sd, token, 0x1, nil); result.Granted {
+			t.Errorf("Mismatched claim should not grant access")
+		}
+
+		// A missing claim makes the condition UNKNOWN, so the allow ACE is skipped
+		token.UserClaims = nil
+		if result := AccessCheck(sd, token, 0x1, nil); result.Granted {
+			t.Errorf("Missing claim should not grant access")
+		}
+	})
+
+	t.Run("ConditionalDenyAppliesWhenUnknown", func(t *testing.T) {
+		sd := newSD(t, `O:BAD:(XD;;0x1;;;WD;(@User.clearance < 3))(A;;0x1;;;WD)`)
+
+		token := NewTokenUser(userSID, nil)
+		token.UserClaims = map[string]ClaimValues{"clearance": NewIntClaim(5)}
+		if result := AccessCheck(sd, token, 0x1, nil); !result.Granted {
+			t.Errorf("FALSE condition should not deny access, but got: %s", result.Reason)
+		}
+
+		token.UserClaims = map[string]ClaimValues{"clearance": NewIntClaim(1)}
+		if result := AccessCheck(sd, token, 0x1, nil); result.Granted {
+			t.Errorf("TRUE condition should deny access")
+		}
+
+		token.UserClaims = nil
+		if result := AccessCheck(sd, token, 0x1, nil); result.Granted {
+			t.Errorf("UNKNOWN condition should deny access")
+		}
+	})
+
+	t.Run("DeviceGroupsAndResourceAttributes", func(t *testing.T) {
+		sd := newSD(t, `O:BAD:(XA;;0x1;;;WD;((Device_Member_of {SID(S-1-5-21-1-2-3-515)}) && (@Resource.Project Any_of {"Apollo", "Gemini"})))`)
+
+		token := NewTokenUser(userSID, nil)
+		token.DeviceGroups = []SID{NewSIDFromStringOrPanic("S-1-5-21-1-2-3-515")}
+		options := DefaultAccessCheckOptions()
+		options.ResourceAttributes = map[string]ClaimValues{"Project": NewStringClaim("Gemini")}
+
+		if result := AccessCheck(sd, token, 0x1, options); !result.Granted {
+			t.Errorf("Device group and resource attribute should grant access, but got: %s", result.Reason)
+		}
+
+		token.DeviceGroups = nil
+		if result := AccessCheck(sd, token, 0x1, options); result.Granted {
+			t.Errorf("Missing device group should not grant access")
+		}
+	})
+}
+
+func TestAccessCheckMandatoryLabel(t *testing.T) {
+	userSID := NewSIDFromStringOrPanic("S-1-5-21-1234567890-1234567890-1234567890-1001")
+	token := NewTokenUser(userSID, nil)
+
+	sd, err := NewNtSecurityDescriptorFromSDDL("O:BAD:(A;;GA;;;WD)S:(ML;;NWNR;;;HI)")
+	if err != nil {
+		t.Fatalf("parsing descriptor: %v", err)
+	}
+
+	options := DefaultAccessCheckOptions()
+	options.CheckIntegrity = true
+
+	// The label raises the object above the Medium default in the options
+	if result := AccessCheck(&sd, token, AccessMaskReadControl, options); result.Granted {
+		t.Errorf("NO_READ_UP label should deny read access to a Medium subject")
+	}
+
+	options.SubjectIntegrity = IntegrityLevelHigh
+	if result := AccessCheck(&sd, token, AccessMaskReadControl, options); !result.Granted {
+		t.Errorf("High subject should pass the label check, but got: %s", result.Reason)
+	}
+
+	// Without a label the Medium default applies
+	unlabelled, err := NewNtSecurityDescriptorFromSDDL("O:BAD:(A;;GA;;;WD)")
+	if err != nil {
+		t.Fatalf("parsing descriptor: %v", err)
+	}
+	options.SubjectIntegrity = IntegrityLevelLow
+	if result := AccessCheck(&unlabelled, token, AccessMaskWriteDACL, options); result.Granted {
+		t.Errorf("Low subject should not write up to an unlabelled object")
+	}
+	if result := AccessCheck(&unlabelled, token, AccessMaskReadControl, options); !result.Granted {
+		t.Errorf("Low subject should read an unlabelled object, but got: %s", result.Reason)
+	}
+}
+
+func TestAccessCheckMandatoryLabelSpecificRights(t *testing.T) {
+	userSID := NewSIDFromStringOrPanic("S-1-5-21-1234567890-1234567890-1234567890-1001")
+	token := NewTokenUser(userSID, nil)
+
+	sd, err := NewNtSecurityDescriptorFromSDDL("O:BAG:SYD:(A;;FA;;;WD)S:(ML;;NW;;;ME)")
+	if err != nil {
+		t.Fatalf("parsing descriptor: %v", err)
+	}
+
+	options := DefaultAccessCheckOptions()
+	options.CheckIntegrity = true
+	options.SubjectIntegrity = IntegrityLevelLow
+	options.GenericMapping = map[uint32]uint32{
+		AccessMaskGenericRead:    0x00120089,
+		AccessMaskGenericWrite:   0x00120116,
+		AccessMaskGenericExecute: 0x001200A0,
+		AccessMaskGenericAll:     0x001F01FF,
+	}
+
+	// NO_WRITE_UP blocks the rights FILE_GENERIC_WRITE maps to:
+	// FILE_WRITE_DATA and FILE_APPEND_DATA
+	for _, access := range []uint32{0x2, 0x4, AccessMaskGenericWrite, AccessMaskWriteDACL} {
+		if result := AccessCheck(&sd, token, access, options); result.Granted {
+			t.Errorf("Low subject should not be granted 0x%08X on a Medium NO_WRITE_UP object", access)
+		}
+	}
+
+	// Rights shared with FILE_GENERIC_READ stay readable
+	for _, access := range []uint32{0x1, AccessMaskGenericRead, AccessMaskReadControl, AccessMaskSynchronize} {
+		if result := AccessCheck(&sd, token, access, options); !result.Granted {
+			t.Errorf("Low subject should be granted 0x%08X, but got: %s", access, result.Reason)
+		}
+	}
+
+	result := AccessCheck(&sd, token, AccessMaskMaximumAllowed, options)
+	if result.Access&0x2 != 0 {
+		t.Errorf("MAXIMUM_ALLOWED should not include FILE_WRITE_DATA, got 0x%08X", result.Access)
+	}
+	if result.Access&0x1 == 0 {
+		t.Errorf("MAXIMUM_ALLOWED should include FILE_READ_DATA, got 0x%08X", result.Access)
+	}
+}
+
+func TestAccessCheckObjectACEs(t *testing.T) {
+	userSID := NewSIDFromStringOrPanic("S-1-5-21-1234567890-1234567890-1234567890-1001")
+	token := NewTokenUser(userSID, nil)
+
+	// Object ACEs without an ObjectType apply to the whole object
+	sd, err := NewNtSecurityDescriptorFromSDDL("O:BAD:(OD;;WP;;;WD)(OA;;RPWP;;;WD)")
+	if err != nil {
+		t.Fatalf("parsing descriptor: %v", err)
+	}
+	if result := AccessCheck(&sd, token, ADSRightDSReadProp, nil); !result.Granted {
+		t.Errorf("OA ACE without an object type should grant READ_PROPERTY, but got: %s", result.Reason)
+	}
+	if result := AccessCheck(&sd, token, ADSRightDSWriteProp, nil); result.Granted {
+		t.Errorf("OD ACE without an object type should deny WRITE_PROPERTY")
+	}
+
+	// Those with an ObjectType are left to AccessCheckByType
+	sd, err = NewNtSecurityDescriptorFromSDDL("O:BAD:(OA;;RP;bf967a86-0de6-11d0-a285-00aa003049e2;;WD)")
+	if err != nil {
+		t.Fatalf("parsing descriptor: %v", err)
+	}
+	if result := AccessCheck(&sd, token, ADSRightDSReadProp, nil); result.Granted {
+		t.Errorf("OA ACE with an object type should not grant access to the whole object")
+	}
+}
+
+func TestAccessCheckACEOrder(t *testing.T) {
+	userSID := NewSIDFromStringOrPanic("S-1-5-21-1234567890-1234567890-1234567890-1001")
+	token := NewTokenUser(userSID, nil)
+
+	nonCanonical := func(result *AccessCheckResult) bool {
+		for _, detail := range result.Details {
+			if detail.Step == "NonCanonicalOrder" {
+				return true
+			}
+		}
+		return false
+	}
+
+	tests := []struct {
+		name         string
+		sddl         string
+		granted      bool
+		nonCanonical bool
+	}{
+		{"CanonicalDenyFirst", "O:BAD:(D;;0x1;;;WD)(A;;0x1;;;WD)", false, false},
+		{"AllowBeforeDeny", "O:BAD:(A;;0x1;;;WD)(D;;0x1;;;WD)", true, true},
+		{"ExplicitAllowBeforeInheritedDeny", "O:BAD:(A;;0x1;;;WD)(D;ID;0x1;;;WD)", true, false},
+		{"InheritedDenyBeforeExplicitAllow", "O:BAD:(D;ID;0x1;;;WD)(A;;0x1;;;WD)", false, true},
+		{"InheritOnlyDenySkipped", "O:BAD:(D;OICIIO;0x1;;;WD)(A;;0x1;;;WD)", true, false},
+		{"DisjointRights", "O:BAD:(A;;0x1;;;WD)(D;;0x2;;;WD)", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd, err := NewNtSecurityDescriptorFromSDDL(tt.sddl)
+			if err != nil {
+				t.Fatalf("parsing %q: %v", tt.sddl, err)
+			}
+
+			result := AccessCheck(&sd, token, 0x1, nil)
+			if result.Granted != tt.granted {
+				t.Errorf("Granted = %v, want %v: %s", result.Granted, tt.granted, result.Reason)
+			}
+			if got := nonCanonical(result); got != tt.nonCanonical {
+				t.Errorf("non-canonical order flagged = %v, want %v", got, tt.nonCanonical)
+			}
+		})
+	}
+
+	// A partially denied request reports the first denying ACE
+	sd, err := NewNtSecurityDescriptorFromSDDL("O:BAD:(A;;0x1;;;WD)(D;;0x2;;;WD)(A;;0x2;;;WD)")
+	if err != nil {
+		t.Fatalf("parsing descriptor: %v", err)
+	}
+	result := AccessCheck(&sd, token, 0x3, nil)
+	if result.Granted || result.Ace == nil || result.Ace.Header.Type != AceTypeAccessDenied {
+		t.Errorf("Expected denial by the deny ACE, got granted=%v: %s", result.Granted, result.Reason)
+	}
+	if result.Access != 0x1 {
+		t.Errorf("Access = 0x%x, want 0x1", result.Access)
+	}
+}
+
+func TestAccessCheckOwnerRights(t *testing.T) {
+	userSID := NewSIDFromStringOrPanic("S-1-5-21-1234567890-1234567890-1234567890-1001")
+	admins := NewSIDFromStringOrPanic("S-1-5-32-544")
+
+	check := func(t *testing.T, sddl string, token *TokenUser, desired uint32) *AccessCheckResult {
+		sd, err := NewNtSecurityDescriptorFromSDDL(sddl)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", sddl, err)
+		}
+		return AccessCheck(&sd, token, desired, nil)
+	}
+
+	owner := "O:" + userSID.String()
+
+	t.Run("CombinedWithACLRights", func(t *testing.T) {
+		token := NewTokenUser(userSID, nil)
+		result := check(t, owner+"D:(A;;0x1;;;WD)", token, 0x1|AccessMaskReadControl|AccessMaskWriteDACL)
+		if !result.Granted {
+			t.Errorf("Owner rights should combine with ACL rights, but got: %s", result.Reason)
+		}
+	})
+
+	t.Run("OwnerRightsSuppressesImplicitRights", func(t *testing.T) {
+		token := NewTokenUser(userSID, nil)
+		sddl := owner + "D:(A;;RC;;;OW)"
+
+		if result := check(t, sddl, token, AccessMaskReadControl); !result.Granted {
+			t.Errorf("OWNER RIGHTS ACE should grant READ_CONTROL to the owner, but got: %s", result.Reason)
+		}
+		if result := check(t, sddl, token, AccessMaskWriteDACL); result.Granted {
+			t.Errorf("OWNER RIGHTS ACE should remove the implicit WRITE_DAC")
+		}
+
+		// OWNER RIGHTS does not match anyone else
+		other := NewTokenUser(NewSIDFromStringOrPanic("S-1-5-21-1-2-3-1002"), nil)
+		if result := check(t, sddl, other, AccessMaskReadControl); result.Granted {
+			t.Errorf("OWNER RIGHTS ACE should not apply to a non-owner")
+		}
+	})
+
+	t.Run("InheritOnlyOwnerRightsIgnored", func(t *testing.T) {
+		token := NewTokenUser(userSID, nil)
+		result := check(t, owner+"D:(A;OICIIO;RC;;;OW)", token, AccessMaskWriteDACL)
+		if !result.Granted {
+			t.Errorf("Inherit-only OWNER RIGHTS ACE should keep the implicit rights, but got: %s", result.Reason)
+		}
+	})
+
+	t.Run("GroupOwner", func(t *testing.T) {
+		sddl := "O:BAD:(A;;0x1;;;WD)"
+
+		member := NewTokenUser(userSID, []SID{admins})
+		if result := check(t, sddl, member, AccessMaskWriteDACL); !result.Granted {
+			t.Errorf("Member of the owning group should get owner rights, but got: %s", result.Reason)
+		}
+
+		filtered := NewTokenUser(userSID, nil)
+		filtered.AddGroup(admins, GroupUseForDenyOnly)
+		if result := check(t, sddl, filtered, AccessMaskWriteDACL); result.Granted {
+			t.Errorf("Deny-only owning group should not get owner rights")
+		}
+	})
+
+	t.Run("MaximumAllowed", func(t *testing.T) {
+		token := NewTokenUser(userSID, nil)
+		result := check(t, owner+"D:(A;;RC;;;OW)(A;;0x1;;;WD)", token, AccessMaskMaximumAllowed)
+		if result.Access != 0x1|AccessMaskReadControl {
+			t.Errorf("Access = 0x%08X, want 0x%08X", result.Access, 0x1|AccessMaskReadControl)
+		}
+	})
+}
+
+func TestAccessCheckPrincipalSelf(t *testing.T) {
+	computerSID := NewSIDFromStringOrPanic("S-1-5-21-1-2-3-1105")
+	otherSID := NewSIDFromStringOrPanic("S-1-5-21-1-2-3-1106")
+
+	sd, err := NewNtSecurityDescriptorFromSDDL("O:DAD:(A;;WP;;;PS)")
+	if err != nil {
+		t.Fatalf("parsing descriptor: %v", err)
+	}
+
+	options := DefaultAccessCheckOptions()
+	if result := AccessCheck(&sd, NewTokenUser(computerSID, nil), ADSRightDSWriteProp, options); result.Granted {
+		t.Errorf("PRINCIPAL SELF should not match without a principal self SID")
+	}
+
+	options.PrincipalSelfSID = &computerSID
+	result := AccessCheck(&sd, NewTokenUser(computerSID, nil), ADSRightDSWriteProp, options)
+	if !result.Granted {
+		t.Errorf("PRINCIPAL SELF should match the object itself, but got: %s", result.Reason)
+	}
+
+	if result := AccessCheck(&sd, NewTokenUser(otherSID, nil), ADSRightDSWriteProp, options); result.Granted {
+		t.Errorf("PRINCIPAL SELF should not match another principal")
+	}
+
+	// A group SID as principal self matches its members
+	group := NewSIDFromStringOrPanic("S-1-5-21-1-2-3-1200")
+	options.PrincipalSelfSID = &group
+	if result := AccessCheck(&sd, NewTokenUser(otherSID, []SID{group}), ADSRightDSWriteProp, options); !result.Granted {
+		t.Errorf("PRINCIPAL SELF should match through group membership, but got: %s", result.Reason)
+	}
+}
+
+func TestAccessCheckCreatorPlaceholders(t *testing.T) {
+	userSID := NewSIDFromStringOrPanic("S-1-5-21-1234567890-1234567890-1234567890-1001")
+	token := NewTokenUser(userSID, []SID{NewSIDFromStringOrPanic("S-1-3-0")})
+
+	for _, alias := range []string{"CO", "CG"} {
+		sd, err := NewNtSecurityDescriptorFromSDDL("O:" + userSID.String() + "D:(A;;0x1;;;" + alias + ")")
+		if err != nil {
+			t.Fatalf("parsing descriptor: %v", err)
+		}
+
+		result := AccessCheck(&sd, token, 0x1, nil)
+		if result.Granted {
+			t.Errorf("%s ACE should never match a token", alias)
+		}
+
+		found := false
+		for _, detail := range result.Details {
+			if strings.Contains(detail.Description, "inheritance placeholder") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s ACE should be reported as an inheritance placeholder", alias)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package winacl
+
+import (
+	"fmt"
+)
+
+// ObjectTypeLevel is the depth of a node in an object type list
+type ObjectTypeLevel uint16
+
+// ObjectTypeLevel constants, named after their ACCESS_*_GUID values
+const (
+	ObjectTypeLevelObject      ObjectTypeLevel = 0 // The object class
+	ObjectTypeLevelPropertySet ObjectTypeLevel = 1 // A property set of the object
+	ObjectTypeLevelProperty    ObjectTypeLevel = 2 // A property, within its property set
+)
+
+// ObjectTypeNode is an entry of an object type list, as passed to
+// Windows' AccessCheckByType in an OBJECT_TYPE_LIST. A list holds the
+// object class at level 0 followed by its property sets and properties
+// in depth-first order
+//
+// https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-object_type_list
+type ObjectTypeNode struct {
+	Level ObjectTypeLevel
+	GUID  GUID
+}
+
+// ObjectTypeAccess is the outcome of an access check for a single node of
+// an object type list
+type ObjectTypeAccess struct {
+	ObjectType ObjectTypeNode
+	Granted    bool   // Whether all requested access was granted on this node
+	Access     uint32 // Access mask that was granted on this node
+	Denied     uint32 // Access mask that was explicitly denied on this node
+}
+
+// AccessCheckByTypeResult represents the result of an AccessCheckByType
+// operation
+type AccessCheckByTypeResult struct {
+	Results []ObjectTypeAccess // One result per object type list node, in order
+	Details []CheckDetails     // Detailed reasoning about the check
+}
+
+// Granted reports whether all requested access was granted on the object
+// itself, the root of the object type list
+func (r AccessCheckByTypeResult) Granted() bool {
+	return len(r.Results) > 0 && r.Results[0].Granted
+}
+
+// objectTypeTree holds the per-node state of an AccessCheckByType run
+type objectTypeTree struct {
+	nodes     []ObjectTypeNode
+	parents   []int // Index of each node's parent, -1 for the root
+	remaining []uint32
+	granted   []uint32
+	denied    []uint32
+
+	// Under MAXIMUM_ALLOWED a node is granted when it holds any right and
+	// every right in required
+	maximumAllowed bool
+	required       uint32
+}
+
+func newObjectTypeTree(nodes []ObjectTypeNode, desiredAccess uint32) (*objectTypeTree, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("object type list is empty")
+	}
+	if nodes[0].Level != ObjectTypeLevelObject {
+		return nil, fmt.Errorf("object type list must start with a level %d node", ObjectTypeLevelObject)
+	}
+
+	tree := &objectTypeTree{
+		nodes:     nodes,
+		parents:   make([]int, len(nodes)),
+		remaining: make([]uint32, len(nodes)),
+		granted:   make([]uint32, len(nodes)),
+		denied:    make([]uint32, len(nodes)),
+	}
+
+	tree.parents[0] = -1
+	for i := range nodes {
+		tree.remaining[i] = desiredAccess
+		if i == 0 {
+			continue
+		}
+
+		level := nodes[i].Level
+		switch {
+		case level == ObjectTypeLevelObject:
+			return nil, fmt.Errorf("object type list node %d: only the first node may be level %d", i, ObjectTypeLevelObject)
+		case level > ObjectTypeLevelProperty:
+			return nil, fmt.Errorf("object type list node %d: invalid level %d", i, level)
+		case level > nodes[i-1].Level+1:
+			return nil, fmt.Errorf("object type list node %d: level %d follows level %d", i, level, nodes[i-1].Level)
+		}
+
+		parent := i - 1
+		for nodes[parent].Level >= level {
+			parent = tree.parents[parent]
+		}
+		tree.parents[i] = parent
+	}
+	return tree, nil
+}
+
+// find returns the index of the node with the given GUID, or -1
+func (t *objectTypeTree) find(guid GUID) int {
+	for i, node := range t.nodes {
+		if node.GUID == guid {
+			return i
+		}
+	}
+	return -1
+}
+
+// subtree returns the index just past the last descendant of node n
+func (t *objectTypeTree) subtree(n int) int {
+	end := n + 1
+	for end < len(t.nodes) && t.nodes[end].Level > t.nodes[n].Level {
+		end++
+	}
+	return end
+}
+
+// grant grants mask to node n and its descendants. An ancestor is only
+// granted the access that all of its children have been granted
+func (t *objectTypeTree) grant(n int, mask uint32) {
+	for i := n; i < t.subtree(n); i++ {
+		t.granted[i] |= t.remaining[i] & mask
+		t.remaining[i] &^= mask
+	}
+
+	for parent := t.parents[n]; parent >= 0; parent = t.parents[parent] {
+		childRemaining := uint32(0)
+		for i := parent + 1; i < t.subtree(parent); i++ {
+			if t.parents[i] == parent {
+				childRemaining |= t.remaining[i]
+			}
+		}
+		t.granted[parent] |= t.remaining[parent] &^ childRemaining
+		t.remaining[parent] &= childRemaining
+	}
+}
+
+// deny denies mask to node n, its descendants and its ancestors, since
+// access denied on a part of the object is denied on the whole
+func (t *objectTypeTree) deny(n int, mask uint32) {
+	for i := n; i < t.subtree(n); i++ {
+		t.denied[i] |= t.remaining[i] & mask
+		t.remaining[i] &^= mask
+	}
+
+	for parent := t.parents[n]; parent >= 0; parent = t.parents[parent] {
+		t.denied[parent] |= t.remaining[parent] & mask
+		t.remaining[parent] &^= mask
+	}
+}
+
+// revoke takes mask away from every node, leaving it ungranted
+func (t *objectTypeTree) revoke(mask uint32) {
+	for i := range t.nodes {
+		t.remaining[i] |= t.granted[i] & mask
+		t.granted[i] &^= mask
+	}
+}
+
+// grantedAnywhere returns the access granted to any node
+func (t *objectTypeTree) grantedAnywhere() uint32 {
+	granted := uint32(0)
+	for _, mask := range t.granted {
+		granted |= mask
+	}
+	return granted
+}
+
+// AccessCheckByType simulates the Windows AccessCheckByType algorithm,
+// checking access to an object and to each of the property sets and
+// properties in objectTypes
+//
+// ACEs are processed in DACL order and each access right is decided by the
+// first ACE that allows or denies it. Object ACEs apply to the node
+// matching their ObjectType and its descendants, or to the whole object
+// when they have none. Object ACEs whose InheritedObjectType does not
+// match the object class at the root of the list do not apply. Inherit-only
+// ACEs are skipped.
+//
+// When desiredAccess includes MAXIMUM_ALLOWED, each node is granted every
+// right the token holds on it. Restricted and AppContainer tokens are
+// checked again for their restricting, package and capability SIDs, as in
+// AccessCheck, and each node keeps only the access every check grants it
+//
+// https://docs.microsoft.com/en-us/windows/win32/api/securitybaseapi/nf-securitybaseapi-accesscheckbytype
+func AccessCheckByType(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
+	desiredAccess uint32, objectTypes []ObjectTypeNode, options *AccessCheckOptions,
+) (*AccessCheckByTypeResult, error) {
+	options = resolveAccessCheckOptions(securityDescriptor, options)
+	result := &AccessCheckByTypeResult{Details: make([]CheckDetails, 0)}
+
+	tree, err := objectTypeAccessCheck(securityDescriptor, token, desiredAccess, objectTypes, options, result)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.IsRestricted() {
+		restricted := ^uint32(0)
+		if token.WriteRestricted {
+			restricted = restrictedWriteAccess(options)
+		}
+		err = tree.intersectPass(securityDescriptor, token, tokenPassRestricted, restricted, desiredAccess, options, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if token.IsAppContainer() {
+		err = tree.intersectPass(securityDescriptor, token, tokenPassAppContainer, ^uint32(0), desiredAccess, options, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tree.result(result), nil
+}
+
+// objectTypeAccessCheck runs a single AccessCheckByType pass for the
+// token's SIDs, returning the access decided for each node
+func objectTypeAccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
+	desiredAccess uint32, objectTypes []ObjectTypeNode, options *AccessCheckOptions,
+	result *AccessCheckByTypeResult,
+) (*objectTypeTree, error) {
+	token = token.forObject(securityDescriptor)
+	mappedAccess := MapGenericAccess(desiredAccess, options.GenericMapping)
+	maximumAllowed := desiredAccess&AccessMaskMaximumAllowed != 0
+
+	// MAXIMUM_ALLOWED asks for every right, as long as the other requested
+	// rights are among them
+	required := mappedAccess &^ AccessMaskMaximumAllowed
+	requested, privilegeMask := mappedAccess, mappedAccess
+	if maximumAllowed {
+		requested = required | maximumAllowedRights(options)
+		privilegeMask = ^uint32(maximumAllowedIgnoredRights) | (mappedAccess & AccessMaskSystemSecurity)
+	}
+
+	tree, err := newObjectTypeTree(objectTypes, requested)
+	if err != nil {
+		return nil, err
+	}
+	tree.maximumAllowed, tree.required = maximumAllowed, required
+
+	// Under MAXIMUM_ALLOWED the integrity policy removes rights at the end
+	if options.CheckIntegrity && !maximumAllowed {
+		detail := checkIntegrity(securityDescriptor, token, options, mappedAccess)
+		result.Details = append(result.Details, detail)
+
+		if !detail.Outcome {
+			tree.deny(0, mappedAccess)
+			return tree, nil
+		}
+	}
+
+	// Privileges grant access to the whole object regardless of the DACL
+	grants := privilegeGrants(token, options, privilegeMask)
+	result.Details = append(result.Details, privilegeDetails(grants)...)
+
+	// Only an explicit request needs the privilege; generic mappings that
+	// cover every bit are not treated as asking for ACCESS_SYSTEM_SECURITY
+	if desiredAccess&AccessMaskSystemSecurity != 0 {
+		detail := checkSecurityPrivilege(grants, mappedAccess)
+		result.Details = append(result.Details, detail)
+
+		if !detail.Outcome {
+			tree.deny(0, mappedAccess)
+			return tree, nil
+		}
+	}
+	tree.grant(0, privilegeAccess(grants))
+
+	switch securityDescriptor.DACLState() {
+	case ACLStateAbsent, ACLStateNull:
+		result.Details = append(result.Details, CheckDetails{
+			Step:        "EmptyDACL",
+			Description: "No DACL or NULL DACL present; full access granted",
+			Outcome:     true,
+		})
+		tree.grant(0, requested)
+
+	default:
+		tree.checkDACL(securityDescriptor, token, options, result)
+	}
+
+	if options.CheckIntegrity && maximumAllowed {
+		blocked := uint32(0)
+		for _, right := range accessRights(tree.grantedAnywhere()) {
+			if detail := checkIntegrity(securityDescriptor, token, options, right); !detail.Outcome {
+				blocked |= right
+				result.Details = append(result.Details, detail)
+			}
+		}
+		tree.revoke(blocked)
+	}
+	return tree, nil
+}
+
+// checkDACL grants and denies each node the access the DACL's ACEs give it
+func (t *objectTypeTree) checkDACL(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
+	options *AccessCheckOptions, result *AccessCheckByTypeResult,
+) {
+	// The owner is implicitly granted READ_CONTROL and WRITE_DAC
+	if ownerRights, reason := token.implicitOwnerRights(securityDescriptor); token.objectOwner {
+		t.grant(0, ownerRights)
+		result.Details = append(result.Details, CheckDetails{
+			Step:        "OwnerRights",
+			Description: fmt.Sprintf("%s; granted access mask 0x%08X", reason, t.granted[0]&ownerRights),
+			Outcome:     ownerRights != 0,
+		})
+	}
+
+	for i, ace := range securityDescriptor.DACL.Aces {
+		allow := isAllowAceType(ace.Header.Type)
+		if !allow && !isDenyAceType(ace.Header.Type) {
+			continue
+		}
+		if ace.Header.Flags&ACEHeaderFlagsInheritOnlyAce != 0 {
+			continue
+		}
+
+		node, reason := t.aceNode(ace)
+		if node >= 0 {
+			var applies bool
+			applies, reason = aceAppliesToToken(ace, token, options)
+			if !applies {
+				node = -1
+			}
+		}
+
+		result.Details = append(result.Details, CheckDetails{
+			Step:        fmt.Sprintf("ACE[%d]", i),
+			Description: fmt.Sprintf("Checking if %s ACE applies: %v - %s", ace.GetTypeString(), node >= 0, reason),
+			Outcome:     node >= 0,
+		})
+		if node < 0 {
+			continue
+		}
+
+		aceMappedAccess := MapGenericAccess(ace.AccessMask.Raw(), options.GenericMapping)
+		if allow {
+			t.grant(node, aceMappedAccess)
+		} else {
+			t.deny(node, aceMappedAccess)
+		}
+	}
+}
+
+// intersectPass checks the rights in restricted again for the SIDs of
+// another pass, and takes those it does not grant a node away from it
+func (t *objectTypeTree) intersectPass(securityDescriptor *NtSecurityDescriptor, token *TokenUser, pass tokenPass,
+	restricted uint32, desiredAccess uint32, options *AccessCheckOptions, result *AccessCheckByTypeResult,
+) error {
+	info := tokenPassInfo[pass]
+	passDesired := MapGenericAccess(desiredAccess, options.GenericMapping) & restricted
+	if t.maximumAllowed {
+		passDesired |= AccessMaskMaximumAllowed
+	}
+	if passDesired == 0 {
+		result.Details = append(result.Details, CheckDetails{
+			Step:        info.step + "SIDs",
+			Description: fmt.Sprintf("No requested access applies to %s SIDs; not checked", pass),
+			Outcome:     true,
+		})
+		return nil
+	}
+
+	passResult := &AccessCheckByTypeResult{}
+	second, err := objectTypeAccessCheck(securityDescriptor, token.view(pass), passDesired, t.nodes, options, passResult)
+	if err != nil {
+		return fmt.Errorf("checking %s SIDs: %w", pass, err)
+	}
+
+	result.Details = append(result.Details, CheckDetails{
+		Step:        info.step + "SIDs",
+		Description: fmt.Sprintf("Checking access mask 0x%08X against %s SIDs", passDesired, pass),
+		Outcome:     second.remaining[0] == 0 && second.denied[0] == 0,
+	})
+	for _, detail := range passResult.Details {
+		detail.Step = info.step + detail.Step
+		result.Details = append(result.Details, detail)
+	}
+
+	for i := range t.nodes {
+		removed := t.granted[i] & restricted &^ second.granted[i]
+		t.granted[i] &^= removed
+		t.remaining[i] |= removed
+	}
+	return nil
+}
+
+// aceNode returns the index of the node an ACE applies to, or -1 when it
+// applies to none of them
+func (t *objectTypeTree) aceNode(ace ACE) (int, string) {
+	aa, ok := ace.ObjectAce.(AdvancedAce)
+	if !ok {
+		return 0, "Applies to the whole object"
+	}
+
+	if aa.Flags&ACEInheritanceFlagsInheritedObjectTypePresent != 0 && aa.InheritedObjectType != t.nodes[0].GUID {
+		return -1, fmt.Sprintf("Inherited object type %s does not match the object class", aa.InheritedObjectType.Resolve())
+	}
+
+	if aa.Flags&ACEInheritanceFlagsObjectTypePresent == 0 {
+		return 0, "Applies to the whole object"
+	}
+
+	node := t.find(aa.ObjectType)
+	if node < 0 {
+		return -1, fmt.Sprintf("Object type %s is not in the object type list", aa.ObjectType.Resolve())
+	}
+	return node, fmt.Sprintf("Applies to object type %s", aa.ObjectType.Resolve())
+}
+
+// result records the per-node outcome
+func (t *objectTypeTree) result(result *AccessCheckByTypeResult) *AccessCheckByTypeResult {
+	result.Results = make([]ObjectTypeAccess, len(t.nodes))
+	for i, node := range t.nodes {
+		granted := t.remaining[i] == 0 && t.denied[i] == 0
+		if t.maximumAllowed {
+			granted = t.granted[i] != 0 && t.granted[i]&t.required == t.required
+		}
+		result.Results[i] = ObjectTypeAccess{
+			ObjectType: node,
+			Granted:    granted,
+			Access:     t.granted[i],
+			Denied:     t.denied[i],
+		}
+	}
+	return result
+}
+
+// hasObjectType reports whether an object ACE only applies to the part of
+// the object named by its ObjectType. AccessCheck leaves such ACEs to
+// AccessCheckByType
+func hasObjectType(ace ACE) bool {
+	aa, ok := ace.ObjectAce.(AdvancedAce)
+	return ok && aa.Flags&ACEInheritanceFlagsObjectTypePresent != 0
+}
+
+// isAllowAceType reports whether ACEs of this type grant access
+func isAllowAceType(aceType AceType) bool {
+	switch aceType {
+	case AceTypeAccessAllowed, AceTypeAccessAllowedCallback,
+		AceTypeAccessAllowedObject, AceTypeAccessAllowedCallbackObject:
+		return true
+	}
+	return false
+}
+
+// isDenyAceType reports whether ACEs of this type deny access
+func isDenyAceType(aceType AceType) bool {
+	switch aceType {
+	case AceTypeAccessDenied, AceTypeAccessDeniedCallback,
+		AceTypeAccessDeniedObject, AceTypeAccessDeniedCallbackObject:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessCheckByType(t *testing.T) {
+	r := require.New(t)
+
+	guid := func(s string) winacl.GUID {
+		g, err := winacl.NewGUIDFromString(s)
+		r.NoError(err)
+		return g
+	}
+
+	userClass := guid("bf967aba-0de6-11d0-a285-00aa003049e2")
+	computerClass := guid("bf967a86-0de6-11d0-a285-00aa003049e2")
+	personalInfo := guid("77b5b886-944a-11d1-aebd-0000f80367c1")
+	telephone := guid("bf967a49-0de6-11d0-a285-00aa003049e2")
+	streetAddress := guid("bf967a3a-0de6-11d0-a285-00aa003049e2")
+	publicInfo := guid("e48d0154-bcf8-11d1-8702-00c04fb96050")
+	mail := guid("bf967961-0de6-11d0-a285-00aa003049e2")
+
+	objectTypes := []winacl.ObjectTypeNode{
+		{Level: winacl.ObjectTypeLevelObject, GUID: userClass},
+		{Level: winacl.ObjectTypeLevelPropertySet, GUID: personalInfo},
+		{Level: winacl.ObjectTypeLevelProperty, GUID: telephone},
+		{Level: winacl.ObjectTypeLevelProperty, GUID: streetAddress},
+		{Level: winacl.ObjectTypeLevelPropertySet, GUID: publicInfo},
+		{Level: winacl.ObjectTypeLevelProperty, GUID: mail},
+	}
+
+	token := winacl.NewTokenUser(mustSID(t, "S-1-5-21-1-2-3-1001"), nil)
+
+	check := func(sddl string, desired uint32) *winacl.AccessCheckByTypeResult {
+		result, err := winacl.AccessCheckByType(mustParseSDDL(t, sddl), token, desired, objectTypes, nil)
+		r.NoError(err)
+		r.Len(result.Results, len(objectTypes))
+		return result
+	}
+
+	granted := func(result *winacl.AccessCheckByTypeResult) []bool {
+		out := make([]bool, len(result.Results))
+		for i, res := range result.Results {
+			out[i] = res.Granted
+		}
+		return out
+	}
+
+	t.Run("Property set grants flow down but not up", func(t *testing.T) {
+		result := check("O:BAD:(OA;;WP;"+personalInfo.String()+";;WD)", winacl.ADSRightDSWriteProp)
+		r.Equal([]bool{false, true, true, true, false, false}, granted(result))
+		r.False(result.Granted())
+		r.Equal(uint32(winacl.ADSRightDSWriteProp), result.Results[2].Access)
+	})
+
+	t.Run("Parents are granted once all children are", func(t *testing.T) {
+		result := check("O:BAD:(OA;;WP;"+personalInfo.String()+";;WD)(OA;;WP;"+mail.String()+";;WD)", winacl.ADSRightDSWriteProp)
+		r.Equal([]bool{true, true, true, true, true, true}, granted(result))
+		r.True(result.Granted())
+	})
+
+	t.Run("Denied properties deny their ancestors but not siblings", func(t *testing.T) {
+		result := check("O:BAD:(OD;;WP;"+telephone.String()+";;WD)(A;;RPWP;;;WD)", winacl.ADSRightDSWriteProp)
+		r.Equal([]bool{false, false, false, true, true, true}, granted(result))
+		r.Equal(uint32(winacl.ADSRightDSWriteProp), result.Results[0].Denied)
+	})
+
+	t.Run("The first ACE to decide a right wins", func(t *testing.T) {
+		result := check("O:BAD:(A;;WP;;;WD)(OD;;WP;"+telephone.String()+";;WD)", winacl.ADSRightDSWriteProp)
+		r.True(result.Granted())
+	})
+
+	t.Run("Inherited object type must match the object class", func(t *testing.T) {
+		result := check("O:BAD:(OA;;WP;;"+computerClass.String()+";WD)", winacl.ADSRightDSWriteProp)
+		r.False(result.Granted())
+
+		result = check("O:BAD:(OA;;WP;;"+userClass.String()+";WD)", winacl.ADSRightDSWriteProp)
+		r.True(result.Granted())
+	})
+
+	t.Run("Unknown object types and inherit-only ACEs are ignored", func(t *testing.T) {
+		result := check("O:BAD:(OA;;WP;"+computerClass.String()+";;WD)(A;IO;WP;;;WD)", winacl.ADSRightDSWriteProp)
+		r.Equal([]bool{false, false, false, false, false, false}, granted(result))
+	})
+
+	t.Run("Control access rights on an extended right", func(t *testing.T) {
+		resetPassword := guid("00299570-246d-11d0-a768-00aa006e0529")
+		ntsd := mustParseSDDL(t, "O:BAD:(OA;;CR;"+resetPassword.String()+";;WD)")
+
+		result, err := winacl.AccessCheckByType(ntsd, token, winacl.ADSRightDSControlAccess, []winacl.ObjectTypeNode{
+			{Level: winacl.ObjectTypeLevelObject, GUID: userClass},
+			{Level: winacl.ObjectTypeLevelPropertySet, GUID: resetPassword},
+		}, nil)
+		r.NoError(err)
+		r.True(result.Granted())
+	})
+
+	t.Run("Restricted tokens only keep access their restricting SIDs grant", func(t *testing.T) {
+		restricted := winacl.NewTokenUser(token.UserSID, nil)
+		restricted.RestrictedSIDs = []winacl.SID{mustSID(t, "S-1-5-12")}
+
+		sddl := "O:BAD:(OA;;WP;" + personalInfo.String() + ";;WD)(OA;;WP;" + telephone.String() + ";;RC)"
+		result, err := winacl.AccessCheckByType(mustParseSDDL(t, sddl), restricted, winacl.ADSRightDSWriteProp, objectTypes, nil)
+		r.NoError(err)
+		r.Equal([]bool{false, false, true, false, false, false}, granted(result))
+		r.Zero(result.Results[1].Access)
+	})
+
+	t.Run("AppContainer tokens only keep access their package SIDs grant", func(t *testing.T) {
+		app := winacl.NewTokenUser(token.UserSID, nil)
+		packageSID := mustSID(t, "S-1-15-2-1-2-3-4-5-6-7")
+		app.AppContainerSID = &packageSID
+
+		sddl := "O:BAD:(OA;;WP;" + personalInfo.String() + ";;WD)(OA;;WP;" + telephone.String() + ";;AC)"
+		result, err := winacl.AccessCheckByType(mustParseSDDL(t, sddl), app, winacl.ADSRightDSWriteProp, objectTypes, nil)
+		r.NoError(err)
+		r.Equal([]bool{false, false, true, false, false, false}, granted(result))
+
+		// As with AccessCheck, Everyone alone does not grant an AppContainer access
+		everyone := mustParseSDDL(t, "O:BAD:(A;;WP;;;WD)")
+		r.False(winacl.AccessCheck(everyone, app, winacl.ADSRightDSWriteProp, nil).Granted)
+		result, err = winacl.AccessCheckByType(everyone, app, winacl.ADSRightDSWriteProp, objectTypes, nil)
+		r.NoError(err)
+		r.False(result.Granted())
+	})
+
+	t.Run("MAXIMUM_ALLOWED grants each node every right it holds", func(t *testing.T) {
+		sddl := "O:BAD:(OA;;WP;" + personalInfo.String() + ";;WD)(A;;RP;;;WD)"
+		result := check(sddl, winacl.AccessMaskMaximumAllowed)
+		r.Equal([]bool{true, true, true, true, true, true}, granted(result))
+		r.Equal(uint32(winacl.ADSRightDSReadProp), result.Results[0].Access)
+		r.Equal(uint32(winacl.ADSRightDSReadProp|winacl.ADSRightDSWriteProp), result.Results[1].Access)
+
+		result = check(sddl, winacl.AccessMaskMaximumAllowed|winacl.ADSRightDSWriteProp)
+		r.Equal([]bool{false, true, true, true, false, false}, granted(result))
+	})
+
+	t.Run("Rejects malformed object type lists", func(t *testing.T) {
+		ntsd := mustParseSDDL(t, "O:BAD:(A;;WP;;;WD)")
+
+		for _, list := range [][]winacl.ObjectTypeNode{
+			{},
+			{{Level: winacl.ObjectTypeLevelPropertySet, GUID: personalInfo}},
+			{{Level: winacl.ObjectTypeLevelObject, GUID: userClass}, {Level: winacl.ObjectTypeLevelProperty, GUID: telephone}},
+			{{Level: winacl.ObjectTypeLevelObject, GUID: userClass}, {Level: winacl.ObjectTypeLevelObject, GUID: computerClass}},
+		} {
+			_, err := winacl.AccessCheckByType(ntsd, token, winacl.ADSRightDSWriteProp, list, nil)
+			r.Error(err)
+		}
+	})
+}
@@ -0,0 +1,336 @@
+package winacl
+
+import (
+	"fmt"
+
+	"github.com/audibleblink/bamflags"
+)
+
+// Access mask constants for the specific rights of files, directories and
+// named pipes
+//
+// https://docs.microsoft.com/en-us/windows/win32/fileio/file-access-rights-constants
+const (
+	FileReadData           = 0x00000001
+	FileListDirectory      = 0x00000001
+	FileWriteData          = 0x00000002
+	FileAddFile            = 0x00000002
+	FileAppendData         = 0x00000004
+	FileAddSubdirectory    = 0x00000004
+	FileCreatePipeInstance = 0x00000004
+	FileReadEA             = 0x00000008
+	FileWriteEA            = 0x00000010
+	FileExecute            = 0x00000020
+	FileTraverse           = 0x00000020
+	FileDeleteChild        = 0x00000040
+	FileReadAttributes     = 0x00000080
+	FileWriteAttributes    = 0x00000100
+	FileAllAccess          = 0x001F01FF
+	FileGenericRead        = 0x00120089
+	FileGenericWrite       = 0x00120116
+	FileGenericExecute     = 0x001200A0
+)
+
+// Access mask constants for the specific rights of registry keys
+//
+// https://docs.microsoft.com/en-us/windows/win32/sysinfo/registry-key-security-and-access-rights
+const (
+	KeyQueryValue       = 0x00000001
+	KeySetValue         = 0x00000002
+	KeyCreateSubKey     = 0x00000004
+	KeyEnumerateSubKeys = 0x00000008
+	KeyNotify           = 0x00000010
+	KeyCreateLink       = 0x00000020
+	KeyWow6464Key       = 0x00000100
+	KeyWow6432Key       = 0x00000200
+	KeyAllAccess        = 0x000F003F
+	KeyRead             = 0x00020019
+	KeyWrite            = 0x00020006
+	KeyExecute          = 0x00020019
+)
+
+// Access mask constants for the specific rights of services and the
+// service control manager
+//
+// https://docs.microsoft.com/en-us/windows/win32/services/service-security-and-access-rights
+const (
+	ServiceQueryConfig         = 0x00000001
+	ServiceChangeConfig        = 0x00000002
+	ServiceQueryStatus         = 0x00000004
+	ServiceEnumerateDependents = 0x00000008
+	ServiceStart               = 0x00000010
+	ServiceStop                = 0x00000020
+	ServicePauseContinue       = 0x00000040
+	ServiceInterrogate         = 0x00000080
+	ServiceUserDefinedControl  = 0x00000100
+	ServiceAllAccess           = 0x000F01FF
+
+	SCManagerConnect          = 0x00000001
+	SCManagerCreateService    = 0x00000002
+	SCManagerEnumerateService = 0x00000004
+	SCManagerLock             = 0x00000008
+	SCManagerQueryLockStatus  = 0x00000010
+	SCManagerModifyBootConfig = 0x00000020
+	SCManagerAllAccess        = 0x000F003F
+)
+
+// Access mask constants for the specific rights of processes and threads
+//
+// https://docs.microsoft.com/en-us/windows/win32/procthread/process-security-and-access-rights
+const (
+	ProcessTerminate               = 0x00000001
+	ProcessCreateThread            = 0x00000002
+	ProcessSetSessionID            = 0x00000004
+	ProcessVMOperation             = 0x00000008
+	ProcessVMRead                  = 0x00000010
+	ProcessVMWrite                 = 0x00000020
+	ProcessDupHandle               = 0x00000040
+	ProcessCreateProcess           = 0x00000080
+	ProcessSetQuota                = 0x00000100
+	ProcessSetInformation          = 0x00000200
+	ProcessQueryInformation        = 0x00000400
+	ProcessSuspendResume           = 0x00000800
+	ProcessQueryLimitedInformation = 0x00001000
+	ProcessSetLimitedInformation   = 0x00002000
+	ProcessAllAccess               = 0x001FFFFF
+
+	ThreadTerminate               = 0x00000001
+	ThreadSuspendResume           = 0x00000002
+	ThreadGetContext              = 0x00000008
+	ThreadSetContext              = 0x00000010
+	ThreadSetInformation          = 0x00000020
+	ThreadQueryInformation        = 0x00000040
+	ThreadSetThreadToken          = 0x00000080
+	ThreadImpersonate             = 0x00000100
+	ThreadDirectImpersonation     = 0x00000200
+	ThreadSetLimitedInformation   = 0x00000400
+	ThreadQueryLimitedInformation = 0x00000800
+	ThreadResume                  = 0x00001000
+	ThreadAllAccess               = 0x001FFFFF
+)
+
+// Access mask constants for the specific rights of access tokens
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/access-rights-for-access-token-objects
+const (
+	TokenAssignPrimary    = 0x00000001
+	TokenDuplicate        = 0x00000002
+	TokenImpersonate      = 0x00000004
+	TokenQuery            = 0x00000008
+	TokenQuerySource      = 0x00000010
+	TokenAdjustPrivileges = 0x00000020
+	TokenAdjustGroups     = 0x00000040
+	TokenAdjustDefault    = 0x00000080
+	TokenAdjustSessionID  = 0x00000100
+	TokenAllAccess        = 0x000F01FF
+	TokenRead             = 0x00020008
+	TokenWrite            = 0x000200E0
+)
+
+// Access mask constants for the composite rights of directory service
+// objects
+const (
+	DSGenericRead  = 0x00020094
+	DSGenericWrite = 0x00020028
+	DSGenericAll   = 0x000F01FF
+)
+
+// CompositeRight is an access mask that Windows names as a whole, such as
+// FILE_ALL_ACCESS or KEY_READ
+type CompositeRight struct {
+	Mask uint32
+	Name string
+}
+
+var fileRights = map[uint32]string{
+	FileReadData:        "FILE_READ_DATA",
+	FileWriteData:       "FILE_WRITE_DATA",
+	FileAppendData:      "FILE_APPEND_DATA",
+	FileReadEA:          "FILE_READ_EA",
+	FileWriteEA:         "FILE_WRITE_EA",
+	FileExecute:         "FILE_EXECUTE",
+	FileDeleteChild:     "FILE_DELETE_CHILD",
+	FileReadAttributes:  "FILE_READ_ATTRIBUTES",
+	FileWriteAttributes: "FILE_WRITE_ATTRIBUTES",
+}
+
+var directoryRights = map[uint32]string{
+	FileListDirectory:   "FILE_LIST_DIRECTORY",
+	FileAddFile:         "FILE_ADD_FILE",
+	FileAddSubdirectory: "FILE_ADD_SUBDIRECTORY",
+	FileReadEA:          "FILE_READ_EA",
+	FileWriteEA:         "FILE_WRITE_EA",
+	FileTraverse:        "FILE_TRAVERSE",
+	FileDeleteChild:     "FILE_DELETE_CHILD",
+	FileReadAttributes:  "FILE_READ_ATTRIBUTES",
+	FileWriteAttributes: "FILE_WRITE_ATTRIBUTES",
+}
+
+var namedPipeRights = map[uint32]string{
+	FileReadData:           "FILE_READ_DATA",
+	FileWriteData:          "FILE_WRITE_DATA",
+	FileCreatePipeInstance: "FILE_CREATE_PIPE_INSTANCE",
+	FileReadEA:             "FILE_READ_EA",
+	FileWriteEA:            "FILE_WRITE_EA",
+	FileExecute:            "FILE_EXECUTE",
+	FileDeleteChild:        "FILE_DELETE_CHILD",
+	FileReadAttributes:     "FILE_READ_ATTRIBUTES",
+	FileWriteAttributes:    "FILE_WRITE_ATTRIBUTES",
+}
+
+var fileCompositeRights = []CompositeRight{
+	{FileAllAccess, "FILE_ALL_ACCESS"},
+	{FileGenericRead, "FILE_GENERIC_READ"},
+	{FileGenericWrite, "FILE_GENERIC_WRITE"},
+	{FileGenericExecute, "FILE_GENERIC_EXECUTE"},
+}
+
+// SpecificRightsLookup maps the object-specific bits (0x0000FFFF) of each
+// object type's access masks to human-readable strings
+var SpecificRightsLookup = map[SecurableObjectType]map[uint32]string{
+	SecurableFile:      fileRights,
+	SecurableDirectory: directoryRights,
+	SecurableNamedPipe: namedPipeRights,
+	SecurableRegistryKey: {
+		KeyQueryValue:       "KEY_QUERY_VALUE",
+		KeySetValue:         "KEY_SET_VALUE",
+		KeyCreateSubKey:     "KEY_CREATE_SUB_KEY",
+		KeyEnumerateSubKeys: "KEY_ENUMERATE_SUB_KEYS",
+		KeyNotify:           "KEY_NOTIFY",
+		KeyCreateLink:       "KEY_CREATE_LINK",
+		KeyWow6464Key:       "KEY_WOW64_64KEY",
+		KeyWow6432Key:       "KEY_WOW64_32KEY",
+	},
+	SecurableService: {
+		ServiceQueryConfig:         "SERVICE_QUERY_CONFIG",
+		ServiceChangeConfig:        "SERVICE_CHANGE_CONFIG",
+		ServiceQueryStatus:         "SERVICE_QUERY_STATUS",
+		ServiceEnumerateDependents: "SERVICE_ENUMERATE_DEPENDENTS",
+		ServiceStart:               "SERVICE_START",
+		ServiceStop:                "SERVICE_STOP",
+		ServicePauseContinue:       "SERVICE_PAUSE_CONTINUE",
+		ServiceInterrogate:         "SERVICE_INTERROGATE",
+		ServiceUserDefinedControl:  "SERVICE_USER_DEFINED_CONTROL",
+	},
+	SecurableServiceControlManager: {
+		SCManagerConnect:          "SC_MANAGER_CONNECT",
+		SCManagerCreateService:    "SC_MANAGER_CREATE_SERVICE",
+		SCManagerEnumerateService: "SC_MANAGER_ENUMERATE_SERVICE",
+		SCManagerLock:             "SC_MANAGER_LOCK",
+		SCManagerQueryLockStatus:  "SC_MANAGER_QUERY_LOCK_STATUS",
+		SCManagerModifyBootConfig: "SC_MANAGER_MODIFY_BOOT_CONFIG",
+	},
+	SecurableProcess: {
+		ProcessTerminate:               "PROCESS_TERMINATE",
+		ProcessCreateThread:            "PROCESS_CREATE_THREAD",
+		ProcessSetSessionID:            "PROCESS_SET_SESSIONID",
+		ProcessVMOperation:             "PROCESS_VM_OPERATION",
+		ProcessVMRead:                  "PROCESS_VM_READ",
+		ProcessVMWrite:                 "PROCESS_VM_WRITE",
+		ProcessDupHandle:               "PROCESS_DUP_HANDLE",
+		ProcessCreateProcess:           "PROCESS_CREATE_PROCESS",
+		ProcessSetQuota:                "PROCESS_SET_QUOTA",
+		ProcessSetInformation:          "PROCESS_SET_INFORMATION",
+		ProcessQueryInformation:        "PROCESS_QUERY_INFORMATION",
+		ProcessSuspendResume:           "PROCESS_SUSPEND_RESUME",
+		ProcessQueryLimitedInformation: "PROCESS_QUERY_LIMITED_INFORMATION",
+		ProcessSetLimitedInformation:   "PROCESS_SET_LIMITED_INFORMATION",
+	},
+	SecurableThread: {
+		ThreadTerminate:               "THREAD_TERMINATE",
+		ThreadSuspendResume:           "THREAD_SUSPEND_RESUME",
+		ThreadGetContext:              "THREAD_GET_CONTEXT",
+		ThreadSetContext:              "THREAD_SET_CONTEXT",
+		ThreadSetInformation:          "THREAD_SET_INFORMATION",
+		ThreadQueryInformation:        "THREAD_QUERY_INFORMATION",
+		ThreadSetThreadToken:          "THREAD_SET_THREAD_TOKEN",
+		ThreadImpersonate:             "THREAD_IMPERSONATE",
+		ThreadDirectImpersonation:     "THREAD_DIRECT_IMPERSONATION",
+		ThreadSetLimitedInformation:   "THREAD_SET_LIMITED_INFORMATION",
+		ThreadQueryLimitedInformation: "THREAD_QUERY_LIMITED_INFORMATION",
+		ThreadResume:                  "THREAD_RESUME",
+	},
+	SecurableToken: {
+		TokenAssignPrimary:    "TOKEN_ASSIGN_PRIMARY",
+		TokenDuplicate:        "TOKEN_DUPLICATE",
+		TokenImpersonate:      "TOKEN_IMPERSONATE",
+		TokenQuery:            "TOKEN_QUERY",
+		TokenQuerySource:      "TOKEN_QUERY_SOURCE",
+		TokenAdjustPrivileges: "TOKEN_ADJUST_PRIVILEGES",
+		TokenAdjustGroups:     "TOKEN_ADJUST_GROUPS",
+		TokenAdjustDefault:    "TOKEN_ADJUST_DEFAULT",
+		TokenAdjustSessionID:  "TOKEN_ADJUST_SESSIONID",
+	},
+	SecurableDSObject: {
+		ADSRightDSCreateChild:   "CREATE_CHILD",
+		ADSRightDSDeleteChild:   "DELETE_CHILD",
+		ADSRightDSListChildrend: "LIST_CHILDREN",
+		ADSRightDSSelf:          "SELF",
+		ADSRightDSReadProp:      "READ_PROP",
+		ADSRightDSWriteProp:     "WRITE_PROP",
+		ADSRightDSDeleteTree:    "DELETE_TREE",
+		ADSRightDSListObject:    "LIST_OBJECT",
+		ADSRightDSControlAccess: "CONTROL_ACCESS",
+	},
+}
+
+// CompositeRightsLookup lists the composite rights of each object type,
+// broadest first
+var CompositeRightsLookup = map[SecurableObjectType][]CompositeRight{
+	SecurableFile:      fileCompositeRights,
+	SecurableDirectory: fileCompositeRights,
+	SecurableNamedPipe: fileCompositeRights,
+	SecurableRegistryKey: {
+		{KeyAllAccess, "KEY_ALL_ACCESS"},
+		{KeyRead, "KEY_READ"},
+		{KeyWrite, "KEY_WRITE"},
+	},
+	SecurableService:               {{ServiceAllAccess, "SERVICE_ALL_ACCESS"}},
+	SecurableServiceControlManager: {{SCManagerAllAccess, "SC_MANAGER_ALL_ACCESS"}},
+	SecurableProcess:               {{ProcessAllAccess, "PROCESS_ALL_ACCESS"}},
+	SecurableThread:                {{ThreadAllAccess, "THREAD_ALL_ACCESS"}},
+	SecurableToken: {
+		{TokenAllAccess, "TOKEN_ALL_ACCESS"},
+		{TokenWrite, "TOKEN_WRITE"},
+		{TokenRead, "TOKEN_READ"},
+	},
+	SecurableDSObject: {
+		{DSGenericAll, "DS_GENERIC_ALL"},
+		{DSGenericRead, "DS_GENERIC_READ"},
+		{DSGenericWrite, "DS_GENERIC_WRITE"},
+	},
+}
+
+// StringSliceFor, like StringSlice, returns human-readable permissions,
+// but named for an object type. Composite rights such as FILE_ALL_ACCESS
+// are named first, then the remaining specific, standard and generic
+// rights. Bits the object type does not define are written in hexadecimal.
+// SecurableGeneric returns StringSlice
+func (am ACEAccessMask) StringSliceFor(objectType SecurableObjectType) []string {
+	specific, ok := SpecificRightsLookup[objectType]
+	if !ok {
+		return am.StringSlice()
+	}
+
+	var readableRights []string
+	var covered uint32
+	for _, composite := range CompositeRightsLookup[objectType] {
+		if am.Value&composite.Mask == composite.Mask && composite.Mask&^covered != 0 {
+			readableRights = append(readableRights, composite.Name)
+			covered |= composite.Mask
+		}
+	}
+
+	rights, _ := bamflags.ParseInt(int64(am.Value &^ covered))
+	for _, right := range rights {
+		name := specific[uint32(right)]
+		if right > 0xFFFF {
+			name = ACEAccessMaskLookup[uint32(right)]
+		}
+		if name == "" {
+			name = fmt.Sprintf("0x%08X", right)
+		}
+		readableRights = append(readableRights, name)
+	}
+	return readableRights
+}
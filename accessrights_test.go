@@ -0,0 +1,59 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessMaskStringSliceFor(t *testing.T) {
+	r := require.New(t)
+
+	names := func(value uint32, objectType winacl.SecurableObjectType) []string {
+		return winacl.ACEAccessMask{Value: value}.StringSliceFor(objectType)
+	}
+
+	t.Run("Specific rights are named for the object type", func(t *testing.T) {
+		r.Equal([]string{"FILE_READ_DATA"}, names(0x1, winacl.SecurableFile))
+		r.Equal([]string{"FILE_LIST_DIRECTORY"}, names(0x1, winacl.SecurableDirectory))
+		r.Equal([]string{"FILE_CREATE_PIPE_INSTANCE"}, names(0x4, winacl.SecurableNamedPipe))
+		r.Equal([]string{"KEY_QUERY_VALUE"}, names(0x1, winacl.SecurableRegistryKey))
+		r.Equal([]string{"SERVICE_START", "SERVICE_STOP"}, names(0x30, winacl.SecurableService))
+		r.Equal([]string{"PROCESS_VM_READ"}, names(0x10, winacl.SecurableProcess))
+		r.Equal([]string{"CREATE_CHILD"}, names(0x1, winacl.SecurableDSObject))
+	})
+
+	t.Run("Composite rights", func(t *testing.T) {
+		r.Equal([]string{"FILE_ALL_ACCESS"}, names(winacl.FileAllAccess, winacl.SecurableFile))
+		r.Equal([]string{"FILE_GENERIC_READ", "FILE_GENERIC_EXECUTE"},
+			names(winacl.FileGenericRead|winacl.FileGenericExecute, winacl.SecurableFile))
+		r.Equal([]string{"FILE_GENERIC_READ", "DELETE"},
+			names(winacl.FileGenericRead|winacl.AccessMaskDelete, winacl.SecurableFile))
+		r.Equal([]string{"KEY_READ"}, names(winacl.KeyRead, winacl.SecurableRegistryKey))
+		r.Equal([]string{"SERVICE_ALL_ACCESS"}, names(winacl.ServiceAllAccess, winacl.SecurableService))
+	})
+
+	t.Run("Standard and generic rights", func(t *testing.T) {
+		r.Equal([]string{"KEY_SET_VALUE", "WRITE_DACL", "GENERIC_READ"},
+			names(0x2|winacl.AccessMaskWriteDACL|winacl.AccessMaskGenericRead, winacl.SecurableRegistryKey))
+	})
+
+	t.Run("Undefined bits are written in hexadecimal", func(t *testing.T) {
+		r.Equal([]string{"KEY_QUERY_VALUE", "0x00000040"}, names(0x41, winacl.SecurableRegistryKey))
+	})
+
+	t.Run("Generic object type keeps StringSlice", func(t *testing.T) {
+		r.Equal([]string{"CREATE_CHILD"}, names(0x1, winacl.SecurableGeneric))
+	})
+}
+
+func TestCompositeRightsSDDL(t *testing.T) {
+	r := require.New(t)
+
+	ntsd := mustParseSDDL(t, "O:S-1-5-18D:(A;;KX;;;WD)")
+	r.Equal(uint32(winacl.KeyExecute), ntsd.DACL.Aces[0].AccessMask.Value)
+
+	// KEY_EXECUTE and KEY_READ share a mask, which is written as KR
+	r.Equal("O:S-1-5-18D:(A;;KR;;;WD)", ntsd.ToSDDLFor(winacl.SecurableRegistryKey))
+}
@@ -1,6 +1,8 @@
 package winacl
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"strings"
 
@@ -30,6 +32,11 @@ const (
 	AceTypeSystemAlarmCallback
 	AceTypeSystemAuditCallbackObject
 	AceTypeSystemAlarmCallbackObject
+	AceTypeSystemMandatoryLabel
+	AceTypeSystemResourceAttribute
+	AceTypeSystemScopedPolicyID
+	AceTypeSystemProcessTrustLabel
+	AceTypeSystemAccessFilter
 )
 
 // ACETypeLookup maps ACE types to human-readable strings
@@ -51,6 +58,11 @@ var ACETypeLookup = map[AceType]string{
 	AceTypeSystemAlarmCallback:         "SYSTEM_ALARM_CALLBACK",
 	AceTypeSystemAuditCallbackObject:   "SYSTEM_AUDIT_CALLBACK_OBJECT",
 	AceTypeSystemAlarmCallbackObject:   "SYSTEM_ALARM_CALLBACK_OBJECT",
+	AceTypeSystemMandatoryLabel:        "SYSTEM_MANDATORY_LABEL",
+	AceTypeSystemResourceAttribute:     "SYSTEM_RESOURCE_ATTRIBUTE",
+	AceTypeSystemScopedPolicyID:        "SYSTEM_SCOPED_POLICY_ID",
+	AceTypeSystemProcessTrustLabel:     "SYSTEM_PROCESS_TRUST_LABEL",
+	AceTypeSystemAccessFilter:          "SYSTEM_ACCESS_FILTER",
 }
 
 // ACEHeaderFlags represents the flags in an ACE header
@@ -181,6 +193,12 @@ type ACE struct {
 
 // Strings returns an human-readable representation of an ACE
 func (s ACE) String() string {
+	return s.StringWith(nil)
+}
+
+// StringWith is String with the ACE's SID resolved to a name by resolver,
+// when it knows the SID
+func (s ACE) StringWith(resolver SIDResolver) string {
 	sb := strings.Builder{}
 
 	aceType := s.GetTypeString()
@@ -206,8 +224,82 @@ func (s ACE) String() string {
 		}
 	}
 
+	if cond := s.GetCondition(); cond != nil {
+		sb.WriteString(fmt.Sprintf("Condition: %s\n", cond.ToSDDL()))
+	}
+
 	sb.WriteString(fmt.Sprintf("Permissions: %s\n", perms))
-	return fmt.Sprintf("SID: %s\n%s", sid.String(), sb.String())
+	principal := sid.String()
+	if name := sid.ResolveWith(resolver); name != principal {
+		principal = fmt.Sprintf("%s (%s)", name, principal)
+	}
+	return fmt.Sprintf("SID: %s\n%s", principal, sb.String())
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, returning the ACE in
+// its binary form. The header's Size is recomputed and the ACE is padded
+// to a multiple of 4 bytes
+func (s ACE) MarshalBinary() ([]byte, error) {
+	var (
+		body []byte
+		err  error
+	)
+
+	switch oa := s.ObjectAce.(type) {
+	case BasicAce:
+		body, err = oa.MarshalBinary()
+	case AdvancedAce:
+		body, err = oa.MarshalBinary()
+	default:
+		return nil, fmt.Errorf("unsupported ACE object type: %T", s.ObjectAce)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 4 bytes for the header and 4 for the access mask, rounded up to DWORD
+	size := (8 + len(body) + 3) &^ 3
+	if size > 0xFFFF {
+		return nil, fmt.Errorf("ACE too large: %d bytes", size)
+	}
+
+	header := s.Header
+	header.Size = uint16(size)
+
+	buf := bytes.Buffer{}
+	buf.Grow(size)
+	if err = binary.Write(&buf, binary.LittleEndian, header); err != nil {
+		return nil, fmt.Errorf("writing ACE header: %w", err)
+	}
+	if err = binary.Write(&buf, binary.LittleEndian, s.AccessMask.Value); err != nil {
+		return nil, fmt.Errorf("writing ACE access mask: %w", err)
+	}
+	buf.Write(body)
+	buf.Write(make([]byte, size-buf.Len()))
+
+	return buf.Bytes(), nil
+}
+
+// recomputeSize sets the header's Size to the length MarshalBinary
+// would write
+func (s *ACE) recomputeSize() error {
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	s.Header.Size = uint16(len(data))
+	return nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, parsing an ACE
+// from its binary form
+func (s *ACE) UnmarshalBinary(data []byte) error {
+	ace, err := NewAce(bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	*s = ace
+	return nil
 }
 
 // ACEHeader represents an ACE Header
@@ -242,6 +334,24 @@ func (s ACE) GetType() AceType {
 	return s.Header.Type
 }
 
+// GetCondition returns the conditional expression of a callback ACE, or
+// nil when the ACE has none
+func (s ACE) GetCondition() *ConditionalExpression {
+	switch oa := s.ObjectAce.(type) {
+	case BasicAce:
+		return oa.Condition
+	case AdvancedAce:
+		return oa.Condition
+	}
+	return nil
+}
+
+// IsCallback reports whether the ACE is one of the callback types, which
+// may carry application data after the SID
+func (s ACE) IsCallback() bool {
+	return isCallbackAceType(s.Header.Type)
+}
+
 // GetTypeString returns the ACE type as a human-readable string
 func (s ACE) GetTypeString() string {
 	return ACETypeLookup[s.Header.Type]
@@ -250,6 +360,15 @@ func (s ACE) GetTypeString() string {
 // BasicAce represent a Simple ACEs
 type BasicAce struct {
 	SecurityIdentifier SID
+
+	// Condition and ApplicationData are only used by callback and access
+	// filter ACEs. Condition holds a parsed conditional expression;
+	// ApplicationData holds any other data that follows the SID
+	Condition       *ConditionalExpression
+	ApplicationData []byte
+
+	// Attribute is only used by resource attribute ACEs
+	Attribute *ClaimSecurityAttribute
 }
 
 // GetPrincipal returns an ACEs Principal
@@ -257,12 +376,35 @@ func (s BasicAce) GetPrincipal() SID {
 	return s.SecurityIdentifier
 }
 
+// MarshalBinary returns the type-specific part of the ACE, which follows
+// the access mask
+func (s BasicAce) MarshalBinary() ([]byte, error) {
+	sid, err := s.SecurityIdentifier.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Attribute != nil {
+		attr, err := s.Attribute.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("writing resource attribute: %w", err)
+		}
+		return append(sid, attr...), nil
+	}
+	return appendApplicationData(sid, s.Condition, s.ApplicationData)
+}
+
 // AdvancedAce represents an Object Ace
 type AdvancedAce struct {
 	Flags               ACEInheritanceFlags //4 bytes
 	ObjectType          GUID                //16 bytes
 	InheritedObjectType GUID
 	SecurityIdentifier  SID
+
+	// Condition and ApplicationData are only used by callback object ACEs,
+	// as in BasicAce
+	Condition       *ConditionalExpression
+	ApplicationData []byte
 }
 
 // GetPrincipal returns an ACEs Principal
@@ -270,6 +412,45 @@ func (s AdvancedAce) GetPrincipal() SID {
 	return s.SecurityIdentifier
 }
 
+// MarshalBinary returns the type-specific part of the ACE, which follows
+// the access mask. GUIDs are only written when Flags marks them present
+func (s AdvancedAce) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := binary.Write(&buf, binary.LittleEndian, s.Flags); err != nil {
+		return nil, fmt.Errorf("writing ACE inheritance flags: %w", err)
+	}
+
+	if (s.Flags & ACEInheritanceFlagsObjectTypePresent) != 0 {
+		guid, _ := s.ObjectType.MarshalBinary()
+		buf.Write(guid)
+	}
+	if (s.Flags & ACEInheritanceFlagsInheritedObjectTypePresent) != 0 {
+		guid, _ := s.InheritedObjectType.MarshalBinary()
+		buf.Write(guid)
+	}
+
+	sid, err := s.SecurityIdentifier.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(sid)
+	return appendApplicationData(buf.Bytes(), s.Condition, s.ApplicationData)
+}
+
+// appendApplicationData appends the data that follows the SID of a
+// callback ACE, preferring the conditional expression when one is set
+func appendApplicationData(body []byte, cond *ConditionalExpression, data []byte) ([]byte, error) {
+	if cond == nil {
+		return append(body, data...), nil
+	}
+
+	expr, err := cond.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("writing conditional expression: %w", err)
+	}
+	return append(body, expr...), nil
+}
+
 // FlagsString returns an human-readable representation of an ACEHeader's Flags
 func (s AdvancedAce) FlagsString() string {
 	sb := strings.Builder{}
@@ -253,3 +253,49 @@ func TestNewACEHeader(t *testing.T) {
 		r.Error(err)
 	})
 }
+
+func TestACEMarshalBinary(t *testing.T) {
+	r := require.New(t)
+
+	sid := winacl.SID{
+		Revision:       1,
+		NumAuthorities: 1,
+		Authority:      []byte{0, 0, 0, 0, 0, 5},
+		SubAuthorities: []uint32{10},
+	}
+	guid := winacl.GUID{
+		Data1: 0x12345678,
+		Data2: 0x1234,
+		Data3: 0x5678,
+		Data4: [8]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+
+	t.Run("Round-trips an AdvancedAce", func(t *testing.T) {
+		ace := winacl.ACE{
+			Header: winacl.ACEHeader{
+				Type:  winacl.AceTypeAccessAllowedObject,
+				Flags: winacl.ACEHeaderFlagsContainerInheritAce,
+			},
+			AccessMask: winacl.ACEAccessMask{Value: winacl.ADSRightDSReadProp},
+			ObjectAce: winacl.AdvancedAce{
+				Flags:              winacl.ACEInheritanceFlagsObjectTypePresent,
+				ObjectType:         guid,
+				SecurityIdentifier: sid,
+			},
+		}
+
+		out, err := ace.MarshalBinary()
+		r.NoError(err)
+		r.Len(out, 40) // 8 header+mask, 4 flags, 16 GUID, 12 SID
+
+		var parsed winacl.ACE
+		r.NoError(parsed.UnmarshalBinary(out))
+		r.Equal(uint16(40), parsed.Header.Size)
+		r.Equal(ace.ToSDDL(), parsed.ToSDDL())
+	})
+
+	t.Run("Returns an error for an unsupported object ACE", func(t *testing.T) {
+		_, err := winacl.ACE{}.MarshalBinary()
+		r.Error(err)
+	})
+}
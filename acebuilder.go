@@ -24,20 +24,35 @@ func NewAce(buf *bytes.Buffer) (ACE, error) {
 	// Process ACE based on its type
 	switch ace.Header.Type {
 	case AceTypeAccessAllowed, AceTypeAccessDenied, AceTypeSystemAudit, AceTypeSystemAlarm, 
-	     AceTypeAccessAllowedCallback, AceTypeAccessDeniedCallback, AceTypeSystemAuditCallback, AceTypeSystemAlarmCallback:
+	     AceTypeAccessAllowedCallback, AceTypeAccessDeniedCallback, AceTypeSystemAuditCallback, AceTypeSystemAlarmCallback,
+	     AceTypeSystemMandatoryLabel, AceTypeSystemResourceAttribute, AceTypeSystemScopedPolicyID,
+	     AceTypeSystemProcessTrustLabel, AceTypeSystemAccessFilter:
 		
-		ace.ObjectAce, err = NewBasicAce(buf, ace.Header.Size)
+		oa, err := NewBasicAce(buf, ace.Header.Size)
 		if err != nil {
 			return ace, fmt.Errorf("parsing basic ACE: %w", err)
 		}
 
+		if ace.Header.Type == AceTypeSystemResourceAttribute {
+			attr, err := NewClaimSecurityAttribute(oa.ApplicationData)
+			if err != nil {
+				return ace, fmt.Errorf("parsing resource attribute: %w", err)
+			}
+			oa.Attribute, oa.ApplicationData = &attr, nil
+		} else {
+			oa.Condition, oa.ApplicationData = parseApplicationData(ace.Header.Type, oa.ApplicationData)
+		}
+		ace.ObjectAce = oa
+
 	case AceTypeAccessAllowedObject, AceTypeAccessDeniedObject, AceTypeSystemAuditObject, AceTypeSystemAlarmObject, 
 	     AceTypeAccessAllowedCallbackObject, AceTypeAccessDeniedCallbackObject, AceTypeSystemAuditCallbackObject, AceTypeSystemAlarmCallbackObject:
 		
-		ace.ObjectAce, err = NewAdvancedAce(buf, ace.Header.Size)
+		oa, err := NewAdvancedAce(buf, ace.Header.Size)
 		if err != nil {
 			return ace, fmt.Errorf("parsing advanced ACE: %w", err)
 		}
+		oa.Condition, oa.ApplicationData = parseApplicationData(ace.Header.Type, oa.ApplicationData)
+		ace.ObjectAce = oa
 
 	default:
 		return ace, fmt.Errorf("unknown ACE type: %d", ace.Header.Type)
@@ -66,12 +81,13 @@ func NewBasicAce(buf *bytes.Buffer, totalSize uint16) (BasicAce, error) {
 		return oa, fmt.Errorf("invalid ACE size for SID: %d", sidSize)
 	}
 	
-	sid, err := NewSID(buf, sidSize)
+	sid, rest, err := readACESID(buf, sidSize)
 	if err != nil {
 		return oa, fmt.Errorf("parsing SID in basic ACE: %w", err)
 	}
 	
 	oa.SecurityIdentifier = sid
+	oa.ApplicationData = rest
 	return oa, nil
 }
 
@@ -114,11 +130,62 @@ func NewAdvancedAce(buf *bytes.Buffer, totalSize uint16) (AdvancedAce, error) {
 	}
 	
 	// Read SID
-	sid, err := NewSID(buf, sidSize)
+	sid, rest, err := readACESID(buf, sidSize)
 	if err != nil {
 		return oa, fmt.Errorf("parsing SID in advanced ACE: %w", err)
 	}
 	
 	oa.SecurityIdentifier = sid
+	oa.ApplicationData = rest
 	return oa, nil
 }
+
+// readACESID consumes the remaining size bytes of an ACE, returning the SID
+// at their start and whatever follows it
+func readACESID(buf *bytes.Buffer, size int) (SID, []byte, error) {
+	data := buf.Next(size)
+	sidLength, err := sidLengthFromBytes(data)
+	if err != nil {
+		return SID{}, nil, err
+	}
+
+	sid, err := NewSID(bytes.NewBuffer(data), sidLength)
+	if err != nil {
+		return sid, nil, err
+	}
+	return sid, append([]byte(nil), data[sidLength:]...), nil
+}
+
+// parseApplicationData interprets the bytes that follow an ACE's SID.
+// Only callback and access filter ACEs carry application data; for other
+// types the bytes are padding and are dropped. Data holding a valid
+// conditional expression is parsed, anything else is kept as-is
+func parseApplicationData(aceType AceType, data []byte) (*ConditionalExpression, []byte) {
+	if !isConditionalAceType(aceType) || len(bytes.Trim(data, "\x00")) == 0 {
+		return nil, nil
+	}
+
+	if expr, err := NewConditionalExpression(data); err == nil {
+		return expr, nil
+	}
+	return nil, data
+}
+
+// isCallbackAceType reports whether ACEs of this type may carry
+// application data after their SID
+func isCallbackAceType(aceType AceType) bool {
+	switch aceType {
+	case AceTypeAccessAllowedCallback, AceTypeAccessDeniedCallback,
+		AceTypeAccessAllowedCallbackObject, AceTypeAccessDeniedCallbackObject,
+		AceTypeSystemAuditCallback, AceTypeSystemAlarmCallback,
+		AceTypeSystemAuditCallbackObject, AceTypeSystemAlarmCallbackObject:
+		return true
+	}
+	return false
+}
+
+// isConditionalAceType reports whether ACEs of this type may carry a
+// conditional expression: callback ACEs and access filter ACEs
+func isConditionalAceType(aceType AceType) bool {
+	return isCallbackAceType(aceType) || aceType == AceTypeSystemAccessFilter
+}
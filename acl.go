@@ -6,6 +6,12 @@ import (
 	"fmt"
 )
 
+// ACL revisions. ACLRevisionDS is required when the ACL holds object ACEs
+const (
+	ACLRevision   = 0x02
+	ACLRevisionDS = 0x04
+)
+
 // ACL represents an Access Control List
 type ACL struct {
 	Header ACLHeader
@@ -57,3 +63,64 @@ func (header *ACLHeader) ToBuffer() (bytes.Buffer, error) {
 	err := binary.Write(&buf, binary.LittleEndian, header)
 	return buf, err
 }
+
+// MarshalBinary implements encoding.BinaryMarshaler, returning the ACL in
+// its binary form. The header's Size and AceCount are recomputed from the
+// ACEs and a zero Revision is replaced with the lowest one that fits them
+func (a ACL) MarshalBinary() ([]byte, error) {
+	body := bytes.Buffer{}
+	revision := byte(ACLRevision)
+
+	for i, ace := range a.Aces {
+		aceBytes, err := ace.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("writing ACE %d: %w", i, err)
+		}
+		body.Write(aceBytes)
+
+		if _, ok := ace.ObjectAce.(AdvancedAce); ok {
+			revision = ACLRevisionDS
+		}
+	}
+
+	size := 8 + body.Len()
+	if size > 0xFFFF || len(a.Aces) > 0xFFFF {
+		return nil, fmt.Errorf("ACL too large: %d bytes", size)
+	}
+
+	header := a.Header
+	header.Size = uint16(size)
+	header.AceCount = uint16(len(a.Aces))
+	if header.Revision == 0 {
+		header.Revision = revision
+	}
+
+	buf, err := header.ToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("writing ACL header: %w", err)
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// recomputeHeader sets the header's Size, AceCount and, when unset,
+// Revision from the ACEs, as MarshalBinary would write them
+func (a *ACL) recomputeHeader() error {
+	data, err := a.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	a.Header, err = NewACLHeader(bytes.NewBuffer(data))
+	return err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, parsing an ACL
+// from its binary form
+func (a *ACL) UnmarshalBinary(data []byte) error {
+	acl, err := NewACL(bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	*a = acl
+	return nil
+}
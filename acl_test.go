@@ -37,3 +37,42 @@ func TestNewACLHeader(t *testing.T) {
 	})
 
 }
+
+func TestACLMarshalBinary(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Round-trips the test DACL", func(t *testing.T) {
+		sd := newTestSD()
+		out, err := sd.DACL.MarshalBinary()
+		r.NoError(err)
+		r.Equal(int(sd.DACL.Header.Size), len(out))
+
+		var acl winacl.ACL
+		r.NoError(acl.UnmarshalBinary(out))
+		r.Equal(sd.DACL.Header, acl.Header)
+		r.Equal(len(sd.DACL.Aces), len(acl.Aces))
+	})
+
+	t.Run("Recomputes the header from the ACEs", func(t *testing.T) {
+		sid, err := winacl.NewSIDFromString("S-1-1-0")
+		r.NoError(err)
+
+		acl := winacl.ACL{
+			Aces: []winacl.ACE{{
+				Header:     winacl.ACEHeader{Type: winacl.AceTypeAccessAllowed},
+				AccessMask: winacl.ACEAccessMask{Value: winacl.AccessMaskGenericRead},
+				ObjectAce:  winacl.BasicAce{SecurityIdentifier: sid},
+			}},
+		}
+		out, err := acl.MarshalBinary()
+		r.NoError(err)
+		r.Len(out, 28)
+
+		var parsed winacl.ACL
+		r.NoError(parsed.UnmarshalBinary(out))
+		r.Equal(byte(winacl.ACLRevision), parsed.Header.Revision)
+		r.Equal(uint16(28), parsed.Header.Size)
+		r.Equal(uint16(1), parsed.Header.AceCount)
+		r.Equal(uint16(20), parsed.Aces[0].Header.Size)
+	})
+}
@@ -0,0 +1,45 @@
+package winacl
+
+// SIDs granted to AppContainers as a whole
+const (
+	AllApplicationPackagesSID           = "S-1-15-2-1"
+	AllRestrictedApplicationPackagesSID = "S-1-15-2-2"
+)
+
+// AppContainerSIDPrefix is the prefix of AppContainer package SIDs
+const AppContainerSIDPrefix = "S-1-15-2-"
+
+// IsAppContainer reports whether the token belongs to an AppContainer
+func (t *TokenUser) IsAppContainer() bool {
+	return t.AppContainerSID != nil
+}
+
+// appContainerSIDs returns the SIDs matched by the AppContainer pass: the
+// package SID, the token's capabilities, ALL RESTRICTED APPLICATION
+// PACKAGES and, unless the AppContainer is less privileged, ALL
+// APPLICATION PACKAGES
+func (t *TokenUser) appContainerSIDs() []SID {
+	sids := make([]SID, 0, len(t.Capabilities)+3)
+	if t.AppContainerSID != nil {
+		sids = append(sids, *t.AppContainerSID)
+	}
+	sids = append(sids, t.Capabilities...)
+
+	allRestricted, _ := NewSIDFromString(AllRestrictedApplicationPackagesSID)
+	sids = append(sids, allRestricted)
+	if !t.LessPrivilegedAppContainer {
+		all, _ := NewSIDFromString(AllApplicationPackagesSID)
+		sids = append(sids, all)
+	}
+	return sids
+}
+
+// appContainerAccessCheck runs the AppContainer pass of an AppContainer
+// token's access check and intersects it with the earlier passes
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/implementing-an-appcontainer
+func appContainerAccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
+	desiredAccess uint32, options *AccessCheckOptions, first *AccessCheckResult,
+) *AccessCheckResult {
+	return intersectAccessCheck(securityDescriptor, token, tokenPassAppContainer, ^uint32(0), desiredAccess, options, first)
+}
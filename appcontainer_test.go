@@ -0,0 +1,78 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppContainerAccessCheck(t *testing.T) {
+	r := require.New(t)
+
+	userSID := mustSID(t, "S-1-5-21-1-2-3-1001")
+	packageSID := mustSID(t, "S-1-15-2-1-2-3-4-5-6-7")
+	internetClient, err := winacl.SIDFromCapability("internetClient")
+	r.NoError(err)
+
+	newToken := func(lpac bool) *winacl.TokenUser {
+		token := winacl.NewTokenUser(userSID, []winacl.SID{mustSID(t, "S-1-5-32-545")})
+		token.AppContainerSID = &packageSID
+		token.Capabilities = []winacl.SID{internetClient}
+		token.LessPrivilegedAppContainer = lpac
+		return token
+	}
+
+	appContainer := newToken(false)
+	lpac := newToken(true)
+
+	t.Run("Normal SIDs alone are not enough", func(t *testing.T) {
+		r.True(appContainer.IsAppContainer())
+
+		result := accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BU)", appContainer, 0x1, nil)
+		r.False(result.Granted)
+		r.Contains(result.Reason, "AppContainer")
+	})
+
+	t.Run("AppContainer SIDs alone are not enough", func(t *testing.T) {
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;"+winacl.AllApplicationPackagesSID+")", appContainer, 0x1, nil).Granted)
+	})
+
+	t.Run("ALL APPLICATION PACKAGES", func(t *testing.T) {
+		sddl := "O:SYD:(A;;0x1;;;BU)(A;;0x1;;;" + winacl.AllApplicationPackagesSID + ")"
+		r.True(accessCheckSDDL(t, sddl, appContainer, 0x1, nil).Granted)
+		r.False(accessCheckSDDL(t, sddl, lpac, 0x1, nil).Granted)
+	})
+
+	t.Run("ALL RESTRICTED APPLICATION PACKAGES", func(t *testing.T) {
+		sddl := "O:SYD:(A;;0x1;;;BU)(A;;0x1;;;" + winacl.AllRestrictedApplicationPackagesSID + ")"
+		r.True(accessCheckSDDL(t, sddl, appContainer, 0x1, nil).Granted)
+		r.True(accessCheckSDDL(t, sddl, lpac, 0x1, nil).Granted)
+	})
+
+	t.Run("Package SID and capabilities", func(t *testing.T) {
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BU)(A;;0x1;;;"+packageSID.String()+")", lpac, 0x1, nil).Granted)
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BU)(A;;0x1;;;"+internetClient.String()+")", lpac, 0x1, nil).Granted)
+
+		other, err := winacl.SIDFromCapability("webcam")
+		r.NoError(err)
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BU)(A;;0x1;;;"+other.String()+")", lpac, 0x1, nil).Granted)
+	})
+
+	t.Run("Everyone does not match the AppContainer pass", func(t *testing.T) {
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;WD)", appContainer, 0x1, nil).Granted)
+	})
+
+	t.Run("Maximum allowed is the intersection", func(t *testing.T) {
+		sddl := "O:SYD:(A;;0x7;;;BU)(A;;0x5;;;" + packageSID.String() + ")"
+		result := accessCheckSDDL(t, sddl, appContainer, winacl.AccessMaskMaximumAllowed, nil)
+		r.True(result.Granted)
+		r.Equal(uint32(0x5), result.Access)
+
+		for _, decision := range result.Rights {
+			if decision.Right == 0x2 {
+				r.Equal(winacl.DecisionSourceAppContainer, decision.Source)
+			}
+		}
+	})
+}
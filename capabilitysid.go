@@ -190,7 +190,7 @@ func ParseCapabilitySID(data []byte) (SID, error) {
 	}
 	
 	// Verify it's a capability SID
-	if sid.Authority[5] != 15 || (numAuth > 0 && sid.SubAuthorities[0] != 3) {
+	if sid.IdentifierAuthority() != 15 || (numAuth > 0 && sid.SubAuthorities[0] != 3) {
 		return SID{}, fmt.Errorf("not a capability SID")
 	}
 	
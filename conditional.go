@@ -0,0 +1,848 @@
+package winacl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ConditionalSignature is the "artx" marker that starts the application
+// data of a callback ACE holding a conditional expression
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/7ba4de4c-3f0e-4e05-a0c4-bcb2a7d3b8a1
+var ConditionalSignature = []byte{'a', 'r', 't', 'x'}
+
+// ConditionalTokenType is the byte code of a token in a conditional
+// expression
+type ConditionalTokenType byte
+
+// Conditional expression token constants
+const (
+	ConditionalTokenPadding ConditionalTokenType = 0x00
+
+	// Literal tokens
+	ConditionalTokenInt8          ConditionalTokenType = 0x01
+	ConditionalTokenInt16         ConditionalTokenType = 0x02
+	ConditionalTokenInt32         ConditionalTokenType = 0x03
+	ConditionalTokenInt64         ConditionalTokenType = 0x04
+	ConditionalTokenUnicodeString ConditionalTokenType = 0x10
+	ConditionalTokenOctetString   ConditionalTokenType = 0x18
+	ConditionalTokenComposite     ConditionalTokenType = 0x50
+	ConditionalTokenSID           ConditionalTokenType = 0x51
+
+	// Relational operators
+	ConditionalTokenEqual                ConditionalTokenType = 0x80
+	ConditionalTokenNotEqual             ConditionalTokenType = 0x81
+	ConditionalTokenLessThan             ConditionalTokenType = 0x82
+	ConditionalTokenLessThanOrEqual      ConditionalTokenType = 0x83
+	ConditionalTokenGreaterThan          ConditionalTokenType = 0x84
+	ConditionalTokenGreaterThanOrEqual   ConditionalTokenType = 0x85
+	ConditionalTokenContains             ConditionalTokenType = 0x86
+	ConditionalTokenExists               ConditionalTokenType = 0x87
+	ConditionalTokenAnyOf                ConditionalTokenType = 0x88
+	ConditionalTokenMemberOf             ConditionalTokenType = 0x89
+	ConditionalTokenDeviceMemberOf       ConditionalTokenType = 0x8a
+	ConditionalTokenMemberOfAny          ConditionalTokenType = 0x8b
+	ConditionalTokenDeviceMemberOfAny    ConditionalTokenType = 0x8c
+	ConditionalTokenNotExists            ConditionalTokenType = 0x8d
+	ConditionalTokenNotContains          ConditionalTokenType = 0x8e
+	ConditionalTokenNotAnyOf             ConditionalTokenType = 0x8f
+	ConditionalTokenNotMemberOf          ConditionalTokenType = 0x90
+	ConditionalTokenNotDeviceMemberOf    ConditionalTokenType = 0x91
+	ConditionalTokenNotMemberOfAny       ConditionalTokenType = 0x92
+	ConditionalTokenNotDeviceMemberOfAny ConditionalTokenType = 0x93
+
+	// Logical operators
+	ConditionalTokenAnd ConditionalTokenType = 0xa0
+	ConditionalTokenOr  ConditionalTokenType = 0xa1
+	ConditionalTokenNot ConditionalTokenType = 0xa2
+
+	// Attribute references
+	ConditionalTokenLocalAttribute    ConditionalTokenType = 0xf8
+	ConditionalTokenUserAttribute     ConditionalTokenType = 0xf9
+	ConditionalTokenResourceAttribute ConditionalTokenType = 0xfa
+	ConditionalTokenDeviceAttribute   ConditionalTokenType = 0xfb
+)
+
+// ConditionalTokenSDDL maps operator tokens to their SDDL spelling
+var ConditionalTokenSDDL = map[ConditionalTokenType]string{
+	ConditionalTokenEqual:                "==",
+	ConditionalTokenNotEqual:             "!=",
+	ConditionalTokenLessThan:             "<",
+	ConditionalTokenLessThanOrEqual:      "<=",
+	ConditionalTokenGreaterThan:          ">",
+	ConditionalTokenGreaterThanOrEqual:   ">=",
+	ConditionalTokenContains:             "Contains",
+	ConditionalTokenExists:               "Exists",
+	ConditionalTokenAnyOf:                "Any_of",
+	ConditionalTokenMemberOf:             "Member_of",
+	ConditionalTokenDeviceMemberOf:       "Device_Member_of",
+	ConditionalTokenMemberOfAny:          "Member_of_Any",
+	ConditionalTokenDeviceMemberOfAny:    "Device_Member_of_Any",
+	ConditionalTokenNotExists:            "Not_Exists",
+	ConditionalTokenNotContains:          "Not_Contains",
+	ConditionalTokenNotAnyOf:             "Not_Any_of",
+	ConditionalTokenNotMemberOf:          "Not_Member_of",
+	ConditionalTokenNotDeviceMemberOf:    "Not_Device_Member_of",
+	ConditionalTokenNotMemberOfAny:       "Not_Member_of_Any",
+	ConditionalTokenNotDeviceMemberOfAny: "Not_Device_Member_of_Any",
+	ConditionalTokenAnd:                  "&&",
+	ConditionalTokenOr:                   "||",
+	ConditionalTokenNot:                  "!",
+}
+
+// ConditionalAttributePrefixSDDL maps attribute tokens to the prefix
+// SDDL writes before the attribute name. Local attributes have none
+var ConditionalAttributePrefixSDDL = map[ConditionalTokenType]string{
+	ConditionalTokenLocalAttribute:    "",
+	ConditionalTokenUserAttribute:     "@User.",
+	ConditionalTokenResourceAttribute: "@Resource.",
+	ConditionalTokenDeviceAttribute:   "@Device.",
+}
+
+// ConditionalIntSign records how the sign of an integer literal was written
+type ConditionalIntSign byte
+
+// ConditionalIntSign constants
+const (
+	ConditionalIntSignPlus  ConditionalIntSign = 0x01
+	ConditionalIntSignMinus ConditionalIntSign = 0x02
+	ConditionalIntSignNone  ConditionalIntSign = 0x03
+)
+
+// ConditionalIntBase records the base an integer literal was written in
+type ConditionalIntBase byte
+
+// ConditionalIntBase constants
+const (
+	ConditionalIntBaseOctal   ConditionalIntBase = 0x01
+	ConditionalIntBaseDecimal ConditionalIntBase = 0x02
+	ConditionalIntBaseHex     ConditionalIntBase = 0x03
+)
+
+// ConditionalToken is a single token of a conditional expression. Only the
+// fields relevant to its Type are set
+type ConditionalToken struct {
+	Type ConditionalTokenType
+
+	// Int, Sign and Base hold integer literals
+	Int  int64
+	Sign ConditionalIntSign
+	Base ConditionalIntBase
+
+	// Text holds Unicode string literals and attribute names
+	Text string
+
+	// Octets holds octet string literals
+	Octets []byte
+
+	// SID holds SID literals
+	SID SID
+
+	// Composite holds the elements of composite literals
+	Composite []ConditionalToken
+}
+
+// IsLiteral reports whether the token is a literal value
+func (t ConditionalToken) IsLiteral() bool {
+	switch t.Type {
+	case ConditionalTokenInt8, ConditionalTokenInt16, ConditionalTokenInt32, ConditionalTokenInt64,
+		ConditionalTokenUnicodeString, ConditionalTokenOctetString,
+		ConditionalTokenComposite, ConditionalTokenSID:
+		return true
+	}
+	return false
+}
+
+// IsAttribute reports whether the token references an attribute
+func (t ConditionalToken) IsAttribute() bool {
+	_, ok := ConditionalAttributePrefixSDDL[t.Type]
+	return ok
+}
+
+// IsInteger reports whether the token is an integer literal
+func (t ConditionalToken) IsInteger() bool {
+	return t.Type >= ConditionalTokenInt8 && t.Type <= ConditionalTokenInt64
+}
+
+// operandCount returns the number of operands an operator consumes, or 0
+// for literals and attributes
+func (t ConditionalToken) operandCount() int {
+	switch t.Type {
+	case ConditionalTokenExists, ConditionalTokenNotExists,
+		ConditionalTokenMemberOf, ConditionalTokenDeviceMemberOf,
+		ConditionalTokenMemberOfAny, ConditionalTokenDeviceMemberOfAny,
+		ConditionalTokenNotMemberOf, ConditionalTokenNotDeviceMemberOf,
+		ConditionalTokenNotMemberOfAny, ConditionalTokenNotDeviceMemberOfAny,
+		ConditionalTokenNot:
+		return 1
+	}
+	if _, ok := ConditionalTokenSDDL[t.Type]; ok {
+		return 2
+	}
+	return 0
+}
+
+// ConditionalExpression is a conditional ACE expression. Tokens are kept
+// in the postfix order in which they are stored in the ACE
+type ConditionalExpression struct {
+	Tokens []ConditionalToken
+}
+
+// conditionalNode is an operator or operand of a ConditionalExpression,
+// arranged as a tree
+type conditionalNode struct {
+	token    ConditionalToken
+	operands []*conditionalNode
+}
+
+// tree arranges the postfix tokens into an expression tree, failing when
+// operators lack operands or the tokens do not reduce to a single root
+func (e ConditionalExpression) tree() (*conditionalNode, error) {
+	stack := make([]*conditionalNode, 0, len(e.Tokens))
+
+	for i, tok := range e.Tokens {
+		node := &conditionalNode{token: tok}
+		if n := tok.operandCount(); n > 0 {
+			if len(stack) < n {
+				return nil, fmt.Errorf("operator %s at token %d is missing operands",
+					ConditionalTokenSDDL[tok.Type], i)
+			}
+			node.operands = append([]*conditionalNode(nil), stack[len(stack)-n:]...)
+			stack = stack[:len(stack)-n]
+		} else if !tok.IsLiteral() && !tok.IsAttribute() {
+			return nil, fmt.Errorf("unknown token 0x%02x at token %d", byte(tok.Type), i)
+		}
+		stack = append(stack, node)
+	}
+
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("expression reduces to %d values, expected 1", len(stack))
+	}
+	return stack[0], nil
+}
+
+// NewConditionalExpression is a constructor that will parse out a
+// ConditionalExpression from the application data of a callback ACE
+func NewConditionalExpression(data []byte) (*ConditionalExpression, error) {
+	if !bytes.HasPrefix(data, ConditionalSignature) {
+		return nil, fmt.Errorf("missing conditional expression signature")
+	}
+
+	tokens, err := readConditionalTokens(data[len(ConditionalSignature):])
+	if err != nil {
+		return nil, err
+	}
+
+	expr := &ConditionalExpression{Tokens: tokens}
+	if _, err := expr.tree(); err != nil {
+		return nil, fmt.Errorf("invalid conditional expression: %w", err)
+	}
+	return expr, nil
+}
+
+// readConditionalTokens decodes a sequence of binary tokens, skipping
+// padding
+func readConditionalTokens(data []byte) ([]ConditionalToken, error) {
+	tokens := make([]ConditionalToken, 0)
+
+	for pos := 0; pos < len(data); {
+		tok := ConditionalToken{Type: ConditionalTokenType(data[pos])}
+		pos++
+
+		switch {
+		case tok.Type == ConditionalTokenPadding:
+			continue
+
+		case tok.IsInteger():
+			if pos+10 > len(data) {
+				return nil, fmt.Errorf("truncated integer token at offset %d", pos-1)
+			}
+			tok.Int = int64(binary.LittleEndian.Uint64(data[pos : pos+8]))
+			tok.Sign = ConditionalIntSign(data[pos+8])
+			tok.Base = ConditionalIntBase(data[pos+9])
+			pos += 10
+
+		case tok.IsAttribute(), tok.Type == ConditionalTokenUnicodeString,
+			tok.Type == ConditionalTokenOctetString,
+			tok.Type == ConditionalTokenComposite, tok.Type == ConditionalTokenSID:
+			if pos+4 > len(data) {
+				return nil, fmt.Errorf("truncated token length at offset %d", pos-1)
+			}
+			length := int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+			pos += 4
+			if length < 0 || pos+length > len(data) {
+				return nil, fmt.Errorf("token length %d at offset %d exceeds data", length, pos-5)
+			}
+			value := data[pos : pos+length]
+			pos += length
+
+			var err error
+			switch tok.Type {
+			case ConditionalTokenOctetString:
+				tok.Octets = append([]byte(nil), value...)
+			case ConditionalTokenComposite:
+				tok.Composite, err = readConditionalTokens(value)
+			case ConditionalTokenSID:
+				err = tok.SID.UnmarshalBinary(value)
+			default:
+				tok.Text, err = decodeUTF16(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("decoding token at offset %d: %w", pos-length-5, err)
+			}
+
+		case tok.operandCount() > 0:
+			// Operators carry no data
+
+		default:
+			return nil, fmt.Errorf("unknown token 0x%02x at offset %d", data[pos-1], pos-1)
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	return tokens, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, returning the
+// expression as callback ACE application data, padded to a multiple of
+// 4 bytes
+func (e ConditionalExpression) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.Write(ConditionalSignature)
+	if err := writeConditionalTokens(&buf, e.Tokens); err != nil {
+		return nil, err
+	}
+	buf.Write(make([]byte, (4-buf.Len()%4)%4))
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, parsing callback
+// ACE application data
+func (e *ConditionalExpression) UnmarshalBinary(data []byte) error {
+	expr, err := NewConditionalExpression(data)
+	if err != nil {
+		return err
+	}
+	*e = *expr
+	return nil
+}
+
+func writeConditionalTokens(buf *bytes.Buffer, tokens []ConditionalToken) error {
+	for _, tok := range tokens {
+		buf.WriteByte(byte(tok.Type))
+
+		var value []byte
+		switch {
+		case tok.IsInteger():
+			binary.Write(buf, binary.LittleEndian, tok.Int)
+			buf.WriteByte(byte(tok.Sign))
+			buf.WriteByte(byte(tok.Base))
+			continue
+
+		case tok.Type == ConditionalTokenOctetString:
+			value = tok.Octets
+
+		case tok.Type == ConditionalTokenComposite:
+			inner := bytes.Buffer{}
+			if err := writeConditionalTokens(&inner, tok.Composite); err != nil {
+				return err
+			}
+			value = inner.Bytes()
+
+		case tok.Type == ConditionalTokenSID:
+			var err error
+			if value, err = tok.SID.MarshalBinary(); err != nil {
+				return err
+			}
+
+		case tok.IsAttribute(), tok.Type == ConditionalTokenUnicodeString:
+			value = encodeUTF16(tok.Text)
+
+		case tok.operandCount() > 0:
+			continue
+
+		default:
+			return fmt.Errorf("unknown conditional token 0x%02x", byte(tok.Type))
+		}
+
+		binary.Write(buf, binary.LittleEndian, uint32(len(value)))
+		buf.Write(value)
+	}
+	return nil
+}
+
+func decodeUTF16(data []byte) (string, error) {
+	if len(data)%2 != 0 {
+		return "", fmt.Errorf("odd UTF-16 length %d", len(data))
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+func encodeUTF16(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	data := make([]byte, len(units)*2)
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(data[i*2:], unit)
+	}
+	return data
+}
+
+// String returns the SDDL form of the expression
+func (e ConditionalExpression) String() string {
+	return e.ToSDDL()
+}
+
+// ToSDDL will convert the expression into the SDDL form used as the last
+// field of a callback ACE string, such as (@User.Department == "Finance")
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/security-descriptor-definition-language-for-conditional-aces-
+func (e ConditionalExpression) ToSDDL() string {
+	root, err := e.tree()
+	if err != nil {
+		return ""
+	}
+
+	sddl := root.toSDDL()
+	if !strings.HasPrefix(sddl, "(") {
+		sddl = "(" + sddl + ")"
+	}
+	return sddl
+}
+
+func (n *conditionalNode) toSDDL() string {
+	tok := n.token
+	op := ConditionalTokenSDDL[tok.Type]
+
+	switch {
+	case tok.Type == ConditionalTokenNot:
+		return fmt.Sprintf("(%s%s)", op, n.operands[0].toSDDL())
+	case len(n.operands) == 1:
+		return fmt.Sprintf("(%s %s)", op, n.operands[0].toSDDL())
+	case len(n.operands) == 2:
+		return fmt.Sprintf("(%s %s %s)", n.operands[0].toSDDL(), op, n.operands[1].toSDDL())
+	}
+	return tok.literalSDDL()
+}
+
+// literalSDDL renders a literal or attribute token
+func (t ConditionalToken) literalSDDL() string {
+	switch {
+	case t.IsAttribute():
+		return ConditionalAttributePrefixSDDL[t.Type] + t.Text
+
+	case t.IsInteger():
+		magnitude := uint64(t.Int)
+		if t.Int < 0 {
+			magnitude = uint64(-t.Int)
+		}
+
+		var digits string
+		switch t.Base {
+		case ConditionalIntBaseHex:
+			digits = "0x" + strconv.FormatUint(magnitude, 16)
+		case ConditionalIntBaseOctal:
+			digits = "0" + strconv.FormatUint(magnitude, 8)
+		default:
+			digits = strconv.FormatUint(magnitude, 10)
+		}
+
+		switch {
+		case t.Int < 0:
+			return "-" + digits
+		case t.Sign == ConditionalIntSignPlus:
+			return "+" + digits
+		}
+		return digits
+
+	case t.Type == ConditionalTokenUnicodeString:
+		// SDDL strings have no escape sequences
+		return `"` + t.Text + `"`
+
+	case t.Type == ConditionalTokenOctetString:
+		return "#" + hex.EncodeToString(t.Octets)
+
+	case t.Type == ConditionalTokenSID:
+		sid := t.SID.String()
+		if alias := WellKnownSIDsSSDL[sid]; alias != "" {
+			sid = alias
+		}
+		return fmt.Sprintf("SID(%s)", sid)
+
+	case t.Type == ConditionalTokenComposite:
+		elems := make([]string, len(t.Composite))
+		for i, elem := range t.Composite {
+			elems[i] = elem.literalSDDL()
+		}
+		return "{" + strings.Join(elems, ", ") + "}"
+	}
+	return ""
+}
+
+// NewConditionalExpressionFromSDDL is a constructor that will parse out a
+// ConditionalExpression from its SDDL form, such as
+// (@User.Department == "Finance")
+func NewConditionalExpressionFromSDDL(sddl string) (*ConditionalExpression, error) {
+	p := sddlParser{input: sddl}
+	return p.parseConditionalExpression(len(sddl))
+}
+
+// conditionalBinaryOperators maps the relational operators that sit between
+// two operands to their tokens, keyed by lower-cased SDDL spelling
+var conditionalBinaryOperators = map[string]ConditionalTokenType{
+	"==":           ConditionalTokenEqual,
+	"!=":           ConditionalTokenNotEqual,
+	"<":            ConditionalTokenLessThan,
+	"<=":           ConditionalTokenLessThanOrEqual,
+	">":            ConditionalTokenGreaterThan,
+	">=":           ConditionalTokenGreaterThanOrEqual,
+	"contains":     ConditionalTokenContains,
+	"any_of":       ConditionalTokenAnyOf,
+	"not_contains": ConditionalTokenNotContains,
+	"not_any_of":   ConditionalTokenNotAnyOf,
+}
+
+// conditionalUnaryOperators maps the relational operators that precede a
+// single operand to their tokens, keyed by lower-cased SDDL spelling
+var conditionalUnaryOperators = map[string]ConditionalTokenType{
+	"exists":                   ConditionalTokenExists,
+	"not_exists":               ConditionalTokenNotExists,
+	"member_of":                ConditionalTokenMemberOf,
+	"device_member_of":         ConditionalTokenDeviceMemberOf,
+	"member_of_any":            ConditionalTokenMemberOfAny,
+	"device_member_of_any":     ConditionalTokenDeviceMemberOfAny,
+	"not_member_of":            ConditionalTokenNotMemberOf,
+	"not_device_member_of":     ConditionalTokenNotDeviceMemberOf,
+	"not_member_of_any":        ConditionalTokenNotMemberOfAny,
+	"not_device_member_of_any": ConditionalTokenNotDeviceMemberOfAny,
+}
+
+// conditionalDelimiters end attribute names and keywords
+const conditionalDelimiters = " \t\r\n()=!<>&|,{}\""
+
+// conditionalParser parses the SDDL form of a conditional expression into
+// postfix tokens. It shares the input and position of its sddlParser so
+// that errors are reported relative to the whole SDDL string
+type conditionalParser struct {
+	*sddlParser
+	end    int
+	tokens []ConditionalToken
+}
+
+// parseConditionalExpression parses the expression between p.pos and end
+func (p *sddlParser) parseConditionalExpression(end int) (*ConditionalExpression, error) {
+	cp := conditionalParser{sddlParser: p, end: end}
+
+	if err := cp.parseOr(); err != nil {
+		return nil, err
+	}
+	cp.skipSpace()
+	if p.pos != end {
+		return nil, p.errorf("unexpected characters in conditional expression")
+	}
+	return &ConditionalExpression{Tokens: cp.tokens}, nil
+}
+
+func (cp *conditionalParser) skipSpace() {
+	for cp.pos < cp.end && strings.IndexByte(" \t\r\n", cp.input[cp.pos]) >= 0 {
+		cp.pos++
+	}
+}
+
+// hasPrefix reports whether the remaining input starts with s, ignoring case
+func (cp *conditionalParser) hasPrefix(s string) bool {
+	return cp.pos+len(s) <= cp.end && strings.EqualFold(cp.input[cp.pos:cp.pos+len(s)], s)
+}
+
+// word returns the run of non-delimiter characters at the current position
+func (cp *conditionalParser) word() string {
+	end := cp.pos
+	for end < cp.end && strings.IndexByte(conditionalDelimiters, cp.input[end]) < 0 {
+		end++
+	}
+	return cp.input[cp.pos:end]
+}
+
+func (cp *conditionalParser) emit(tok ConditionalToken) {
+	cp.tokens = append(cp.tokens, tok)
+}
+
+func (cp *conditionalParser) parseOr() error {
+	if err := cp.parseAnd(); err != nil {
+		return err
+	}
+	for {
+		cp.skipSpace()
+		if !cp.hasPrefix("||") {
+			return nil
+		}
+		cp.pos += 2
+		if err := cp.parseAnd(); err != nil {
+			return err
+		}
+		cp.emit(ConditionalToken{Type: ConditionalTokenOr})
+	}
+}
+
+func (cp *conditionalParser) parseAnd() error {
+	if err := cp.parseUnary(); err != nil {
+		return err
+	}
+	for {
+		cp.skipSpace()
+		if !cp.hasPrefix("&&") {
+			return nil
+		}
+		cp.pos += 2
+		if err := cp.parseUnary(); err != nil {
+			return err
+		}
+		cp.emit(ConditionalToken{Type: ConditionalTokenAnd})
+	}
+}
+
+func (cp *conditionalParser) parseUnary() error {
+	cp.skipSpace()
+	if cp.hasPrefix("!") && !cp.hasPrefix("!=") {
+		cp.pos++
+		if err := cp.parseUnary(); err != nil {
+			return err
+		}
+		cp.emit(ConditionalToken{Type: ConditionalTokenNot})
+		return nil
+	}
+	return cp.parseTerm()
+}
+
+// parseTerm reads a parenthesised sub-expression, a unary relational
+// operator and its operand, or an operand optionally followed by a binary
+// relational operator and a second operand
+func (cp *conditionalParser) parseTerm() error {
+	cp.skipSpace()
+	start := cp.pos
+
+	if cp.hasPrefix("(") {
+		cp.pos++
+		if err := cp.parseOr(); err != nil {
+			return err
+		}
+		cp.skipSpace()
+		if !cp.hasPrefix(")") {
+			return cp.errorf("expected ')' in conditional expression")
+		}
+		cp.pos++
+		return nil
+	}
+
+	word := cp.word()
+	if op, ok := conditionalUnaryOperators[strings.ToLower(word)]; ok {
+		cp.pos += len(word)
+		cp.skipSpace()
+		operandAt := cp.pos
+		operand, err := cp.parseOperand()
+		if err != nil {
+			return err
+		}
+		exists := op == ConditionalTokenExists || op == ConditionalTokenNotExists
+		if exists && !operand.IsAttribute() {
+			return cp.errorAt(operandAt, "%s requires an attribute", ConditionalTokenSDDL[op])
+		}
+		cp.emit(ConditionalToken{Type: op})
+		return nil
+	}
+
+	operand, err := cp.parseOperand()
+	if err != nil {
+		return err
+	}
+
+	cp.skipSpace()
+	op, width := cp.binaryOperator()
+	if width == 0 {
+		if !operand.IsAttribute() {
+			return cp.errorAt(start, "expected relational operator after literal")
+		}
+		return nil
+	}
+	cp.pos += width
+	cp.skipSpace()
+
+	if _, err := cp.parseOperand(); err != nil {
+		return err
+	}
+	cp.emit(ConditionalToken{Type: op})
+	return nil
+}
+
+// binaryOperator returns the binary relational operator at the current
+// position and its width, or a zero width when there is none
+func (cp *conditionalParser) binaryOperator() (ConditionalTokenType, int) {
+	for _, symbol := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if cp.hasPrefix(symbol) {
+			return conditionalBinaryOperators[symbol], len(symbol)
+		}
+	}
+
+	word := cp.word()
+	if op, ok := conditionalBinaryOperators[strings.ToLower(word)]; ok {
+		return op, len(word)
+	}
+	return 0, 0
+}
+
+// parseOperand reads an attribute reference or a literal and emits it
+func (cp *conditionalParser) parseOperand() (ConditionalToken, error) {
+	tok, err := cp.parseValue(true)
+	if err != nil {
+		return tok, err
+	}
+	cp.emit(tok)
+	return tok, nil
+}
+
+// parseValue reads a literal or, when allowed, an attribute reference
+func (cp *conditionalParser) parseValue(allowAttribute bool) (ConditionalToken, error) {
+	cp.skipSpace()
+	start := cp.pos
+	if cp.pos >= cp.end {
+		return ConditionalToken{}, cp.errorf("expected operand in conditional expression")
+	}
+
+	c := cp.input[cp.pos]
+	switch {
+	case c == '"':
+		closing := strings.IndexByte(cp.input[cp.pos+1:cp.end], '"')
+		if closing < 0 {
+			return ConditionalToken{}, cp.errorf("unterminated string literal")
+		}
+		text := cp.input[cp.pos+1 : cp.pos+1+closing]
+		cp.pos += closing + 2
+		return ConditionalToken{Type: ConditionalTokenUnicodeString, Text: text}, nil
+
+	case c == '#':
+		cp.pos++
+		digits := cp.word()
+		octets, err := hex.DecodeString(digits)
+		if err != nil {
+			return ConditionalToken{}, cp.errorAt(start, "invalid octet string literal")
+		}
+		cp.pos += len(digits)
+		return ConditionalToken{Type: ConditionalTokenOctetString, Octets: octets}, nil
+
+	case c == '{':
+		cp.pos++
+		tok := ConditionalToken{Type: ConditionalTokenComposite, Composite: []ConditionalToken{}}
+		for {
+			cp.skipSpace()
+			if cp.hasPrefix("}") && len(tok.Composite) == 0 {
+				cp.pos++
+				return tok, nil
+			}
+			elem, err := cp.parseValue(false)
+			if err != nil {
+				return tok, err
+			}
+			tok.Composite = append(tok.Composite, elem)
+
+			cp.skipSpace()
+			switch {
+			case cp.hasPrefix(","):
+				cp.pos++
+			case cp.hasPrefix("}"):
+				cp.pos++
+				return tok, nil
+			default:
+				return tok, cp.errorf("expected ',' or '}' in composite literal")
+			}
+		}
+
+	case cp.hasPrefix("SID("):
+		cp.pos += len("SID(")
+		closing := strings.IndexByte(cp.input[cp.pos:cp.end], ')')
+		if closing < 0 {
+			return ConditionalToken{}, cp.errorAt(start, "unterminated SID literal")
+		}
+		sid, err := cp.parseSID(strings.TrimSpace(cp.input[cp.pos:cp.pos+closing]), cp.pos)
+		if err != nil {
+			return ConditionalToken{}, err
+		}
+		cp.pos += closing + 1
+		return ConditionalToken{Type: ConditionalTokenSID, SID: sid}, nil
+
+	case c == '+' || c == '-' || (c >= '0' && c <= '9'):
+		return cp.parseInteger()
+
+	case !allowAttribute:
+		return ConditionalToken{}, cp.errorf("expected literal in conditional expression")
+	}
+
+	tok := ConditionalToken{Type: ConditionalTokenLocalAttribute}
+	for attrType, prefix := range ConditionalAttributePrefixSDDL {
+		if prefix != "" && cp.hasPrefix(prefix) {
+			tok.Type = attrType
+			cp.pos += len(prefix)
+			break
+		}
+	}
+
+	tok.Text = cp.word()
+	if tok.Text == "" {
+		return tok, cp.errorAt(start, "expected attribute name")
+	}
+	cp.pos += len(tok.Text)
+	return tok, nil
+}
+
+// parseInteger reads a signed decimal, hex (0x) or octal (leading 0)
+// integer literal
+func (cp *conditionalParser) parseInteger() (ConditionalToken, error) {
+	start := cp.pos
+	tok := ConditionalToken{
+		Type: ConditionalTokenInt64,
+		Sign: ConditionalIntSignNone,
+		Base: ConditionalIntBaseDecimal,
+	}
+
+	switch cp.input[cp.pos] {
+	case '+':
+		tok.Sign = ConditionalIntSignPlus
+		cp.pos++
+	case '-':
+		tok.Sign = ConditionalIntSignMinus
+		cp.pos++
+	}
+
+	digits := cp.word()
+	base := 10
+	switch {
+	case len(digits) > 2 && (digits[:2] == "0x" || digits[:2] == "0X"):
+		tok.Base = ConditionalIntBaseHex
+		base = 16
+		digits = digits[2:]
+	case len(digits) > 1 && digits[0] == '0':
+		tok.Base = ConditionalIntBaseOctal
+		base = 8
+		digits = digits[1:]
+	}
+
+	magnitude, err := strconv.ParseUint(digits, base, 64)
+	if err != nil || magnitude > 1<<63 || (magnitude == 1<<63 && tok.Sign != ConditionalIntSignMinus) {
+		return tok, cp.errorAt(start, "invalid integer literal")
+	}
+	cp.pos += len(cp.word())
+
+	tok.Int = int64(magnitude)
+	if tok.Sign == ConditionalIntSignMinus {
+		tok.Int = -tok.Int
+	}
+	return tok, nil
+}
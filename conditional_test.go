@@ -0,0 +1,153 @@
+package winacl_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConditionalExpressionFromSDDL(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Parses a relational expression into postfix tokens", func(t *testing.T) {
+		expr, err := winacl.NewConditionalExpressionFromSDDL(`(@User.Department == "Finance")`)
+		r.NoError(err)
+		r.Len(expr.Tokens, 3)
+		r.Equal(winacl.ConditionalTokenUserAttribute, expr.Tokens[0].Type)
+		r.Equal("Department", expr.Tokens[0].Text)
+		r.Equal(winacl.ConditionalTokenUnicodeString, expr.Tokens[1].Type)
+		r.Equal("Finance", expr.Tokens[1].Text)
+		r.Equal(winacl.ConditionalTokenEqual, expr.Tokens[2].Type)
+	})
+
+	t.Run("Round-trips SDDL", func(t *testing.T) {
+		for _, sddl := range []string{
+			`(WIN://SYSAPPID Contains "AB12")`,
+			`(@User.Department == "Finance")`,
+			`((@User.clearance >= 3) && (Member_of {SID(BA), SID(S-1-5-21-1-2-3-1001)}))`,
+			`(!(Exists @Device.managed))`,
+			`((@Resource.Project Any_of {"A", "B"}) || (@User.level < -0x10))`,
+			`(@User.smartcard)`,
+			`(@Device.blob == #00ff)`,
+		} {
+			expr, err := winacl.NewConditionalExpressionFromSDDL(sddl)
+			r.NoError(err, sddl)
+			r.Equal(sddl, expr.ToSDDL())
+		}
+	})
+
+	t.Run("Rejects malformed expressions", func(t *testing.T) {
+		for _, sddl := range []string{
+			`(@User.Department == )`,
+			`(@User.Department == "Finance"`,
+			`("Finance")`,
+			`(Exists "Finance")`,
+			`(@User.level == 99999999999999999999)`,
+		} {
+			_, err := winacl.NewConditionalExpressionFromSDDL(sddl)
+			r.Error(err, sddl)
+
+			var parseErr winacl.SDDLParseError
+			r.ErrorAs(err, &parseErr, sddl)
+		}
+	})
+}
+
+func TestConditionalExpressionBinary(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Encodes the artx format", func(t *testing.T) {
+		expr, err := winacl.NewConditionalExpressionFromSDDL(`(@User.a == 1)`)
+		r.NoError(err)
+
+		data, err := expr.MarshalBinary()
+		r.NoError(err)
+		expected := []byte{
+			'a', 'r', 't', 'x',
+			0xf9, 0x02, 0x00, 0x00, 0x00, 'a', 0x00,
+			0x04, 0x01, 0, 0, 0, 0, 0, 0, 0, 0x03, 0x02,
+			0x80,
+		}
+		r.Equal(expected, data[:len(expected)])
+		r.Zero(len(data) % 4)
+		r.Equal(make([]byte, len(data)-len(expected)), data[len(expected):])
+	})
+
+	t.Run("Round-trips through binary", func(t *testing.T) {
+		sddl := `((@User.clearance >= 3) && (Member_of {SID(SY), SID(BA)}))`
+		expr, err := winacl.NewConditionalExpressionFromSDDL(sddl)
+		r.NoError(err)
+
+		data, err := expr.MarshalBinary()
+		r.NoError(err)
+
+		var parsed winacl.ConditionalExpression
+		r.NoError(parsed.UnmarshalBinary(data))
+		r.Equal(sddl, parsed.ToSDDL())
+	})
+
+	t.Run("Rejects data without the signature or with dangling operators", func(t *testing.T) {
+		_, err := winacl.NewConditionalExpression([]byte{0x80})
+		r.Error(err)
+
+		_, err = winacl.NewConditionalExpression([]byte{'a', 'r', 't', 'x', 0x80})
+		r.Error(err)
+
+		_, err = winacl.NewConditionalExpression([]byte{'a', 'r', 't', 'x', 0xf9, 0xff, 0, 0, 0})
+		r.Error(err)
+	})
+}
+
+func TestCallbackACE(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Parses and renders the SDDL form", func(t *testing.T) {
+		sddl := `(XA;;FA;;;WD;(WIN://SYSAPPID Contains "AB12"))`
+		ace, err := winacl.NewACEFromSDDL(sddl)
+		r.NoError(err)
+		r.Equal(winacl.AceTypeAccessAllowedCallback, ace.GetType())
+		r.Equal(uint32(0x1F01FF), ace.AccessMask.Raw())
+		r.NotNil(ace.GetCondition())
+		r.Equal(sddl, ace.ToSDDL())
+		r.Contains(ace.String(), `Condition: (WIN://SYSAPPID Contains "AB12")`)
+	})
+
+	t.Run("Round-trips through binary without losing the expression", func(t *testing.T) {
+		sddl := `(XD;;GA;;;AU;(@User.Department == "Finance"))`
+		ace, err := winacl.NewACEFromSDDL(sddl)
+		r.NoError(err)
+
+		data, err := ace.MarshalBinary()
+		r.NoError(err)
+		r.Equal(ace.Header.Size, uint16(len(data)))
+
+		var parsed winacl.ACE
+		r.NoError(parsed.UnmarshalBinary(data))
+		r.Equal("S-1-5-11", parsed.ObjectAce.GetPrincipal().String())
+		r.Equal(sddl, parsed.ToSDDL())
+	})
+
+	t.Run("Keeps opaque application data", func(t *testing.T) {
+		ace, err := winacl.NewACEFromSDDL("(XA;;GA;;;WD)")
+		r.NoError(err)
+
+		basic := ace.ObjectAce.(winacl.BasicAce)
+		basic.ApplicationData = []byte{1, 2, 3, 4}
+		ace.ObjectAce = basic
+
+		data, err := ace.MarshalBinary()
+		r.NoError(err)
+
+		parsed, err := winacl.NewAce(bytes.NewBuffer(data))
+		r.NoError(err)
+		r.Nil(parsed.GetCondition())
+		r.Equal([]byte{1, 2, 3, 4}, parsed.ObjectAce.(winacl.BasicAce).ApplicationData)
+	})
+
+	t.Run("Rejects expressions on non-callback ACEs", func(t *testing.T) {
+		_, err := winacl.NewACEFromSDDL(`(A;;GA;;;WD;(@User.a == 1))`)
+		r.Error(err)
+	})
+}
@@ -0,0 +1,419 @@
+package winacl
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ConditionalResult is the three-valued outcome of evaluating a
+// conditional expression
+type ConditionalResult byte
+
+// ConditionalResult constants
+const (
+	ConditionalFalse ConditionalResult = iota
+	ConditionalTrue
+	ConditionalUnknown
+)
+
+// ConditionalResultLookup maps ConditionalResults to human-readable strings
+var ConditionalResultLookup = map[ConditionalResult]string{
+	ConditionalFalse:   "FALSE",
+	ConditionalTrue:    "TRUE",
+	ConditionalUnknown: "UNKNOWN",
+}
+
+// String returns the human-readable form of a ConditionalResult
+func (r ConditionalResult) String() string {
+	return ConditionalResultLookup[r]
+}
+
+func (r ConditionalResult) not() ConditionalResult {
+	switch r {
+	case ConditionalTrue:
+		return ConditionalFalse
+	case ConditionalFalse:
+		return ConditionalTrue
+	}
+	return ConditionalUnknown
+}
+
+func conditionalResultOf(b bool) ConditionalResult {
+	if b {
+		return ConditionalTrue
+	}
+	return ConditionalFalse
+}
+
+// ClaimValues holds the values of a claim or resource attribute. Each
+// value is a literal ConditionalToken, so claims compare directly against
+// the literals of an expression
+type ClaimValues []ConditionalToken
+
+// NewIntClaim creates the values of an integer claim
+func NewIntClaim(values ...int64) ClaimValues {
+	claim := make(ClaimValues, len(values))
+	for i, v := range values {
+		claim[i] = ConditionalToken{
+			Type: ConditionalTokenInt64,
+			Int:  v,
+			Sign: ConditionalIntSignNone,
+			Base: ConditionalIntBaseDecimal,
+		}
+	}
+	return claim
+}
+
+// NewBoolClaim creates the values of a boolean claim. Windows stores
+// booleans as the integers 0 and 1
+func NewBoolClaim(values ...bool) ClaimValues {
+	ints := make([]int64, len(values))
+	for i, v := range values {
+		if v {
+			ints[i] = 1
+		}
+	}
+	return NewIntClaim(ints...)
+}
+
+// NewStringClaim creates the values of a string claim
+func NewStringClaim(values ...string) ClaimValues {
+	claim := make(ClaimValues, len(values))
+	for i, v := range values {
+		claim[i] = ConditionalToken{Type: ConditionalTokenUnicodeString, Text: v}
+	}
+	return claim
+}
+
+// NewSIDClaim creates the values of a SID claim
+func NewSIDClaim(values ...SID) ClaimValues {
+	claim := make(ClaimValues, len(values))
+	for i, v := range values {
+		claim[i] = ConditionalToken{Type: ConditionalTokenSID, SID: v}
+	}
+	return claim
+}
+
+// NewOctetClaim creates the values of an octet string claim
+func NewOctetClaim(values ...[]byte) ClaimValues {
+	claim := make(ClaimValues, len(values))
+	for i, v := range values {
+		claim[i] = ConditionalToken{Type: ConditionalTokenOctetString, Octets: v}
+	}
+	return claim
+}
+
+// lookupClaim finds a claim by name. Claim names are case-insensitive
+func lookupClaim(claims map[string]ClaimValues, name string) (ClaimValues, bool) {
+	if values, ok := claims[name]; ok {
+		return values, true
+	}
+	for claimName, values := range claims {
+		if strings.EqualFold(claimName, name) {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+// conditionalContext holds what an expression is evaluated against
+type conditionalContext struct {
+	token    *TokenUser
+	resource map[string]ClaimValues
+	deny     bool // Whether the expression belongs to a deny ACE
+}
+
+// conditionalValue is an operand resolved to its values. Attributes
+// missing from the context are unknown
+type conditionalValue struct {
+	values  []ConditionalToken
+	set     bool
+	unknown bool
+}
+
+// Evaluate evaluates the expression against a token's claims and groups
+// and an object's resource attributes, following the three-valued logic
+// of MS-DTYP. Attributes missing from the token or resource, and
+// comparisons between mismatched types, evaluate to UNKNOWN
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/62d9e6c1-7fc0-4e63-b5ff-e9cfde3ee4a8
+func (e ConditionalExpression) Evaluate(token *TokenUser, resource map[string]ClaimValues) ConditionalResult {
+	return e.evaluate(token, resource, false)
+}
+
+// evaluate evaluates the expression for an allow or deny ACE. Deny-only
+// groups are only considered members of a group by deny ACEs
+func (e ConditionalExpression) evaluate(token *TokenUser, resource map[string]ClaimValues, deny bool) ConditionalResult {
+	root, err := e.tree()
+	if err != nil || token == nil {
+		return ConditionalUnknown
+	}
+	ctx := conditionalContext{token: token, resource: resource, deny: deny}
+	return ctx.evaluate(root)
+}
+
+func (ctx conditionalContext) evaluate(n *conditionalNode) ConditionalResult {
+	tok := n.token
+
+	switch tok.Type {
+	case ConditionalTokenAnd:
+		left, right := ctx.evaluate(n.operands[0]), ctx.evaluate(n.operands[1])
+		switch {
+		case left == ConditionalFalse || right == ConditionalFalse:
+			return ConditionalFalse
+		case left == ConditionalTrue && right == ConditionalTrue:
+			return ConditionalTrue
+		}
+		return ConditionalUnknown
+
+	case ConditionalTokenOr:
+		left, right := ctx.evaluate(n.operands[0]), ctx.evaluate(n.operands[1])
+		switch {
+		case left == ConditionalTrue || right == ConditionalTrue:
+			return ConditionalTrue
+		case left == ConditionalFalse && right == ConditionalFalse:
+			return ConditionalFalse
+		}
+		return ConditionalUnknown
+
+	case ConditionalTokenNot:
+		return ctx.evaluate(n.operands[0]).not()
+
+	case ConditionalTokenExists:
+		return conditionalResultOf(!ctx.resolve(n.operands[0].token).unknown)
+	case ConditionalTokenNotExists:
+		return conditionalResultOf(ctx.resolve(n.operands[0].token).unknown)
+
+	case ConditionalTokenMemberOf:
+		return ctx.memberOf(n.operands[0], ctx.tokenSIDs(), true)
+	case ConditionalTokenMemberOfAny:
+		return ctx.memberOf(n.operands[0], ctx.tokenSIDs(), false)
+	case ConditionalTokenDeviceMemberOf:
+		return ctx.memberOf(n.operands[0], ctx.token.DeviceGroups, true)
+	case ConditionalTokenDeviceMemberOfAny:
+		return ctx.memberOf(n.operands[0], ctx.token.DeviceGroups, false)
+	case ConditionalTokenNotMemberOf:
+		return ctx.memberOf(n.operands[0], ctx.tokenSIDs(), true).not()
+	case ConditionalTokenNotMemberOfAny:
+		return ctx.memberOf(n.operands[0], ctx.tokenSIDs(), false).not()
+	case ConditionalTokenNotDeviceMemberOf:
+		return ctx.memberOf(n.operands[0], ctx.token.DeviceGroups, true).not()
+	case ConditionalTokenNotDeviceMemberOfAny:
+		return ctx.memberOf(n.operands[0], ctx.token.DeviceGroups, false).not()
+	}
+
+	if len(n.operands) == 2 {
+		left := ctx.resolve(n.operands[0].token)
+		right := ctx.resolve(n.operands[1].token)
+		if left.unknown || right.unknown {
+			return ConditionalUnknown
+		}
+		return compareConditionalValues(tok.Type, left, right)
+	}
+
+	// A lone attribute or literal is true when it holds a single non-zero,
+	// non-empty value
+	return ctx.resolve(tok).truth()
+}
+
+// resolve returns the values of an attribute or literal token
+func (ctx conditionalContext) resolve(tok ConditionalToken) conditionalValue {
+	var claims map[string]ClaimValues
+
+	switch tok.Type {
+	case ConditionalTokenUserAttribute:
+		claims = ctx.token.UserClaims
+	case ConditionalTokenDeviceAttribute:
+		claims = ctx.token.DeviceClaims
+	case ConditionalTokenLocalAttribute:
+		claims = ctx.token.LocalClaims
+	case ConditionalTokenResourceAttribute:
+		claims = ctx.resource
+	case ConditionalTokenComposite:
+		return conditionalValue{values: tok.Composite, set: true}
+	default:
+		return conditionalValue{values: []ConditionalToken{tok}}
+	}
+
+	values, ok := lookupClaim(claims, tok.Text)
+	if !ok || len(values) == 0 {
+		return conditionalValue{unknown: true}
+	}
+	return conditionalValue{values: values, set: len(values) > 1}
+}
+
+func (v conditionalValue) truth() ConditionalResult {
+	if v.unknown || len(v.values) != 1 {
+		return ConditionalUnknown
+	}
+
+	val := v.values[0]
+	switch {
+	case val.IsInteger():
+		return conditionalResultOf(val.Int != 0)
+	case val.Type == ConditionalTokenUnicodeString:
+		return conditionalResultOf(val.Text != "")
+	case val.Type == ConditionalTokenOctetString:
+		return conditionalResultOf(len(val.Octets) != 0)
+	case val.Type == ConditionalTokenSID:
+		return ConditionalTrue
+	}
+	return ConditionalUnknown
+}
+
+// tokenSIDs returns the SIDs Member_of checks against: the user, its
+// groups and Everyone, which aceAppliesToToken also treats as implicit.
+// Groups that would not match the ACE's SID are left out, and the
+// restricting SIDs and AppContainer passes only use their own SIDs
+func (ctx conditionalContext) tokenSIDs() []SID {
+	if ctx.token.pass != tokenPassNormal {
+		return ctx.token.passSIDs()
+	}
+
+	everyone, _ := NewSIDFromString("S-1-1-0")
+	sids := make([]SID, 0, len(ctx.token.Groups)+2)
+	sids = append(sids, ctx.token.UserSID)
+	if _, held := ctx.token.GroupAttributes[everyone.Key()]; !held {
+		sids = append(sids, everyone)
+	}
+
+	for _, group := range ctx.token.Groups {
+		if matches, _ := ctx.token.groupMatches(group, ctx.deny); matches {
+			sids = append(sids, group)
+		}
+	}
+	return sids
+}
+
+// memberOf checks the SIDs of an operand against those held. With all
+// set, every SID must be held; otherwise any one suffices
+func (ctx conditionalContext) memberOf(operand *conditionalNode, held []SID, all bool) ConditionalResult {
+	v := ctx.resolve(operand.token)
+	if v.unknown || len(v.values) == 0 {
+		return ConditionalUnknown
+	}
+
+	matched := 0
+	for _, val := range v.values {
+		if val.Type != ConditionalTokenSID {
+			return ConditionalUnknown
+		}
+		for _, sid := range held {
+			if sid.Equal(val.SID) {
+				matched++
+				break
+			}
+		}
+	}
+
+	if all {
+		return conditionalResultOf(matched == len(v.values))
+	}
+	return conditionalResultOf(matched > 0)
+}
+
+// compareConditionalValues applies a binary relational operator
+func compareConditionalValues(op ConditionalTokenType, left, right conditionalValue) ConditionalResult {
+	switch op {
+	case ConditionalTokenEqual, ConditionalTokenNotEqual:
+		var result ConditionalResult
+		if !left.set && !right.set {
+			equal, ok := equalConditionalTokens(left.values[0], right.values[0])
+			if !ok {
+				return ConditionalUnknown
+			}
+			result = conditionalResultOf(equal)
+		} else {
+			result = conditionalResultOf(containsAll(left.values, right.values) &&
+				containsAll(right.values, left.values))
+		}
+		if op == ConditionalTokenNotEqual {
+			return result.not()
+		}
+		return result
+
+	case ConditionalTokenLessThan, ConditionalTokenLessThanOrEqual,
+		ConditionalTokenGreaterThan, ConditionalTokenGreaterThanOrEqual:
+		if left.set || right.set {
+			return ConditionalUnknown
+		}
+		cmp, ok := orderConditionalTokens(left.values[0], right.values[0])
+		if !ok {
+			return ConditionalUnknown
+		}
+		switch op {
+		case ConditionalTokenLessThan:
+			return conditionalResultOf(cmp < 0)
+		case ConditionalTokenLessThanOrEqual:
+			return conditionalResultOf(cmp <= 0)
+		case ConditionalTokenGreaterThan:
+			return conditionalResultOf(cmp > 0)
+		}
+		return conditionalResultOf(cmp >= 0)
+
+	case ConditionalTokenContains:
+		return conditionalResultOf(containsAll(left.values, right.values))
+	case ConditionalTokenNotContains:
+		return conditionalResultOf(!containsAll(left.values, right.values))
+	case ConditionalTokenAnyOf:
+		return conditionalResultOf(containsAny(right.values, left.values))
+	case ConditionalTokenNotAnyOf:
+		return conditionalResultOf(!containsAny(right.values, left.values))
+	}
+	return ConditionalUnknown
+}
+
+// containsAll reports whether every value of want is in have
+func containsAll(have, want []ConditionalToken) bool {
+	for _, w := range want {
+		if !containsAny(have, []ConditionalToken{w}) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAny reports whether any value of want is in have
+func containsAny(have, want []ConditionalToken) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if equal, _ := equalConditionalTokens(h, w); equal {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// equalConditionalTokens compares two literal values. ok is false when
+// their types cannot be compared
+func equalConditionalTokens(a, b ConditionalToken) (equal bool, ok bool) {
+	switch {
+	case a.Type == ConditionalTokenSID && b.Type == ConditionalTokenSID:
+		return a.SID.Equal(b.SID), true
+	case a.Type == ConditionalTokenOctetString && b.Type == ConditionalTokenOctetString:
+		return bytes.Equal(a.Octets, b.Octets), true
+	}
+
+	cmp, ok := orderConditionalTokens(a, b)
+	return ok && cmp == 0, ok
+}
+
+// orderConditionalTokens orders two integer or two string values. Strings
+// compare case-insensitively, as Windows does by default
+func orderConditionalTokens(a, b ConditionalToken) (int, bool) {
+	switch {
+	case a.IsInteger() && b.IsInteger():
+		switch {
+		case a.Int < b.Int:
+			return -1, true
+		case a.Int > b.Int:
+			return 1, true
+		}
+		return 0, true
+
+	case a.Type == ConditionalTokenUnicodeString && b.Type == ConditionalTokenUnicodeString:
+		return strings.Compare(strings.ToLower(a.Text), strings.ToLower(b.Text)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,83 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalExpressionEvaluate(t *testing.T) {
+	r := require.New(t)
+
+	userSID := mustSID(t, "S-1-5-21-1-2-3-1001")
+	groupSID := mustSID(t, "S-1-5-32-544")
+	deviceSID := mustSID(t, "S-1-5-21-1-2-3-515")
+
+	token := winacl.NewTokenUser(userSID, []winacl.SID{groupSID})
+	token.UserClaims = map[string]winacl.ClaimValues{
+		"Department": winacl.NewStringClaim("Finance"),
+		"Projects":   winacl.NewStringClaim("Apollo", "Gemini"),
+		"clearance":  winacl.NewIntClaim(3),
+		"smartcard":  winacl.NewBoolClaim(true),
+	}
+	token.DeviceClaims = map[string]winacl.ClaimValues{
+		"managed": winacl.NewBoolClaim(false),
+	}
+	token.LocalClaims = map[string]winacl.ClaimValues{
+		"WIN://SYSAPPID": winacl.NewStringClaim("AB12", "CD34"),
+	}
+	token.DeviceGroups = []winacl.SID{deviceSID}
+
+	resource := map[string]winacl.ClaimValues{
+		"Project": winacl.NewStringClaim("Gemini"),
+	}
+
+	cases := []struct {
+		sddl     string
+		expected winacl.ConditionalResult
+	}{
+		{`(@User.Department == "finance")`, winacl.ConditionalTrue},
+		{`(@User.Department != "Finance")`, winacl.ConditionalFalse},
+		{`(@User.Title == "Manager")`, winacl.ConditionalUnknown},
+		{`(@User.clearance >= 3)`, winacl.ConditionalTrue},
+		{`(@User.clearance < 0x3)`, winacl.ConditionalFalse},
+		{`(@User.clearance == "3")`, winacl.ConditionalUnknown},
+		{`(@User.smartcard)`, winacl.ConditionalTrue},
+		{`(@Device.managed)`, winacl.ConditionalFalse},
+		{`(WIN://SYSAPPID Contains "AB12")`, winacl.ConditionalTrue},
+		{`(WIN://SYSAPPID Contains {"AB12", "EF56"})`, winacl.ConditionalFalse},
+		{`(@User.Projects Any_of {"Mercury", "Gemini"})`, winacl.ConditionalTrue},
+		{`(@User.Projects Not_Any_of {"Mercury"})`, winacl.ConditionalTrue},
+		{`(@User.Projects Contains @Resource.Project)`, winacl.ConditionalTrue},
+		{`(Exists @User.Department)`, winacl.ConditionalTrue},
+		{`(Exists @User.Title)`, winacl.ConditionalFalse},
+		{`(Not_Exists @User.Title)`, winacl.ConditionalTrue},
+		{`(Member_of {SID(BA), SID(WD)})`, winacl.ConditionalTrue},
+		{`(Member_of {SID(BA), SID(SY)})`, winacl.ConditionalFalse},
+		{`(Member_of_Any {SID(BA), SID(SY)})`, winacl.ConditionalTrue},
+		{`(Device_Member_of {SID(S-1-5-21-1-2-3-515)})`, winacl.ConditionalTrue},
+		{`(Not_Device_Member_of {SID(BA)})`, winacl.ConditionalTrue},
+
+		// Three-valued logic
+		{`((@User.Title == "Manager") && (@User.clearance == 4))`, winacl.ConditionalFalse},
+		{`((@User.Title == "Manager") && (@User.clearance == 3))`, winacl.ConditionalUnknown},
+		{`((@User.Title == "Manager") || (@User.clearance == 3))`, winacl.ConditionalTrue},
+		{`((@User.Title == "Manager") || (@User.clearance == 4))`, winacl.ConditionalUnknown},
+		{`(!(@User.Title == "Manager"))`, winacl.ConditionalUnknown},
+		{`(!(@User.clearance == 4))`, winacl.ConditionalTrue},
+	}
+
+	for _, tc := range cases {
+		expr, err := winacl.NewConditionalExpressionFromSDDL(tc.sddl)
+		r.NoError(err, tc.sddl)
+		r.Equal(tc.expected, expr.Evaluate(token, resource), tc.sddl)
+	}
+
+	t.Run("Unknown without a token", func(t *testing.T) {
+		expr, err := winacl.NewConditionalExpressionFromSDDL(`(Exists @User.Department)`)
+		r.NoError(err)
+		r.Equal(winacl.ConditionalUnknown, expr.Evaluate(nil, nil))
+		r.Equal("UNKNOWN", winacl.ConditionalUnknown.String())
+	})
+}
@@ -0,0 +1,141 @@
+package winacl
+
+import (
+	"strings"
+)
+
+// SecurableObjectType is the kind of object a security descriptor protects,
+// like Windows' SE_OBJECT_TYPE. It selects how generic rights are mapped
+// and how access masks are named
+type SecurableObjectType int
+
+// SecurableObjectType constants. SecurableGeneric keeps the object-agnostic
+// behaviour, with the mapping of DefaultAccessCheckOptions
+const (
+	SecurableGeneric SecurableObjectType = iota
+	SecurableFile
+	SecurableDirectory
+	SecurableRegistryKey
+	SecurableService
+	SecurableServiceControlManager
+	SecurableProcess
+	SecurableThread
+	SecurableToken
+	SecurableNamedPipe
+	SecurableDSObject
+	SecurablePrinter
+	SecurableWMINamespace
+	SecurableDCOM
+)
+
+// SecurableObjectTypeLookup maps SecurableObjectTypes to their names
+var SecurableObjectTypeLookup = map[SecurableObjectType]string{
+	SecurableGeneric:               "Generic",
+	SecurableFile:                  "File",
+	SecurableDirectory:             "Directory",
+	SecurableRegistryKey:           "RegistryKey",
+	SecurableService:               "Service",
+	SecurableServiceControlManager: "ServiceControlManager",
+	SecurableProcess:               "Process",
+	SecurableThread:                "Thread",
+	SecurableToken:                 "Token",
+	SecurableNamedPipe:             "NamedPipe",
+	SecurableDSObject:              "DSObject",
+	SecurablePrinter:               "Printer",
+	SecurableWMINamespace:          "WMINamespace",
+	SecurableDCOM:                  "DCOM",
+}
+
+func (t SecurableObjectType) String() string {
+	return SecurableObjectTypeLookup[t]
+}
+
+// GenericMapping is the GENERIC_MAPPING of an object type: the specific
+// rights each generic right stands for
+//
+// https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-generic_mapping
+type GenericMapping struct {
+	GenericRead    uint32
+	GenericWrite   uint32
+	GenericExecute uint32
+	GenericAll     uint32
+}
+
+// Map returns the mapping in the form used by AccessCheckOptions and
+// MapGenericAccess
+func (m GenericMapping) Map() map[uint32]uint32 {
+	return map[uint32]uint32{
+		AccessMaskGenericRead:    m.GenericRead,
+		AccessMaskGenericWrite:   m.GenericWrite,
+		AccessMaskGenericExecute: m.GenericExecute,
+		AccessMaskGenericAll:     m.GenericAll,
+	}
+}
+
+// GenericMappings holds the generic mappings Windows applies to each object
+// type. Files, directories and named pipes share the I/O manager's file
+// mapping
+var GenericMappings = map[SecurableObjectType]GenericMapping{
+	SecurableFile:                  fileGenericMapping,
+	SecurableDirectory:             fileGenericMapping,
+	SecurableNamedPipe:             fileGenericMapping,
+	SecurableRegistryKey:           {0x00020019, 0x00020006, 0x00020019, 0x000F003F}, // KEY_READ, KEY_WRITE, KEY_EXECUTE, KEY_ALL_ACCESS
+	SecurableService:               {0x0002008D, 0x00020002, 0x00020170, 0x000F01FF}, // SERVICE_ALL_ACCESS
+	SecurableServiceControlManager: {0x00020014, 0x00020022, 0x00020009, 0x000F003F}, // SC_MANAGER_ALL_ACCESS
+	SecurableProcess:               {0x00020410, 0x00020BEA, 0x00121001, 0x001FFFFF}, // PROCESS_ALL_ACCESS
+	SecurableThread:                {0x00020048, 0x00020437, 0x00121800, 0x001FFFFF}, // THREAD_ALL_ACCESS
+	SecurableToken:                 {0x00020008, 0x000200E0, 0x00020000, 0x000F01FF}, // TOKEN_READ, TOKEN_WRITE, TOKEN_EXECUTE, TOKEN_ALL_ACCESS
+	SecurableDSObject:              {0x00020094, 0x00020028, 0x00020004, 0x000F01FF}, // DS_GENERIC_READ, DS_GENERIC_WRITE, DS_GENERIC_EXECUTE, DS_GENERIC_ALL
+	SecurablePrinter:               {0x00020008, 0x00020008, 0x00020008, 0x000F000C}, // PRINTER_READ, PRINTER_WRITE, PRINTER_EXECUTE, PRINTER_ALL_ACCESS
+	SecurableWMINamespace:          {0x00020021, 0x0000001C, 0x00000002, 0x0006003F}, // WBEM_ENABLE|WBEM_REMOTE_ACCESS, WBEM_*_WRITE_*, WBEM_METHOD_EXECUTE
+	SecurableDCOM:                  {0x00000001, 0x00000001, 0x00000001, 0x0000001F}, // COM_RIGHTS_EXECUTE, COM_RIGHTS_*
+}
+
+// fileGenericMapping is FILE_GENERIC_READ, FILE_GENERIC_WRITE,
+// FILE_GENERIC_EXECUTE and FILE_ALL_ACCESS
+var fileGenericMapping = GenericMapping{0x00120089, 0x00120116, 0x001200A0, 0x001F01FF}
+
+// GenericMappingFor returns the generic mapping of an object type, as used
+// by AccessCheckOptions. SecurableGeneric and unknown types get the
+// mapping of DefaultAccessCheckOptions
+func GenericMappingFor(objectType SecurableObjectType) map[uint32]uint32 {
+	if mapping, ok := GenericMappings[objectType]; ok {
+		return mapping.Map()
+	}
+	return defaultGenericMapping()
+}
+
+// defaultGenericMapping is the object-agnostic mapping used when no object
+// type is given
+func defaultGenericMapping() map[uint32]uint32 {
+	return map[uint32]uint32{
+		AccessMaskGenericRead:    AccessMaskReadControl,
+		AccessMaskGenericWrite:   AccessMaskWriteDACL | AccessMaskWriteOwner,
+		AccessMaskGenericExecute: AccessMaskSynchronize,
+		AccessMaskGenericAll:     0xFFFFFFFF,
+	}
+}
+
+// DefaultAccessCheckOptionsFor returns the default access check options for
+// an object type, with its generic mapping
+func DefaultAccessCheckOptionsFor(objectType SecurableObjectType) *AccessCheckOptions {
+	options := DefaultAccessCheckOptions()
+	options.ObjectType = objectType
+	options.GenericMapping = GenericMappingFor(objectType)
+	return options
+}
+
+// StringFor returns the access mask's human-readable rights for an object
+// type, with generic rights mapped to the specific rights they grant
+func (am ACEAccessMask) StringFor(objectType SecurableObjectType) string {
+	return strings.Join(ACEAccessMask{Value: am.Mapped(objectType)}.StringSliceFor(objectType), " ")
+}
+
+// Mapped returns the access mask with its generic rights mapped to the
+// specific rights of an object type
+func (am ACEAccessMask) Mapped(objectType SecurableObjectType) uint32 {
+	if objectType == SecurableGeneric {
+		return am.Value
+	}
+	return MapGenericAccess(am.Value, GenericMappingFor(objectType))
+}
@@ -0,0 +1,81 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenericMappings(t *testing.T) {
+	r := require.New(t)
+
+	token := winacl.NewTokenUser(mustSID(t, "S-1-5-21-1-2-3-1001"), nil)
+
+	t.Run("Tables", func(t *testing.T) {
+		file := winacl.GenericMappingFor(winacl.SecurableFile)
+		r.Equal(uint32(0x00120089), file[winacl.AccessMaskGenericRead])
+		r.Equal(uint32(0x001F01FF), file[winacl.AccessMaskGenericAll])
+		r.Equal(file, winacl.GenericMappingFor(winacl.SecurableNamedPipe))
+
+		key := winacl.GenericMappingFor(winacl.SecurableRegistryKey)
+		r.Equal(uint32(0x00020006), key[winacl.AccessMaskGenericWrite])
+
+		// PROCESS_QUERY_LIMITED_INFORMATION, PROCESS_TERMINATE, THREAD_RESUME
+		// and THREAD_QUERY_LIMITED_INFORMATION are execute rights
+		process := winacl.GenericMappingFor(winacl.SecurableProcess)
+		r.Equal(uint32(0x00020410), process[winacl.AccessMaskGenericRead])
+		r.Equal(uint32(0x00020BEA), process[winacl.AccessMaskGenericWrite])
+		r.Equal(uint32(0x00121001), process[winacl.AccessMaskGenericExecute])
+		thread := winacl.GenericMappingFor(winacl.SecurableThread)
+		r.Equal(uint32(0x00020048), thread[winacl.AccessMaskGenericRead])
+		r.Equal(uint32(0x00020437), thread[winacl.AccessMaskGenericWrite])
+		r.Equal(uint32(0x00121800), thread[winacl.AccessMaskGenericExecute])
+
+		r.Equal(winacl.DefaultAccessCheckOptions().GenericMapping, winacl.GenericMappingFor(winacl.SecurableGeneric))
+
+		for objectType := range winacl.GenericMappings {
+			r.NotEmpty(objectType.String())
+		}
+	})
+
+	t.Run("AccessCheck maps generic rights for the object type", func(t *testing.T) {
+		options := winacl.DefaultAccessCheckOptionsFor(winacl.SecurableFile)
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;FR;;;WD)", token, winacl.AccessMaskGenericRead, options).Granted)
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;FR;;;WD)", token, winacl.AccessMaskGenericWrite, options).Granted)
+
+		// GENERIC_READ in an ACE grants FILE_READ_DATA
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;GR;;;WD)", token, 0x1, options).Granted)
+
+		options = winacl.DefaultAccessCheckOptions()
+		options.ObjectType = winacl.SecurableRegistryKey
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;KR;;;WD)", token, winacl.AccessMaskGenericRead, options).Granted)
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;KR;;;WD)", token, winacl.AccessMaskGenericAll, options).Granted)
+	})
+
+	t.Run("Maximum allowed with GENERIC_ALL", func(t *testing.T) {
+		options := winacl.DefaultAccessCheckOptionsFor(winacl.SecurableFile)
+		result := accessCheckSDDL(t, "O:SYD:(A;;GA;;;WD)", token, winacl.AccessMaskMaximumAllowed, options)
+		r.Equal(uint32(0x001F01FF), result.Access)
+	})
+
+	t.Run("Access mask strings", func(t *testing.T) {
+		mask := winacl.ACEAccessMask{Value: winacl.AccessMaskGenericExecute}
+		r.Equal(uint32(0x001200A0), mask.Mapped(winacl.SecurableFile))
+		r.Equal(uint32(winacl.AccessMaskGenericExecute), mask.Mapped(winacl.SecurableGeneric))
+		r.Equal("FILE_GENERIC_EXECUTE", mask.StringFor(winacl.SecurableFile))
+		r.Equal("GENERIC_EXECUTE", mask.StringFor(winacl.SecurableGeneric))
+	})
+
+	t.Run("SDDL rights", func(t *testing.T) {
+		ntsd := mustParseSDDL(t, "O:S-1-5-18D:(A;;FA;;;WD)(A;;KR;;;BA)")
+
+		r.Equal("O:S-1-5-18D:(A;;FA;;;WD)(A;;KR;;;BA)", ntsd.ToSDDL())
+		r.Equal("O:S-1-5-18D:(A;;FA;;;WD)(A;;CCSWRPRC;;;BA)", ntsd.ToSDDLFor(winacl.SecurableFile))
+		r.Equal("O:S-1-5-18D:(A;;0x1f01ff;;;WD)(A;;KR;;;BA)", ntsd.ToSDDLFor(winacl.SecurableRegistryKey))
+
+		// Bits without an abbreviation are written in hexadecimal
+		roundTrip := mustParseSDDL(t, ntsd.ToSDDLFor(winacl.SecurableRegistryKey))
+		r.Equal(ntsd.DACL.Aces[0].AccessMask.Value, roundTrip.DACL.Aces[0].AccessMask.Value)
+	})
+}
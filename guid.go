@@ -3,7 +3,9 @@ package winacl
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 // GUID holds the various parts of a GUID
@@ -37,6 +39,53 @@ func NewGUID(buf *bytes.Buffer) (guid GUID, err error) {
 	return
 }
 
+// NewGUIDFromString creates a GUID from its string representation
+// Format: 4c164200-20c0-11d0-a768-00aa006e0529, optionally wrapped in braces
+func NewGUIDFromString(guidStr string) (GUID, error) {
+	guid := GUID{}
+	s := strings.TrimSuffix(strings.TrimPrefix(guidStr, "{"), "}")
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 ||
+		len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return guid, fmt.Errorf("invalid GUID format: %q", guidStr)
+	}
+
+	raw, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		return guid, fmt.Errorf("invalid GUID format: %q", guidStr)
+	}
+
+	// The string form is big-endian throughout
+	guid.Data1 = binary.BigEndian.Uint32(raw[0:4])
+	guid.Data2 = binary.BigEndian.Uint16(raw[4:6])
+	guid.Data3 = binary.BigEndian.Uint16(raw[6:8])
+	copy(guid.Data4[:], raw[8:16])
+	return guid, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, returning the GUID in
+// its 16-byte little-endian wire form
+func (g GUID) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := binary.Write(&buf, binary.LittleEndian, g)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, parsing a GUID
+// from its 16-byte little-endian wire form
+func (g *GUID) UnmarshalBinary(data []byte) error {
+	if len(data) != 16 {
+		return fmt.Errorf("invalid GUID length: %d", len(data))
+	}
+	guid, err := NewGUID(bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	*g = guid
+	return nil
+}
+
 // String will return the human-readable version of a GUID
 // It returns an empty string in case of a null-initialized
 // GUID
@@ -96,3 +96,27 @@ func TestGUIDResolve(t *testing.T) {
 		r.Equal("99999999-9999-9999-9999-999999999999", guid.Resolve())
 	})
 }
+
+func TestGUIDMarshalBinary(t *testing.T) {
+	r := require.New(t)
+
+	guid := winacl.GUID{
+		Data1: 0x12345678,
+		Data2: 0x1234,
+		Data3: 0x5678,
+		Data4: [8]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+
+	out, err := guid.MarshalBinary()
+	r.NoError(err)
+	r.Equal([]byte{
+		0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+	}, out)
+
+	var parsed winacl.GUID
+	r.NoError(parsed.UnmarshalBinary(out))
+	r.Equal(guid, parsed)
+
+	r.Error(parsed.UnmarshalBinary(out[:8]))
+}
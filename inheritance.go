@@ -0,0 +1,363 @@
+package winacl
+
+import (
+	"fmt"
+)
+
+// AutoInheritFlags control how CreatePrivateObjectSecurity combines the
+// parent's and creator's descriptors, like the SEF_* flags of
+// CreatePrivateObjectSecurityEx
+type AutoInheritFlags uint32
+
+// AutoInheritFlags constants
+const (
+	// AutoInheritDACL merges inherited ACEs into an explicit creator DACL
+	// and marks the new DACL SE_DACL_AUTO_INHERITED
+	AutoInheritDACL AutoInheritFlags = 0x01
+	// AutoInheritSACL is AutoInheritDACL for the SACL
+	AutoInheritSACL AutoInheritFlags = 0x02
+	// AutoInheritDefaultOwnerFromParent takes the owner from the parent,
+	// rather than the token, when the creator does not give one
+	AutoInheritDefaultOwnerFromParent AutoInheritFlags = 0x20
+	// AutoInheritDefaultGroupFromParent takes the group from the parent,
+	// rather than the token, when the creator does not give one
+	AutoInheritDefaultGroupFromParent AutoInheritFlags = 0x40
+)
+
+// InheritanceOptions configures CreatePrivateObjectSecurity
+type InheritanceOptions struct {
+	Flags          AutoInheritFlags
+	ObjectType     SecurableObjectType // When set, selects GenericMapping from GenericMappings
+	GenericMapping map[uint32]uint32   // Maps generic rights in effective inherited ACEs; nil leaves them as they are
+}
+
+// DefaultInheritanceOptions returns the options used when none are given:
+// DACL and SACL auto-inheritance, as Windows uses for files, registry keys
+// and directory objects
+func DefaultInheritanceOptions() *InheritanceOptions {
+	return &InheritanceOptions{
+		Flags: AutoInheritDACL | AutoInheritSACL,
+	}
+}
+
+// inheritanceFlagsMask holds the ACE flags that control inheritance
+const inheritanceFlagsMask = ACEHeaderFlagsObjectInheritAce | ACEHeaderFlagsContainerInheritAce |
+	ACEHeaderFlagsNoPropogateInheritAce | ACEHeaderFlagsInheritOnlyAce
+
+// CreatePrivateObjectSecurity computes the security descriptor of an object
+// created under parent, following CreatePrivateObjectSecurityEx. creator
+// holds the descriptor the caller asked for and may be nil. objectType is
+// the object's class GUID, used to filter object ACEs by their inherited
+// object type, and may be nil. token supplies the default owner, primary
+// group and default DACL. The owner is not checked against the token
+//
+// https://docs.microsoft.com/en-us/windows/win32/api/securitybaseapi/nf-securitybaseapi-createprivateobjectsecurityex
+func CreatePrivateObjectSecurity(parent, creator *NtSecurityDescriptor, isContainer bool,
+	objectType *GUID, token *TokenUser, options *InheritanceOptions,
+) (NtSecurityDescriptor, error) {
+	if options == nil {
+		options = DefaultInheritanceOptions()
+	}
+	mapping := options.GenericMapping
+	if mapping == nil && options.ObjectType != SecurableGeneric {
+		mapping = GenericMappingFor(options.ObjectType)
+	}
+
+	child := NtSecurityDescriptor{}
+	child.Header.Revision = 1
+
+	child.Owner = newObjectOwner(parent, creator, token, options.Flags)
+	child.Group = newObjectGroup(parent, creator, token, options.Flags)
+
+	inherit := aceInheritance{
+		isContainer: isContainer,
+		objectType:  objectType,
+		owner:       child.Owner,
+		group:       child.Group,
+		mapping:     mapping,
+	}
+
+	dacl := aclInheritance{
+		present:       DACLPresent,
+		defaulted:     DACLDefaulted,
+		protected:     DACLProtected,
+		autoInherited: DACLAutoInherited,
+		autoInherit:   options.Flags&AutoInheritDACL != 0,
+	}
+	var defaultDACL *ACL
+	if token != nil {
+		defaultDACL = token.DefaultDACL
+	}
+	if err := dacl.compute(&child, parent, creator, inherit, defaultDACL); err != nil {
+		return child, fmt.Errorf("computing DACL: %w", err)
+	}
+
+	sacl := aclInheritance{
+		present:       SACLPresent,
+		defaulted:     SACLDefaulted,
+		protected:     SACLProtected,
+		autoInherited: SACLAutoInherited,
+		autoInherit:   options.Flags&AutoInheritSACL != 0,
+		sacl:          true,
+	}
+	if err := sacl.compute(&child, parent, creator, inherit, nil); err != nil {
+		return child, fmt.Errorf("computing SACL: %w", err)
+	}
+
+	return child, nil
+}
+
+// newObjectOwner picks the owner of a new object: the creator's, the
+// parent's when asked for, or the token's
+func newObjectOwner(parent, creator *NtSecurityDescriptor, token *TokenUser, flags AutoInheritFlags) *SID {
+	switch {
+	case creator != nil && creator.Owner != nil:
+		return creator.Owner
+	case flags&AutoInheritDefaultOwnerFromParent != 0 && parent != nil && parent.Owner != nil:
+		return parent.Owner
+	case token == nil:
+		return nil
+	case token.Owner != nil:
+		return token.Owner
+	}
+	owner := token.UserSID
+	return &owner
+}
+
+// newObjectGroup picks the group of a new object like newObjectOwner
+func newObjectGroup(parent, creator *NtSecurityDescriptor, token *TokenUser, flags AutoInheritFlags) *SID {
+	switch {
+	case creator != nil && creator.Group != nil:
+		return creator.Group
+	case flags&AutoInheritDefaultGroupFromParent != 0 && parent != nil && parent.Group != nil:
+		return parent.Group
+	case token != nil:
+		return token.PrimaryGroup
+	}
+	return nil
+}
+
+// aclInheritance holds the control bits and flags of the ACL being
+// computed, so the DACL and SACL share one algorithm
+type aclInheritance struct {
+	present       uint16
+	defaulted     uint16
+	protected     uint16
+	autoInherited uint16
+	autoInherit   bool
+	sacl          bool
+}
+
+// acl returns the descriptor's DACL or SACL and its state
+func (ai aclInheritance) acl(sd *NtSecurityDescriptor) (*ACL, ACLState) {
+	if ai.sacl {
+		return sd.SACL, sd.SACLState()
+	}
+	return sd.DACL, sd.DACLState()
+}
+
+// compute sets the child's ACL and its control bits. An explicit creator
+// ACL is kept, merged with the inherited ACEs when auto-inheriting unless
+// it is protected. Otherwise the inherited ACEs are used, then a defaulted
+// creator ACL, then the token's default
+func (ai aclInheritance) compute(child, parent, creator *NtSecurityDescriptor,
+	inherit aceInheritance, tokenDefault *ACL,
+) error {
+	var (
+		creatorACL   *ACL
+		creatorState = ACLStateAbsent
+		control      uint16
+	)
+	if creator != nil {
+		creatorACL, creatorState = ai.acl(creator)
+		control = creator.Header.Control
+	}
+
+	var inherited []ACE
+	if parent != nil {
+		if parentACL, _ := ai.acl(parent); parentACL != nil {
+			var err error
+			if inherited, err = inherit.fromParent(parentACL.Aces); err != nil {
+				return err
+			}
+		}
+	}
+
+	protected := control&ai.protected != 0
+	explicit := creatorState != ACLStateAbsent && control&ai.defaulted == 0
+
+	var (
+		aces []ACE
+		null bool
+		err  error
+	)
+	switch {
+	case explicit && creatorState == ACLStateNull:
+		null = true
+	case explicit:
+		if aces, err = inherit.fromCreator(creatorACL.Aces, ai.autoInherit); err != nil {
+			return err
+		}
+		if ai.autoInherit && !protected {
+			aces = append(aces, inherited...)
+		}
+	case len(inherited) > 0:
+		aces = inherited
+	case creatorState == ACLStateNull:
+		null = true
+	case creatorState != ACLStateAbsent:
+		if aces, err = inherit.fromCreator(creatorACL.Aces, ai.autoInherit); err != nil {
+			return err
+		}
+	case tokenDefault != nil:
+		aces = append([]ACE(nil), tokenDefault.Aces...)
+	default:
+		return nil
+	}
+
+	child.Header.Control |= ai.present | control&ai.protected
+	if ai.autoInherit && !protected {
+		child.Header.Control |= ai.autoInherited
+	}
+	if null {
+		return nil
+	}
+
+	acl := ACL{Aces: aces}
+	if err := acl.recomputeHeader(); err != nil {
+		return err
+	}
+	if ai.sacl {
+		child.SACL = &acl
+	} else {
+		child.DACL = &acl
+	}
+	return nil
+}
+
+// aceInheritance applies the ACE inheritance rules for one new object
+type aceInheritance struct {
+	isContainer bool
+	objectType  *GUID
+	owner       *SID
+	group       *SID
+	mapping     map[uint32]uint32
+}
+
+// fromParent returns the ACEs a new object inherits from its parent's ACL.
+// An ACE is effective on the child when it inherits to the child's kind of
+// object (OI for objects, CI for containers) and its inherited object type,
+// if any, is the child's class. Containers also keep an inheritable ACE,
+// unless NO_PROPAGATE_INHERIT is set, to pass on to their own children
+func (ai aceInheritance) fromParent(aces []ACE) ([]ACE, error) {
+	var inherited []ACE
+	for _, ace := range aces {
+		flags := ace.Header.Flags
+		if flags&(ACEHeaderFlagsObjectInheritAce|ACEHeaderFlagsContainerInheritAce) == 0 {
+			continue
+		}
+
+		effective := flags&ACEHeaderFlagsObjectInheritAce != 0
+		if ai.isContainer {
+			effective = flags&ACEHeaderFlagsContainerInheritAce != 0
+		}
+		if effective && !ai.inheritsToObjectType(ace) {
+			effective = false
+		}
+		propagate := ai.isContainer && flags&ACEHeaderFlagsNoPropogateInheritAce == 0
+
+		ace.Header.Flags = flags&^ACEHeaderFlagsInheritOnlyAce | ACEHeaderFlagsInheritedAce
+		split, err := ai.split(ace, effective, propagate)
+		if err != nil {
+			return nil, err
+		}
+		inherited = append(inherited, split...)
+	}
+	return inherited, nil
+}
+
+// fromCreator returns the creator's ACEs as the new object keeps them.
+// When auto-inheriting, ACEs the creator marked as inherited are dropped,
+// since they are computed from the parent again
+func (ai aceInheritance) fromCreator(aces []ACE, autoInherit bool) ([]ACE, error) {
+	var explicit []ACE
+	for _, ace := range aces {
+		flags := ace.Header.Flags
+		if autoInherit && flags&ACEHeaderFlagsInheritedAce != 0 {
+			continue
+		}
+		if flags&ACEHeaderFlagsInheritOnlyAce != 0 {
+			explicit = append(explicit, ace)
+			continue
+		}
+		inheritable := flags&(ACEHeaderFlagsObjectInheritAce|ACEHeaderFlagsContainerInheritAce) != 0
+		split, err := ai.split(ace, true, ai.isContainer && inheritable)
+		if err != nil {
+			return nil, err
+		}
+		explicit = append(explicit, split...)
+	}
+	return explicit, nil
+}
+
+// split returns the ACEs that stand for ace on the new object. An ACE that
+// is both effective and propagated is kept whole, unless its generic rights
+// must be mapped or its CREATOR OWNER or CREATOR GROUP SID replaced. Then,
+// as Windows does, it becomes an effective ACE without inheritance flags
+// and an inherit-only ACE that keeps the original rights and SID
+func (ai aceInheritance) split(ace ACE, effective, propagate bool) ([]ACE, error) {
+	substitute, hasSubstitute := ai.creatorSubstitute(ace)
+	mapped := MapGenericAccess(ace.AccessMask.Value, ai.mapping)
+	if !effective && !propagate {
+		return nil, nil
+	}
+	if effective && propagate && !hasSubstitute && mapped == ace.AccessMask.Value {
+		return []ACE{ace}, nil
+	}
+
+	var aces []ACE
+	if effective && (!hasSubstitute || substitute != nil) {
+		eff := ace
+		eff.Header.Flags &^= inheritanceFlagsMask
+		eff.AccessMask.Value = mapped
+		if substitute != nil {
+			eff = withACESID(eff, *substitute)
+		}
+		if err := eff.recomputeSize(); err != nil {
+			return nil, err
+		}
+		aces = append(aces, eff)
+	}
+	if propagate {
+		inheritOnly := ace
+		inheritOnly.Header.Flags |= ACEHeaderFlagsInheritOnlyAce
+		aces = append(aces, inheritOnly)
+	}
+	return aces, nil
+}
+
+// creatorSubstitute returns the SID that replaces a CREATOR OWNER or
+// CREATOR GROUP ACE's SID on the new object. It is nil when the object has
+// no owner or group to put in its place
+func (ai aceInheritance) creatorSubstitute(ace ACE) (*SID, bool) {
+	if ace.ObjectAce == nil {
+		return nil, false
+	}
+	switch ace.ObjectAce.GetPrincipal().String() {
+	case "S-1-3-0", "S-1-3-2": // CREATOR OWNER, CREATOR OWNER SERVER
+		return ai.owner, true
+	case "S-1-3-1", "S-1-3-3": // CREATOR GROUP, CREATOR GROUP SERVER
+		return ai.group, true
+	}
+	return nil, false
+}
+
+// inheritsToObjectType reports whether an ACE applies to the new object's
+// class. ACEs without an inherited object type apply to every class
+func (ai aceInheritance) inheritsToObjectType(ace ACE) bool {
+	aa, ok := ace.ObjectAce.(AdvancedAce)
+	if !ok || aa.Flags&ACEInheritanceFlagsInheritedObjectTypePresent == 0 {
+		return true
+	}
+	return ai.objectType != nil && aa.InheritedObjectType == *ai.objectType
+}
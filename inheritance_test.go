@@ -0,0 +1,107 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePrivateObjectSecurity(t *testing.T) {
+	r := require.New(t)
+
+	userSID := mustSID(t, "S-1-5-21-1-2-3-1001")
+	groupSID := mustSID(t, "S-1-5-21-1-2-3-513")
+	token := winacl.NewTokenUser(userSID, nil)
+	token.PrimaryGroup = &groupSID
+
+	create := func(parent, creator *winacl.NtSecurityDescriptor, isContainer bool,
+		objectType *winacl.GUID, options *winacl.InheritanceOptions,
+	) string {
+		child, err := winacl.CreatePrivateObjectSecurity(parent, creator, isContainer, objectType, token, options)
+		r.NoError(err)
+		return child.ToSDDL()
+	}
+
+	const owner = "O:S-1-5-21-1-2-3-1001G:S-1-5-21-1-2-3-513"
+
+	t.Run("Object and container inheritance", func(t *testing.T) {
+		parent := mustParseSDDL(t, "O:BAG:SYD:(A;OICI;0x1f01ff;;;BA)(A;CI;0x1;;;BU)(A;OI;0x2;;;AU)(A;;0x4;;;WD)")
+
+		r.Equal(owner+"D:AI(A;ID;FA;;;BA)(A;ID;DC;;;AU)", create(parent, nil, false, nil, nil))
+		r.Equal(owner+"D:AI(A;OICIID;FA;;;BA)(A;CIID;CC;;;BU)(A;OIIOID;DC;;;AU)",
+			create(parent, nil, true, nil, nil))
+	})
+
+	t.Run("No propagate and inherit only", func(t *testing.T) {
+		parent := mustParseSDDL(t, "O:BAG:SYD:(A;OICINP;0x1;;;BA)(A;OICIIO;0x2;;;BU)")
+
+		r.Equal(owner+"D:AI(A;ID;CC;;;BA)(A;OICIID;DC;;;BU)", create(parent, nil, true, nil, nil))
+		r.Equal(owner+"D:AI(A;ID;CC;;;BA)(A;ID;DC;;;BU)", create(parent, nil, false, nil, nil))
+	})
+
+	t.Run("CREATOR OWNER and generic rights", func(t *testing.T) {
+		parent := mustParseSDDL(t, "O:BAG:SYD:(A;OICIIO;GA;;;CO)(A;OICI;GR;;;BU)")
+		options := winacl.DefaultInheritanceOptions()
+		options.ObjectType = winacl.SecurableDirectory
+
+		r.Equal(owner+"D:AI(A;ID;FA;;;S-1-5-21-1-2-3-1001)(A;OICIIOID;GA;;;CO)(A;ID;FR;;;BU)(A;OICIIOID;GR;;;BU)",
+			create(parent, nil, true, nil, options))
+		r.Equal(owner+"D:AI(A;ID;FA;;;S-1-5-21-1-2-3-1001)(A;ID;FR;;;BU)", create(parent, nil, false, nil, options))
+	})
+
+	t.Run("Object ACEs are filtered by inherited object type", func(t *testing.T) {
+		user, err := winacl.NewGUIDFromString("bf967aba-0de6-11d0-a285-00aa003049e2")
+		r.NoError(err)
+		group, err := winacl.NewGUIDFromString("bf967a9c-0de6-11d0-a285-00aa003049e2")
+		r.NoError(err)
+
+		parent := mustParseSDDL(t, "O:DAG:DAD:(OA;CI;RP;;bf967aba-0de6-11d0-a285-00aa003049e2;AU)")
+
+		r.Equal(owner+"D:AI(OA;CIID;RP;;bf967aba-0de6-11d0-a285-00aa003049e2;AU)",
+			create(parent, nil, true, &user, nil))
+		r.Equal(owner+"D:AI(OA;CIIOID;RP;;bf967aba-0de6-11d0-a285-00aa003049e2;AU)",
+			create(parent, nil, true, &group, nil))
+	})
+
+	t.Run("Creator descriptor", func(t *testing.T) {
+		parent := mustParseSDDL(t, "O:BAG:SYD:(A;OICI;0x1;;;BU)")
+
+		// Explicit ACEs come first, followed by the inherited ones
+		r.Equal("O:S-1-5-18G:S-1-5-18D:AI(A;;DC;;;WD)(A;ID;CC;;;BU)",
+			create(parent, mustParseSDDL(t, "O:SYG:SYD:(A;;0x2;;;WD)"), false, nil, nil))
+
+		// A protected DACL does not inherit
+		r.Equal(owner+"D:P(A;;DC;;;WD)", create(parent, mustParseSDDL(t, "D:P(A;;0x2;;;WD)"), false, nil, nil))
+
+		// Without auto-inheritance, an explicit DACL replaces the inherited ACEs
+		r.Equal(owner+"D:(A;;DC;;;WD)", create(parent, mustParseSDDL(t, "D:(A;;0x2;;;WD)"), false, nil, &winacl.InheritanceOptions{}))
+
+		// Inherited ACEs given by the creator are computed again
+		r.Equal(owner+"D:AI(A;;DC;;;WD)(A;ID;CC;;;BU)",
+			create(parent, mustParseSDDL(t, "D:(A;;0x2;;;WD)(A;ID;0x4;;;AU)"), false, nil, nil))
+
+		// A NULL DACL is kept
+		r.Equal(owner+"D:AINO_ACCESS_CONTROL", create(parent, mustParseSDDL(t, "D:NO_ACCESS_CONTROL"), false, nil, nil))
+	})
+
+	t.Run("Owner and group from the parent", func(t *testing.T) {
+		parent := mustParseSDDL(t, "O:BAG:SYD:(A;OICI;0x1;;;BU)")
+		options := winacl.DefaultInheritanceOptions()
+		options.Flags |= winacl.AutoInheritDefaultOwnerFromParent | winacl.AutoInheritDefaultGroupFromParent
+
+		r.Equal("O:S-1-5-32-544G:S-1-5-18D:AI(A;ID;CC;;;BU)", create(parent, nil, false, nil, options))
+	})
+
+	t.Run("Token default DACL", func(t *testing.T) {
+		token.DefaultDACL = mustParseSDDL(t, "D:(A;;GA;;;SY)").DACL
+		defer func() { token.DefaultDACL = nil }()
+
+		r.Equal(owner+"D:AI(A;;GA;;;SY)", create(mustParseSDDL(t, "O:BAG:SYD:(A;;0x1;;;BU)"), nil, false, nil, nil))
+	})
+
+	t.Run("SACL", func(t *testing.T) {
+		parent := mustParseSDDL(t, "O:BAG:SYS:(AU;OICISA;0x1;;;WD)")
+		r.Equal(owner+"S:AI(AU;IDSA;CC;;;WD)", create(parent, nil, false, nil, nil))
+	})
+}
@@ -104,28 +104,84 @@ const (
 )
 
 // CheckAccess evaluates if a subject with this integrity level can access
-// an object with the specified integrity level given the policy
+// an object with the specified integrity level given the policy. Only
+// generic and standard rights are checked; use CheckAccessWithMapping for
+// rights that have already been mapped
 func (il IntegrityLevel) CheckAccess(objectLevel IntegrityLevel, policy IntegrityLevelPolicy, requestedAccess uint32) bool {
+	return il.CheckAccessWithMapping(objectLevel, policy, requestedAccess, nil)
+}
+
+// CheckAccessWithMapping is CheckAccess for an object with the given
+// generic mapping, so that the specific rights each generic right maps to
+// are checked too
+func (il IntegrityLevel) CheckAccessWithMapping(objectLevel IntegrityLevel, policy IntegrityLevelPolicy,
+	requestedAccess uint32, mapping map[uint32]uint32,
+) bool {
+	return requestedAccess&il.BlockedAccess(objectLevel, policy, mapping) == 0
+}
+
+// BlockedAccess returns the rights the policy denies a subject with this
+// integrity level on an object with objectLevel. Each policy blocks its
+// generic right and the specific rights the mapping gives it. Rights that
+// the read and execute mappings share with the write mapping, such as
+// READ_CONTROL and SYNCHRONIZE in FILE_GENERIC_WRITE, are only blocked by
+// NO_READ_UP or NO_EXECUTE_UP
+func (il IntegrityLevel) BlockedAccess(objectLevel IntegrityLevel, policy IntegrityLevelPolicy, mapping map[uint32]uint32) uint32 {
 	// If subject level is higher or equal to object level, always grant access
 	if il >= objectLevel {
-		return true
+		return 0
 	}
 
 	// Subject level is lower than object level, apply policy
-	if (policy&PolicyNoWriteUp != 0) && 
-	   (requestedAccess&(AccessMaskGenericWrite|AccessMaskWriteDACL|AccessMaskWriteOwner) != 0) {
-		return false
+	blocked := uint32(0)
+	if policy&PolicyNoWriteUp != 0 {
+		write := AccessMaskGenericWrite | AccessMaskWriteDACL | AccessMaskWriteOwner | mapping[AccessMaskGenericWrite]
+		blocked |= write &^ (mapping[AccessMaskGenericRead] | mapping[AccessMaskGenericExecute])
 	}
-	
-	if (policy&PolicyNoReadUp != 0) && 
-	   (requestedAccess&(AccessMaskGenericRead|AccessMaskReadControl) != 0) {
-		return false
+
+	if policy&PolicyNoReadUp != 0 {
+		blocked |= AccessMaskGenericRead | AccessMaskReadControl | mapping[AccessMaskGenericRead]
 	}
-	
-	if (policy&PolicyNoExecuteUp != 0) && 
-	   (requestedAccess&AccessMaskGenericExecute != 0) {
-		return false
+
+	if policy&PolicyNoExecuteUp != 0 {
+		blocked |= AccessMaskGenericExecute | mapping[AccessMaskGenericExecute]
 	}
 
-	return true
+	return blocked
+}
+
+// MandatoryLabel returns the integrity level and policy held by a
+// SYSTEM_MANDATORY_LABEL_ACE. The level comes from the ACE's S-1-16-X SID
+// and the policy from the NO_WRITE_UP, NO_READ_UP and NO_EXECUTE_UP bits
+// of its access mask
+func (s ACE) MandatoryLabel() (IntegrityLevel, IntegrityLevelPolicy, error) {
+	if s.Header.Type != AceTypeSystemMandatoryLabel {
+		return 0, 0, fmt.Errorf("not a mandatory label ACE: %s", s.GetTypeString())
+	}
+
+	level, err := IntegrityLevelFromSID(s.ObjectAce.GetPrincipal())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	policy := IntegrityLevelPolicy(s.AccessMask.Value) & (PolicyNoWriteUp | PolicyNoReadUp | PolicyNoExecuteUp)
+	return level, policy, nil
+}
+
+// MandatoryLabel returns the integrity level and policy of the first
+// mandatory label ACE in the descriptor's SACL that applies to the object
+// itself. When there is none, ok is false and Medium with NO_WRITE_UP is
+// returned, which is how Windows treats unlabelled objects
+func (s NtSecurityDescriptor) MandatoryLabel() (level IntegrityLevel, policy IntegrityLevelPolicy, ok bool) {
+	if s.SACL != nil {
+		for _, ace := range s.SACL.Aces {
+			if ace.Header.Flags&ACEHeaderFlagsInheritOnlyAce != 0 {
+				continue
+			}
+			if level, policy, err := ace.MandatoryLabel(); err == nil {
+				return level, policy, true
+			}
+		}
+	}
+	return IntegrityLevelMedium, PolicyNoWriteUp, false
 }
@@ -115,3 +115,69 @@ func TestIntegrityLevelCheckAccess(t *testing.T) {
 		r.True(winacl.IntegrityLevelMedium.CheckAccess(winacl.IntegrityLevelMedium, policy, winacl.AccessMaskGenericAll))
 	})
 }
+
+func TestIntegrityLevelCheckAccessWithMapping(t *testing.T) {
+	r := require.New(t)
+	// FILE_GENERIC_READ, FILE_GENERIC_WRITE, FILE_GENERIC_EXECUTE and FILE_ALL_ACCESS
+	mapping := map[uint32]uint32{
+		winacl.AccessMaskGenericRead:    0x00120089,
+		winacl.AccessMaskGenericWrite:   0x00120116,
+		winacl.AccessMaskGenericExecute: 0x001200A0,
+		winacl.AccessMaskGenericAll:     0x001F01FF,
+	}
+	const fileReadData, fileWriteData = 0x1, 0x2
+	low, medium := winacl.IntegrityLevelLow, winacl.IntegrityLevelMedium
+
+	t.Run("NoWriteUp blocks the mapped write rights", func(t *testing.T) {
+		r.False(low.CheckAccessWithMapping(medium, winacl.PolicyNoWriteUp, fileWriteData, mapping))
+		r.True(low.CheckAccess(medium, winacl.PolicyNoWriteUp, fileWriteData))
+		r.True(low.CheckAccessWithMapping(medium, winacl.PolicyNoWriteUp, fileReadData|winacl.AccessMaskSynchronize, mapping))
+	})
+
+	t.Run("NoReadUp blocks the mapped read rights", func(t *testing.T) {
+		r.False(low.CheckAccessWithMapping(medium, winacl.PolicyNoReadUp, fileReadData, mapping))
+		r.False(low.CheckAccessWithMapping(medium, winacl.PolicyNoReadUp, winacl.AccessMaskSynchronize, mapping))
+	})
+
+	t.Run("Blocks nothing for equal levels", func(t *testing.T) {
+		policy := winacl.PolicyNoWriteUp | winacl.PolicyNoReadUp | winacl.PolicyNoExecuteUp
+		r.Zero(medium.BlockedAccess(medium, policy, mapping))
+	})
+}
+
+func TestMandatoryLabel(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Reads level and policy from a label ACE", func(t *testing.T) {
+		ace, err := winacl.NewACEFromSDDL("(ML;;NWNR;;;HI)")
+		r.NoError(err)
+
+		level, policy, err := ace.MandatoryLabel()
+		r.NoError(err)
+		r.Equal(winacl.IntegrityLevelHigh, level)
+		r.Equal(winacl.PolicyNoWriteUp|winacl.PolicyNoReadUp, policy)
+
+		ace, err = winacl.NewACEFromSDDL("(A;;GA;;;WD)")
+		r.NoError(err)
+		_, _, err = ace.MandatoryLabel()
+		r.Error(err)
+	})
+
+	t.Run("Reads the descriptor's label, skipping inherit-only ACEs", func(t *testing.T) {
+		ntsd := mustParseSDDL(t, "D:(A;;GA;;;WD)S:(ML;OICIIO;NX;;;SI)(ML;;NW;;;LW)")
+
+		level, policy, ok := ntsd.MandatoryLabel()
+		r.True(ok)
+		r.Equal(winacl.IntegrityLevelLow, level)
+		r.Equal(winacl.PolicyNoWriteUp, policy)
+	})
+
+	t.Run("Defaults to Medium and NO_WRITE_UP", func(t *testing.T) {
+		ntsd := mustParseSDDL(t, "D:(A;;GA;;;WD)")
+
+		level, policy, ok := ntsd.MandatoryLabel()
+		r.False(ok)
+		r.Equal(winacl.IntegrityLevelMedium, level)
+		r.Equal(winacl.PolicyNoWriteUp, policy)
+	})
+}
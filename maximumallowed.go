@@ -0,0 +1,198 @@
+package winacl
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// Sources of an AccessRightDecision
+const (
+	DecisionSourceACE            = "ACE"
+	DecisionSourceOwner          = "Owner"
+	DecisionSourcePrivilege      = "Privilege"
+	DecisionSourceIntegrity      = "IntegrityLevel"
+	DecisionSourceNullDACL       = "NullDACL"
+	DecisionSourceRestrictedSIDs = "RestrictedSIDs"
+	DecisionSourceAppContainer   = "AppContainer"
+)
+
+// maximumAllowedIgnoredRights are the bits a DACL never grants when
+// computing the maximum allowed access: generic rights are mapped away,
+// and ACCESS_SYSTEM_SECURITY is only granted by privilege
+const maximumAllowedIgnoredRights = AccessMaskGenericRead | AccessMaskGenericWrite |
+	AccessMaskGenericExecute | AccessMaskGenericAll |
+	AccessMaskMaximumAllowed | AccessMaskSystemSecurity
+
+// AccessRightDecision records how a single access right was decided
+type AccessRightDecision struct {
+	Right     uint32 // A single access mask bit
+	Granted   bool   // Whether the right ended up granted
+	Source    string // What decided the right, one of the DecisionSource constants
+	Ace       *ACE   // The ACE that decided the right, if any
+	AceIndex  int    // Index of that ACE in the DACL, or -1
+	Privilege string // The privilege that granted the right, if any
+}
+
+// EffectiveAccess computes the full access mask a token is granted on an
+// object. It is shorthand for an AccessCheck of MAXIMUM_ALLOWED
+func EffectiveAccess(securityDescriptor *NtSecurityDescriptor, token *TokenUser, options *AccessCheckOptions) *AccessCheckResult {
+	return AccessCheck(securityDescriptor, token, AccessMaskMaximumAllowed, options)
+}
+
+// maximumAllowedCheck computes the granted access mask for a request that
+// includes MAXIMUM_ALLOWED. Privilege and owner rights are granted first,
+// then ACEs are walked in DACL order, each deciding the rights no earlier
+// ACE decided, with decisions that rely on a non-canonical order flagged.
+// Finally the rights the integrity policy forbids are removed. Any rights
+// requested alongside MAXIMUM_ALLOWED must all be in the granted mask
+func maximumAllowedCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
+	mappedAccess uint32, options *AccessCheckOptions,
+) *AccessCheckResult {
+	result := &AccessCheckResult{Details: make([]CheckDetails, 0)}
+	decisions := make(map[uint32]AccessRightDecision)
+	required := mappedAccess &^ AccessMaskMaximumAllowed
+
+	decide := func(mask uint32, granted bool, source string, ace *ACE, aceIndex int, privilege string) {
+		for _, right := range accessRights(mask) {
+			if _, decided := decisions[right]; decided {
+				continue
+			}
+			decisions[right] = AccessRightDecision{
+				Right:     right,
+				Granted:   granted,
+				Source:    source,
+				Ace:       ace,
+				AceIndex:  aceIndex,
+				Privilege: privilege,
+			}
+		}
+	}
+
+	// Privileges are checked before the DACL. ACCESS_SYSTEM_SECURITY is
+	// only granted when it was asked for explicitly
+	grants := privilegeGrants(token, options, ^uint32(maximumAllowedIgnoredRights)|(mappedAccess&AccessMaskSystemSecurity))
+	for _, grant := range grants {
+		decide(grant.Access, true, DecisionSourcePrivilege, nil, -1, grant.Privilege)
+	}
+	result.Details = append(result.Details, privilegeDetails(grants)...)
+
+	switch securityDescriptor.DACLState() {
+	case ACLStateAbsent, ACLStateNull:
+		decide(maximumAllowedRights(options), true, DecisionSourceNullDACL, nil, -1, "")
+
+		result.Details = append(result.Details, CheckDetails{
+			Step:        "EmptyDACL",
+			Description: "No DACL or NULL DACL present; all rights granted",
+			Outcome:     true,
+		})
+
+	default:
+		order := newACEOrderTracker(securityDescriptor.DACL.Aces)
+		order.decideImplicit(privilegeAccess(grants))
+
+		if ownerRights, reason := token.implicitOwnerRights(securityDescriptor); token.objectOwner {
+			decide(order.decideImplicit(ownerRights), true, DecisionSourceOwner, nil, -1, "")
+
+			result.Details = append(result.Details, CheckDetails{
+				Step:        "OwnerRights",
+				Description: reason,
+				Outcome:     ownerRights != 0,
+			})
+		}
+
+		for i, ace := range securityDescriptor.DACL.Aces {
+			allow := isAllowAceType(ace.Header.Type)
+			deny := isDenyAceType(ace.Header.Type)
+			if (!allow && !deny) || ace.Header.Flags&ACEHeaderFlagsInheritOnlyAce != 0 || hasObjectType(ace) {
+				continue
+			}
+
+			applies, reason := aceAppliesToToken(ace, token, options)
+			result.Details = append(result.Details, CheckDetails{
+				Step:        fmt.Sprintf("ACE[%d]", i),
+				Description: fmt.Sprintf("Checking if %s ACE applies: %v - %s", ace.GetTypeString(), applies, reason),
+				Outcome:     applies,
+			})
+			if !applies {
+				continue
+			}
+
+			ace := ace // Copy to avoid issues with loop variable in closures
+			aceMappedAccess := MapGenericAccess(ace.AccessMask.Raw(), options.GenericMapping) &^ maximumAllowedIgnoredRights
+			decided, conflicts := order.apply(i, aceMappedAccess, allow)
+			result.Details = append(result.Details, conflicts...)
+			decide(decided, allow, DecisionSourceACE, &ace, i, "")
+		}
+	}
+
+	// Remove the rights the integrity policy forbids
+	if options.CheckIntegrity {
+		for right, decision := range decisions {
+			if !decision.Granted {
+				continue
+			}
+			if detail := checkIntegrity(securityDescriptor, token, options, right); !detail.Outcome {
+				decisions[right] = AccessRightDecision{
+					Right:    right,
+					Granted:  false,
+					Source:   DecisionSourceIntegrity,
+					AceIndex: -1,
+				}
+				result.Details = append(result.Details, detail)
+			}
+		}
+	}
+
+	granted := uint32(0)
+	result.Rights = make([]AccessRightDecision, 0, len(decisions))
+	for right, decision := range decisions {
+		result.Rights = append(result.Rights, decision)
+		if decision.Granted {
+			granted |= right
+		}
+	}
+	sort.Slice(result.Rights, func(i, j int) bool {
+		return result.Rights[i].Right < result.Rights[j].Right
+	})
+
+	result.Access = granted
+	switch {
+	case granted == 0:
+		result.Reason = "No access rights were granted"
+	case granted&required != required:
+		result.Reason = fmt.Sprintf("Requested access 0x%08X was not fully granted", required)
+	default:
+		result.Granted = true
+		result.Reason = fmt.Sprintf("Maximum allowed access is 0x%08X", granted)
+	}
+
+	result.Details = append(result.Details, CheckDetails{
+		Step:        "FinalDecision",
+		Description: fmt.Sprintf("Maximum allowed: granted=%08X, required=%08X", granted, required),
+		Outcome:     result.Granted,
+	})
+	return result
+}
+
+// maximumAllowedRights returns every right GENERIC_ALL stands for, which a
+// NULL DACL grants to MAXIMUM_ALLOWED. Without a mapping GENERIC_ALL would
+// map to nothing, so the object-agnostic mapping is used
+func maximumAllowedRights(options *AccessCheckOptions) uint32 {
+	mapping := options.GenericMapping
+	if mapping == nil {
+		mapping = GenericMappingFor(SecurableGeneric)
+	}
+	return MapGenericAccess(AccessMaskGenericAll, mapping) &^ maximumAllowedIgnoredRights
+}
+
+// accessRights splits an access mask into its individual bits
+func accessRights(mask uint32) []uint32 {
+	rights := make([]uint32, 0, bits.OnesCount32(mask))
+	for mask != 0 {
+		right := mask & -mask
+		rights = append(rights, right)
+		mask &^= right
+	}
+	return rights
+}
@@ -0,0 +1,112 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaximumAllowed(t *testing.T) {
+	r := require.New(t)
+
+	token := winacl.NewTokenUser(mustSID(t, "S-1-5-21-1-2-3-1001"), nil)
+
+	decision := func(result *winacl.AccessCheckResult, right uint32) winacl.AccessRightDecision {
+		for _, d := range result.Rights {
+			if d.Right == right {
+				return d
+			}
+		}
+		r.Failf("missing decision", "no decision for right 0x%x", right)
+		return winacl.AccessRightDecision{}
+	}
+
+	t.Run("Earlier deny wins over later allow", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:BAD:(D;;0x2;;;WD)(A;;0x7;;;WD)", token, winacl.AccessMaskMaximumAllowed, nil)
+		r.True(result.Granted)
+		r.Equal(uint32(0x5), result.Access)
+
+		denied := decision(result, 0x2)
+		r.False(denied.Granted)
+		r.Equal(winacl.DecisionSourceACE, denied.Source)
+		r.Equal(0, denied.AceIndex)
+		r.Equal(winacl.AceTypeAccessDenied, denied.Ace.Header.Type)
+
+		granted := decision(result, 0x4)
+		r.True(granted.Granted)
+		r.Equal(1, granted.AceIndex)
+	})
+
+	t.Run("Earlier allow wins over later deny", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:BAD:(A;;0x3;;;WD)(D;;0x2;;;WD)", token, winacl.AccessMaskMaximumAllowed, nil)
+		r.Equal(uint32(0x3), result.Access)
+		r.Equal(0, decision(result, 0x2).AceIndex)
+		r.Equal("NonCanonicalOrder", result.Details[len(result.Details)-2].Step)
+	})
+
+	t.Run("Owner rights are granted before the DACL", func(t *testing.T) {
+		sddl := "O:" + token.UserSID.String() + "D:(D;;WD;;;WD)(A;;0x1;;;WD)"
+		result := accessCheckSDDL(t, sddl, token, winacl.AccessMaskMaximumAllowed, nil)
+		r.Equal(uint32(0x1|winacl.AccessMaskReadControl|winacl.AccessMaskWriteDACL), result.Access)
+		r.Equal(winacl.DecisionSourceOwner, decision(result, winacl.AccessMaskWriteDACL).Source)
+		r.Equal(-1, decision(result, winacl.AccessMaskWriteDACL).AceIndex)
+	})
+
+	t.Run("Rights requested alongside MAXIMUM_ALLOWED are required", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:BAD:(A;;0x7;;;WD)", token, winacl.AccessMaskMaximumAllowed|0x8, nil)
+		r.False(result.Granted)
+		r.Equal(uint32(0x7), result.Access)
+
+		result = accessCheckSDDL(t, "O:BAD:(A;;0x7;;;WD)", token, winacl.AccessMaskMaximumAllowed|0x4, nil)
+		r.True(result.Granted)
+	})
+
+	t.Run("Object ACEs without an object type apply to the whole object", func(t *testing.T) {
+		sddl := "O:BAD:(OD;;0x2;;;WD)(OA;;0x7;;;WD)(OA;;0x8;bf967a86-0de6-11d0-a285-00aa003049e2;;WD)"
+		result := accessCheckSDDL(t, sddl, token, winacl.AccessMaskMaximumAllowed, nil)
+		r.Equal(uint32(0x5), result.Access)
+		r.Equal(winacl.AceTypeAccessDeniedObject, decision(result, 0x2).Ace.Header.Type)
+	})
+
+	t.Run("Nothing granted", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:BAD:(A;;0x7;;;BA)", token, winacl.AccessMaskMaximumAllowed, nil)
+		r.False(result.Granted)
+		r.Zero(result.Access)
+		r.Empty(result.Rights)
+	})
+
+	t.Run("Generic rights are mapped", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:BAD:(A;;GA;;;WD)", token, winacl.AccessMaskMaximumAllowed, nil)
+		r.True(result.Granted)
+		r.Zero(result.Access & (winacl.AccessMaskGenericAll | winacl.AccessMaskMaximumAllowed | winacl.AccessMaskSystemSecurity))
+		r.NotZero(result.Access & winacl.AccessMaskWriteOwner)
+	})
+
+	t.Run("Integrity policy removes write rights", func(t *testing.T) {
+		options := winacl.DefaultAccessCheckOptions()
+		options.CheckIntegrity = true
+
+		result := accessCheckSDDL(t, "O:BAD:(A;;GA;;;WD)S:(ML;;NW;;;HI)", token, winacl.AccessMaskMaximumAllowed, options)
+		r.True(result.Granted)
+		r.Zero(result.Access & (winacl.AccessMaskWriteDACL | winacl.AccessMaskWriteOwner))
+		r.NotZero(result.Access & winacl.AccessMaskReadControl)
+
+		removed := decision(result, winacl.AccessMaskWriteOwner)
+		r.False(removed.Granted)
+		r.Equal(winacl.DecisionSourceIntegrity, removed.Source)
+	})
+
+	t.Run("EffectiveAccess on a NULL DACL", func(t *testing.T) {
+		result := winacl.EffectiveAccess(mustParseSDDL(t, "O:BAD:NO_ACCESS_CONTROL"), token, nil)
+		r.True(result.Granted)
+		r.Equal(winacl.DecisionSourceNullDACL, decision(result, winacl.AccessMaskDelete).Source)
+	})
+
+	t.Run("NULL DACL without a generic mapping", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:BAD:NO_ACCESS_CONTROL", token, winacl.AccessMaskMaximumAllowed, &winacl.AccessCheckOptions{})
+		r.True(result.Granted)
+		r.Equal(winacl.DecisionSourceNullDACL, decision(result, winacl.AccessMaskDelete).Source)
+		r.Equal(uint32(0x1f01ff), result.Access&0x1f01ff)
+	})
+}
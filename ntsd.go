@@ -2,16 +2,25 @@ package winacl
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 )
 
+// ntsdHeaderSize is the size, in bytes, of a self-relative
+// NtSecurityDescriptorHeader
+const ntsdHeaderSize = 20
+
 // NtSecurityDescriptor represent a Security Descriptor
+//
+// Owner, Group, DACL and SACL are nil when the corresponding component is
+// absent from the descriptor. A nil DACL or SACL can mean either "not
+// present" or "NULL ACL"; use DACLState and SACLState to tell them apart.
 type NtSecurityDescriptor struct {
 	Header NtSecurityDescriptorHeader
-	DACL   ACL
-	SACL   ACL
-	Owner  SID
-	Group  SID
+	DACL   *ACL
+	SACL   *ACL
+	Owner  *SID
+	Group  *SID
 }
 
 // String will returns general information about itself
@@ -21,51 +30,237 @@ func (s NtSecurityDescriptor) String() string {
 		"Parsed Security Descriptor:\n Offsets:\n Owner=%v Group=%v Sacl=%v Dacl=%v\n",
 		s.Header.OffsetOwner,
 		s.Header.OffsetGroup,
-		s.Header.OffsetDacl,
 		s.Header.OffsetSacl,
+		s.Header.OffsetDacl,
 	)
 }
 
 // NewNtSecurityDescriptor is a constructor that will parse out an
 // NtSecurityDescriptor from a byte buffer
+//
+// The buffer must hold a self-relative security descriptor. Each component is
+// read from the offset recorded in the header, so components may appear in
+// any order. A zero offset leaves the corresponding field nil.
 func NewNtSecurityDescriptor(ntsdBytes []byte) (NtSecurityDescriptor, error) {
-	// Use bytes.NewReader which is more efficient for sequential reads
-	buf := bytes.NewBuffer(ntsdBytes)
 	var err error
 
 	ntsd := NtSecurityDescriptor{}
-	ntsd.Header, err = NewNTSDHeader(buf)
+	ntsd.Header, err = NewNTSDHeader(bytes.NewBuffer(ntsdBytes))
 	if err != nil {
 		return ntsd, fmt.Errorf("parsing security descriptor header: %w", err)
 	}
 
-	ntsd.DACL, err = NewACL(buf)
+	ntsd.Owner, err = sidAtOffset(ntsdBytes, ntsd.Header.OffsetOwner)
 	if err != nil {
-		return ntsd, fmt.Errorf("parsing DACL: %w", err)
+		return ntsd, fmt.Errorf("parsing owner SID: %w", err)
+	}
+
+	ntsd.Group, err = sidAtOffset(ntsdBytes, ntsd.Header.OffsetGroup)
+	if err != nil {
+		return ntsd, fmt.Errorf("parsing group SID: %w", err)
+	}
+
+	if ntsd.Header.HasControl(SACLPresent) {
+		ntsd.SACL, err = aclAtOffset(ntsdBytes, ntsd.Header.OffsetSacl)
+		if err != nil {
+			return ntsd, fmt.Errorf("parsing SACL: %w", err)
+		}
+	}
+
+	if ntsd.Header.HasControl(DACLPresent) {
+		ntsd.DACL, err = aclAtOffset(ntsdBytes, ntsd.Header.OffsetDacl)
+		if err != nil {
+			return ntsd, fmt.Errorf("parsing DACL: %w", err)
+		}
+	}
+
+	return ntsd, nil
+}
+
+// ACLState describes whether, and how, an ACL is present in a
+// security descriptor
+type ACLState int
+
+// ACLState constants
+const (
+	// ACLStateAbsent means the *_PRESENT control bit is not set
+	ACLStateAbsent ACLState = iota
+	// ACLStateNull means the *_PRESENT control bit is set but there is no
+	// ACL. A NULL DACL grants full access to everyone
+	ACLStateNull
+	// ACLStateEmpty means an ACL is present but contains no ACEs. An empty
+	// DACL denies access to everyone
+	ACLStateEmpty
+	// ACLStatePopulated means an ACL is present and contains ACEs
+	ACLStatePopulated
+)
+
+// ACLStateLookup maps ACLStates to human-readable strings
+var ACLStateLookup = map[ACLState]string{
+	ACLStateAbsent:    "ABSENT",
+	ACLStateNull:      "NULL",
+	ACLStateEmpty:     "EMPTY",
+	ACLStatePopulated: "POPULATED",
+}
+
+// String returns the human-readable ACLState
+func (st ACLState) String() string {
+	return ACLStateLookup[st]
+}
+
+// DACLState reports whether the descriptor has no DACL, a NULL DACL,
+// an empty DACL or a DACL with ACEs
+func (s NtSecurityDescriptor) DACLState() ACLState {
+	return aclState(s.DACL, s.Header.HasControl(DACLPresent))
+}
+
+// SACLState reports whether the descriptor has no SACL, a NULL SACL,
+// an empty SACL or a SACL with ACEs
+func (s NtSecurityDescriptor) SACLState() ACLState {
+	return aclState(s.SACL, s.Header.HasControl(SACLPresent))
+}
+
+// aclState classifies an ACL. A non-nil ACL is always considered present,
+// so descriptors assembled by hand need not set the control bits
+func aclState(acl *ACL, present bool) ACLState {
+	switch {
+	case acl != nil && len(acl.Aces) > 0:
+		return ACLStatePopulated
+	case acl != nil:
+		return ACLStateEmpty
+	case present:
+		return ACLStateNull
+	default:
+		return ACLStateAbsent
+	}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, returning the
+// descriptor in self-relative form. Components are laid out after the
+// header in the order Windows uses: SACL, DACL, owner, group. Offsets and
+// the SE_SELF_RELATIVE, SE_DACL_PRESENT and SE_SACL_PRESENT control bits
+// are recomputed.
+func (s NtSecurityDescriptor) MarshalBinary() ([]byte, error) {
+	header := s.Header
+	if header.Revision == 0 {
+		header.Revision = 1
 	}
+	header.Control |= SelfRelative
+	header.OffsetOwner, header.OffsetGroup = 0, 0
+	header.OffsetSacl, header.OffsetDacl = 0, 0
 
-	sidSize := ntsd.Header.OffsetGroup - ntsd.Header.OffsetOwner
+	body := bytes.Buffer{}
+	offset := func() uint32 { return uint32(ntsdHeaderSize + body.Len()) }
 
-	// It seems that sometimes the owner and group are at the front of the bytes
-	// stream as well as being part of the first ACE
-	if sidSize == 0 {
-		// Check if there are any ACEs before accessing
-		if len(ntsd.DACL.Aces) > 0 {
-			ntsd.Owner = ntsd.DACL.Aces[0].ObjectAce.GetPrincipal()
-			ntsd.Group = ntsd.DACL.Aces[0].ObjectAce.GetPrincipal()
+	header.Control = presentControl(header.Control, SACLPresent, s.SACLState())
+	if s.SACL != nil {
+		sacl, err := s.SACL.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("writing SACL: %w", err)
 		}
-		return ntsd, nil
+		header.OffsetSacl = offset()
+		body.Write(sacl)
 	}
 
-	ntsd.Owner, err = NewSID(buf, int(sidSize))
+	header.Control = presentControl(header.Control, DACLPresent, s.DACLState())
+	if s.DACL != nil {
+		dacl, err := s.DACL.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("writing DACL: %w", err)
+		}
+		header.OffsetDacl = offset()
+		body.Write(dacl)
+	}
+
+	if s.Owner != nil {
+		owner, err := s.Owner.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("writing owner SID: %w", err)
+		}
+		header.OffsetOwner = offset()
+		body.Write(owner)
+	}
+
+	if s.Group != nil {
+		group, err := s.Group.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("writing group SID: %w", err)
+		}
+		header.OffsetGroup = offset()
+		body.Write(group)
+	}
+
+	buf := bytes.Buffer{}
+	buf.Grow(ntsdHeaderSize + body.Len())
+	if err := binary.Write(&buf, binary.LittleEndian, header); err != nil {
+		return nil, fmt.Errorf("writing security descriptor header: %w", err)
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, parsing a
+// self-relative security descriptor
+func (s *NtSecurityDescriptor) UnmarshalBinary(data []byte) error {
+	ntsd, err := NewNtSecurityDescriptor(data)
 	if err != nil {
-		return ntsd, fmt.Errorf("parsing owner SID: %w", err)
+		return err
 	}
+	*s = ntsd
+	return nil
+}
+
+// presentControl sets or clears a *_PRESENT control bit to match state
+func presentControl(control uint16, bit uint16, state ACLState) uint16 {
+	if state == ACLStateAbsent {
+		return control &^ bit
+	}
+	return control | bit
+}
 
-	ntsd.Group, err = NewSID(buf, int(sidSize))
+// sidAtOffset parses the SID found at offset within a self-relative security
+// descriptor. A zero offset means the SID is absent and yields nil.
+func sidAtOffset(ntsdBytes []byte, offset uint32) (*SID, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	if offset < ntsdHeaderSize || int(offset) >= len(ntsdBytes) {
+		return nil, fmt.Errorf("offset %d out of bounds", offset)
+	}
+
+	data := ntsdBytes[offset:]
+	sidLength, err := sidLengthFromBytes(data)
 	if err != nil {
-		return ntsd, fmt.Errorf("parsing group SID: %w", err)
+		return nil, err
 	}
 
-	return ntsd, nil
+	sid, err := NewSID(bytes.NewBuffer(data), sidLength)
+	if err != nil {
+		return nil, err
+	}
+	return &sid, nil
+}
+
+// aclAtOffset parses the ACL found at offset within a self-relative security
+// descriptor. A zero offset means the ACL is absent and yields nil. The ACL
+// is bounded by the size recorded in its own header.
+func aclAtOffset(ntsdBytes []byte, offset uint32) (*ACL, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	if offset < ntsdHeaderSize || int(offset)+8 > len(ntsdBytes) {
+		return nil, fmt.Errorf("offset %d out of bounds", offset)
+	}
+
+	data := ntsdBytes[offset:]
+	size := int(binary.LittleEndian.Uint16(data[2:4]))
+	if size < 8 || size > len(data) {
+		return nil, fmt.Errorf("invalid ACL size %d at offset %d", size, offset)
+	}
+
+	acl, err := NewACL(bytes.NewBuffer(data[:size]))
+	if err != nil {
+		return nil, err
+	}
+	return &acl, nil
 }
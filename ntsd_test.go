@@ -1,6 +1,8 @@
 package winacl_test
 
 import (
+	"bytes"
+	"encoding/binary"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -21,6 +23,80 @@ func TestNewNtSecurityDescriptor(t *testing.T) {
 		dacl := ntsd.DACL
 		r.NotNil(dacl)
 		r.Equal(int(dacl.Header.AceCount), len(dacl.Aces))
+		r.Nil(ntsd.SACL)
+
+		r.NotNil(ntsd.Owner)
+		r.Equal("S-1-5-21-2333832797-2102143736-1942374753-512", ntsd.Owner.String())
+		r.NotNil(ntsd.Group)
+		r.Equal("S-1-5-21-2333832797-2102143736-1942374753-512", ntsd.Group.String())
+	})
+
+	t.Run("Honours header offsets regardless of component order", func(t *testing.T) {
+		// Layout: header, DACL, group, owner
+		dacl := []byte{0x02, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}
+		group := []byte{0x01, 0x01, 0, 0, 0, 0, 0, 0x05, 0x12, 0, 0, 0}                   // S-1-5-18
+		owner := []byte{0x01, 0x02, 0, 0, 0, 0, 0, 0x05, 0x20, 0, 0, 0, 0x20, 0x02, 0, 0} // S-1-5-32-544
+
+		header := winacl.NtSecurityDescriptorHeader{
+			Revision:    1,
+			Control:     0x8004,
+			OffsetDacl:  20,
+			OffsetGroup: 28,
+			OffsetOwner: 40,
+		}
+		buf := bytes.Buffer{}
+		r.NoError(binary.Write(&buf, binary.LittleEndian, &header))
+		buf.Write(dacl)
+		buf.Write(group)
+		buf.Write(owner)
+
+		ntsd, err := winacl.NewNtSecurityDescriptor(buf.Bytes())
+		r.NoError(err)
+		r.Equal("S-1-5-32-544", ntsd.Owner.String())
+		r.Equal("S-1-5-18", ntsd.Group.String())
+		r.NotNil(ntsd.DACL)
+		r.Empty(ntsd.DACL.Aces)
+		r.Nil(ntsd.SACL)
+	})
+
+	t.Run("Leaves components with a zero offset nil", func(t *testing.T) {
+		header := winacl.NtSecurityDescriptorHeader{Revision: 1, Control: 0x8000}
+		buf := bytes.Buffer{}
+		r.NoError(binary.Write(&buf, binary.LittleEndian, &header))
+
+		ntsd, err := winacl.NewNtSecurityDescriptor(buf.Bytes())
+		r.NoError(err)
+		r.Nil(ntsd.Owner)
+		r.Nil(ntsd.Group)
+		r.Nil(ntsd.DACL)
+		r.Nil(ntsd.SACL)
+	})
+
+	t.Run("Parses the SACL when SE_SACL_PRESENT is set", func(t *testing.T) {
+		ntsd, err := winacl.NewNtSecurityDescriptor(newTestSACLBytes(r, 0x8014))
+		r.NoError(err)
+		r.NotNil(ntsd.SACL)
+		r.Len(ntsd.SACL.Aces, 1)
+		r.Equal(winacl.AceTypeSystemAudit, ntsd.SACL.Aces[0].GetType())
+		r.Equal(winacl.ACLStatePopulated, ntsd.SACLState())
+		r.Equal(winacl.ACLStateNull, ntsd.DACLState())
+	})
+
+	t.Run("Ignores the SACL when SE_SACL_PRESENT is not set", func(t *testing.T) {
+		ntsd, err := winacl.NewNtSecurityDescriptor(newTestSACLBytes(r, 0x8000))
+		r.NoError(err)
+		r.Nil(ntsd.SACL)
+		r.Equal(winacl.ACLStateAbsent, ntsd.SACLState())
+		r.Equal(winacl.ACLStateAbsent, ntsd.DACLState())
+	})
+
+	t.Run("Returns an error when an offset is out of bounds", func(t *testing.T) {
+		header := winacl.NtSecurityDescriptorHeader{Revision: 1, OffsetOwner: 200}
+		buf := bytes.Buffer{}
+		r.NoError(binary.Write(&buf, binary.LittleEndian, &header))
+
+		_, err := winacl.NewNtSecurityDescriptor(buf.Bytes())
+		r.Error(err)
 	})
 
 	t.Run("Returns an error when given a malformed SD", func(t *testing.T) {
@@ -30,6 +106,44 @@ func TestNewNtSecurityDescriptor(t *testing.T) {
 	})
 }
 
+func TestNtSecurityDescriptorACLState(t *testing.T) {
+	r := require.New(t)
+
+	sd := winacl.NtSecurityDescriptor{}
+	r.Equal(winacl.ACLStateAbsent, sd.DACLState())
+
+	sd.Header.Control = winacl.DACLPresent
+	r.Equal(winacl.ACLStateNull, sd.DACLState())
+
+	sd.DACL = &winacl.ACL{}
+	r.Equal(winacl.ACLStateEmpty, sd.DACLState())
+
+	sd.DACL.Aces = []winacl.ACE{{}}
+	r.Equal(winacl.ACLStatePopulated, sd.DACLState())
+}
+
+// newTestSACLBytes returns a self-relative descriptor holding only a SACL
+// with a single success/failure audit ACE for Everyone
+func newTestSACLBytes(r *require.Assertions, control uint16) []byte {
+	ace := []byte{
+		0x02, 0xC0, 0x14, 0x00, // SYSTEM_AUDIT, SA|FA, size 20
+		0x00, 0x00, 0x00, 0x10, // GENERIC_ALL
+		0x01, 0x01, 0, 0, 0, 0, 0, 0x01, 0, 0, 0, 0, // S-1-1-0
+	}
+	aclHeader := []byte{0x02, 0x00, 0x1C, 0x00, 0x01, 0x00, 0x00, 0x00}
+
+	header := winacl.NtSecurityDescriptorHeader{
+		Revision:   1,
+		Control:    control,
+		OffsetSacl: 20,
+	}
+	buf := bytes.Buffer{}
+	r.NoError(binary.Write(&buf, binary.LittleEndian, &header))
+	buf.Write(aclHeader)
+	buf.Write(ace)
+	return buf.Bytes()
+}
+
 func TestNtSecurityDescriptorString(t *testing.T) {
 	r := require.New(t)
 
@@ -54,3 +168,54 @@ func TestToSDDL(t *testing.T) {
 		r.Equal(sddl, ntsd.ToSDDL())
 	})
 }
+
+func TestNtSecurityDescriptorMarshalBinary(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Round-trips the test descriptor byte-for-byte", func(t *testing.T) {
+		ntsdBytes, err := getTestNtsdBytes()
+		r.NoError(err)
+
+		ntsd, err := winacl.NewNtSecurityDescriptor(ntsdBytes)
+		r.NoError(err)
+
+		out, err := ntsd.MarshalBinary()
+		r.NoError(err)
+		r.Equal(ntsdBytes, out)
+	})
+
+	t.Run("Recomputes offsets and control bits", func(t *testing.T) {
+		owner, err := winacl.NewSIDFromString("S-1-5-18")
+		r.NoError(err)
+
+		sd := winacl.NtSecurityDescriptor{
+			Owner: &owner,
+			DACL:  &winacl.ACL{},
+		}
+		out, err := sd.MarshalBinary()
+		r.NoError(err)
+
+		var parsed winacl.NtSecurityDescriptor
+		r.NoError(parsed.UnmarshalBinary(out))
+		r.Equal(uint32(20), parsed.Header.OffsetDacl)
+		r.Equal(uint32(28), parsed.Header.OffsetOwner)
+		r.Zero(parsed.Header.OffsetGroup)
+		r.True(parsed.Header.HasControl(winacl.SelfRelative | winacl.DACLPresent))
+		r.False(parsed.Header.HasControl(winacl.SACLPresent))
+		r.Equal(winacl.ACLStateEmpty, parsed.DACLState())
+		r.Equal("S-1-5-18", parsed.Owner.String())
+		r.Nil(parsed.Group)
+	})
+
+	t.Run("Keeps a NULL DACL distinct from no DACL", func(t *testing.T) {
+		sd := winacl.NtSecurityDescriptor{
+			Header: winacl.NtSecurityDescriptorHeader{Control: winacl.DACLPresent},
+		}
+		out, err := sd.MarshalBinary()
+		r.NoError(err)
+
+		var parsed winacl.NtSecurityDescriptor
+		r.NoError(parsed.UnmarshalBinary(out))
+		r.Equal(winacl.ACLStateNull, parsed.DACLState())
+	})
+}
@@ -17,11 +17,26 @@ type NtSecurityDescriptorHeader struct {
 	OffsetDacl  uint32
 }
 
+// Security descriptor control bits
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/7d4dac05-9cef-4563-a058-f108abecce1d
 const (
+	OwnerDefaulted     = 0x0001
+	GroupDefaulted     = 0x0002
+	DACLPresent        = 0x0004
+	DACLDefaulted      = 0x0008
+	SACLPresent        = 0x0010
+	SACLDefaulted      = 0x0020
+	DACLTrusted        = 0x0040
+	ServerSecurity     = 0x0080
 	DACLAutoInheritReq = 0x0100
+	SACLAutoInheritReq = 0x0200
 	DACLAutoInherited  = 0x0400
 	SACLAutoInherited  = 0x0800
 	DACLProtected      = 0x1000
+	SACLProtected      = 0x2000
+	RMControlValid     = 0x4000
+	SelfRelative       = 0x8000
 )
 
 // NewNTSDHeader is a constructor that will parse out an
@@ -34,3 +49,8 @@ func NewNTSDHeader(buf *bytes.Buffer) (header NtSecurityDescriptorHeader, err er
 	}
 	return header, nil
 }
+
+// HasControl reports whether all of the given control bits are set
+func (header NtSecurityDescriptorHeader) HasControl(bits uint16) bool {
+	return header.Control&bits == bits
+}
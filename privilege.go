@@ -0,0 +1,102 @@
+package winacl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Privileges that grant access independently of the DACL. Names match
+// those accepted by LookupPrivilegeValue
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/privilege-constants
+const (
+	SeBackupPrivilege        = "SeBackupPrivilege"
+	SeRestorePrivilege       = "SeRestorePrivilege"
+	SeSecurityPrivilege      = "SeSecurityPrivilege"
+	SeTakeOwnershipPrivilege = "SeTakeOwnershipPrivilege"
+)
+
+// HasPrivilege reports whether the token holds the named privilege.
+// Privilege names are compared case-insensitively
+func (t *TokenUser) HasPrivilege(name string) bool {
+	for _, privilege := range t.Privileges {
+		if strings.EqualFold(privilege, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// privilegeGrant is access granted to a token by one of its privileges
+type privilegeGrant struct {
+	Privilege string
+	Access    uint32
+}
+
+// privilegeGrants returns the requested rights granted by the token's
+// privileges, in the order Windows checks them. SeSecurityPrivilege grants
+// ACCESS_SYSTEM_SECURITY and SeTakeOwnershipPrivilege grants WRITE_OWNER.
+// With backup intent, SeBackupPrivilege grants read access and
+// SeRestorePrivilege grants write access
+func privilegeGrants(token *TokenUser, options *AccessCheckOptions, mappedAccess uint32) []privilegeGrant {
+	rights := []privilegeGrant{
+		{SeSecurityPrivilege, AccessMaskSystemSecurity},
+		{SeTakeOwnershipPrivilege, AccessMaskWriteOwner},
+	}
+
+	if options.BackupIntent {
+		backup := MapGenericAccess(AccessMaskGenericRead, options.GenericMapping) |
+			AccessMaskReadControl | AccessMaskSystemSecurity
+		restore := MapGenericAccess(AccessMaskGenericWrite, options.GenericMapping) |
+			AccessMaskWriteDACL | AccessMaskWriteOwner | AccessMaskDelete | AccessMaskSystemSecurity
+
+		rights = append(rights,
+			privilegeGrant{SeBackupPrivilege, backup},
+			privilegeGrant{SeRestorePrivilege, restore},
+		)
+	}
+
+	grants := make([]privilegeGrant, 0)
+	for _, right := range rights {
+		access := right.Access & mappedAccess
+		if access != 0 && token.HasPrivilege(right.Privilege) {
+			grants = append(grants, privilegeGrant{right.Privilege, access})
+		}
+	}
+	return grants
+}
+
+// privilegeAccess returns the union of the rights granted by privileges
+func privilegeAccess(grants []privilegeGrant) uint32 {
+	access := uint32(0)
+	for _, grant := range grants {
+		access |= grant.Access
+	}
+	return access
+}
+
+// privilegeDetails describes the rights each privilege granted
+func privilegeDetails(grants []privilegeGrant) []CheckDetails {
+	details := make([]CheckDetails, 0, len(grants))
+	for _, grant := range grants {
+		details = append(details, CheckDetails{
+			Step:        fmt.Sprintf("Privilege[%s]", grant.Privilege),
+			Description: fmt.Sprintf("%s granted access mask 0x%08X", grant.Privilege, grant.Access),
+			Outcome:     true,
+		})
+	}
+	return details
+}
+
+// checkSecurityPrivilege fails requests for ACCESS_SYSTEM_SECURITY that no
+// privilege grants, since a DACL cannot grant it
+func checkSecurityPrivilege(grants []privilegeGrant, mappedAccess uint32) CheckDetails {
+	granted := mappedAccess&AccessMaskSystemSecurity == 0 ||
+		privilegeAccess(grants)&AccessMaskSystemSecurity != 0
+
+	return CheckDetails{
+		Step:        "SecurityPrivilege",
+		Description: fmt.Sprintf("Checking if ACCESS_SYSTEM_SECURITY is granted by %s: %v", SeSecurityPrivilege, granted),
+		Outcome:     granted,
+	}
+}
@@ -0,0 +1,110 @@
+package winacl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivileges(t *testing.T) {
+	r := require.New(t)
+
+	userSID := mustSID(t, "S-1-5-21-1-2-3-1001")
+	ntsd := mustParseSDDL(t, "O:SYD:(A;;0x1;;;SY)")
+
+	newToken := func(privileges ...string) *winacl.TokenUser {
+		token := winacl.NewTokenUser(userSID, nil)
+		token.Privileges = privileges
+		return token
+	}
+
+	privilegeStep := func(result *winacl.AccessCheckResult, privilege string) bool {
+		for _, detail := range result.Details {
+			if detail.Step == "Privilege["+privilege+"]" && strings.Contains(detail.Description, privilege) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("SeTakeOwnershipPrivilege grants WRITE_OWNER", func(t *testing.T) {
+		result := winacl.AccessCheck(ntsd, newToken(), winacl.AccessMaskWriteOwner, nil)
+		r.False(result.Granted)
+
+		result = winacl.AccessCheck(ntsd, newToken("setakeownershipprivilege"), winacl.AccessMaskWriteOwner, nil)
+		r.True(result.Granted, result.Reason)
+		r.True(privilegeStep(result, winacl.SeTakeOwnershipPrivilege))
+	})
+
+	t.Run("ACCESS_SYSTEM_SECURITY requires SeSecurityPrivilege", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:SYD:NO_ACCESS_CONTROL", newToken(), winacl.AccessMaskSystemSecurity, nil)
+		r.False(result.Granted)
+
+		result = winacl.AccessCheck(ntsd, newToken(winacl.SeSecurityPrivilege), winacl.AccessMaskSystemSecurity, nil)
+		r.True(result.Granted, result.Reason)
+		r.True(privilegeStep(result, winacl.SeSecurityPrivilege))
+	})
+
+	t.Run("Backup and restore need backup intent", func(t *testing.T) {
+		token := newToken(winacl.SeBackupPrivilege, winacl.SeRestorePrivilege)
+		options := winacl.DefaultAccessCheckOptions()
+
+		result := winacl.AccessCheck(ntsd, token, winacl.AccessMaskGenericRead, options)
+		r.False(result.Granted)
+
+		options.BackupIntent = true
+		result = winacl.AccessCheck(ntsd, token, winacl.AccessMaskGenericRead, options)
+		r.True(result.Granted, result.Reason)
+		r.True(privilegeStep(result, winacl.SeBackupPrivilege))
+
+		result = winacl.AccessCheck(ntsd, token, winacl.AccessMaskGenericWrite|winacl.AccessMaskDelete, options)
+		r.True(result.Granted, result.Reason)
+		r.True(privilegeStep(result, winacl.SeRestorePrivilege))
+
+		// Privileges combine with rights granted by the DACL
+		result = winacl.AccessCheck(ntsd, newToken(winacl.SeBackupPrivilege), winacl.AccessMaskDelete, options)
+		r.False(result.Granted)
+	})
+
+	t.Run("Privileges are applied before deny ACEs", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:SYD:(D;;WO;;;WD)(A;;0x1;;;WD)", newToken(winacl.SeTakeOwnershipPrivilege), winacl.AccessMaskWriteOwner|0x1, nil)
+		r.True(result.Granted, result.Reason)
+	})
+
+	t.Run("Maximum allowed names the granting privilege", func(t *testing.T) {
+		result := winacl.EffectiveAccess(ntsd, newToken(winacl.SeTakeOwnershipPrivilege, winacl.SeSecurityPrivilege), nil)
+		r.Equal(uint32(winacl.AccessMaskWriteOwner), result.Access)
+		r.Len(result.Rights, 1)
+		r.Equal(winacl.DecisionSourcePrivilege, result.Rights[0].Source)
+		r.Equal(winacl.SeTakeOwnershipPrivilege, result.Rights[0].Privilege)
+	})
+
+	t.Run("Maximum allowed only includes ACCESS_SYSTEM_SECURITY when requested", func(t *testing.T) {
+		token := newToken(winacl.SeSecurityPrivilege)
+		result := winacl.EffectiveAccess(ntsd, token, nil)
+		r.Zero(result.Access & winacl.AccessMaskSystemSecurity)
+
+		result = winacl.AccessCheck(ntsd, token, winacl.AccessMaskMaximumAllowed|winacl.AccessMaskSystemSecurity, nil)
+		r.True(result.Granted, result.Reason)
+		r.Equal(uint32(winacl.AccessMaskSystemSecurity), result.Access)
+		r.Equal(winacl.SeSecurityPrivilege, result.Rights[0].Privilege)
+	})
+
+	t.Run("AccessCheckByType applies privileges to the whole object", func(t *testing.T) {
+		classGUID, err := winacl.NewGUIDFromString("bf967aba-0de6-11d0-a285-00aa003049e2")
+		r.NoError(err)
+		objectTypes := []winacl.ObjectTypeNode{{Level: winacl.ObjectTypeLevelObject, GUID: classGUID}}
+
+		result, err := winacl.AccessCheckByType(ntsd, newToken(winacl.SeTakeOwnershipPrivilege),
+			winacl.AccessMaskWriteOwner, objectTypes, nil)
+		r.NoError(err)
+		r.True(result.Granted())
+
+		result, err = winacl.AccessCheckByType(ntsd, newToken(winacl.SeSecurityPrivilege),
+			winacl.AccessMaskMaximumAllowed, objectTypes, nil)
+		r.NoError(err)
+		r.Zero(result.Results[0].Access & winacl.AccessMaskSystemSecurity)
+	})
+}
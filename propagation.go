@@ -0,0 +1,240 @@
+package winacl
+
+import (
+	"fmt"
+)
+
+// SecurityDescriptorNode is an object in a hierarchy, such as a folder in a
+// directory tree or an object in an OU, together with its children
+type SecurityDescriptorNode struct {
+	Name        string
+	Descriptor  *NtSecurityDescriptor
+	IsContainer bool
+	ObjectType  *GUID // The object's class GUID, used to filter object ACEs
+	Children    []*SecurityDescriptorNode
+}
+
+// ACLDiff holds the ACEs a propagation added to and removed from an ACL
+type ACLDiff struct {
+	Added   []ACE
+	Removed []ACE
+}
+
+// Changed reports whether the ACL changed
+func (d ACLDiff) Changed() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0
+}
+
+// PropagationChange describes how a propagation changed one node
+type PropagationChange struct {
+	Node   *SecurityDescriptorNode
+	Path   []string // Names of the nodes from the root's child down to Node
+	Before NtSecurityDescriptor
+	After  NtSecurityDescriptor
+	DACL   ACLDiff
+	SACL   ACLDiff
+
+	// DACLProtected and SACLProtected report that the node's ACL is
+	// protected, and so was left as it was
+	DACLProtected bool
+	SACLProtected bool
+}
+
+// Changed reports whether the node's DACL or SACL changed
+func (c PropagationChange) Changed() bool {
+	return c.DACL.Changed() || c.SACL.Changed()
+}
+
+// PropagateInheritance recomputes the inherited ACEs of every descendant of
+// root from root's descriptor, as SetNamedSecurityInfo or the directory
+// service's descriptor propagator do after root's ACL changes. Each node
+// keeps its explicit ACEs, followed by the ACEs it inherits from its
+// parent's new descriptor; the INHERITED_ACE ACEs it had are replaced.
+// Inheritance stops at protected ACLs, which are left as they were, so
+// nodes below them only change if they were out of date. Descriptors are
+// updated in place and a change is returned for every descendant, in
+// depth-first order
+func PropagateInheritance(root *SecurityDescriptorNode, options *InheritanceOptions) ([]PropagationChange, error) {
+	if options == nil {
+		options = DefaultInheritanceOptions()
+	}
+	if root.Descriptor == nil {
+		return nil, fmt.Errorf("node %q has no security descriptor", root.Name)
+	}
+
+	var changes []PropagationChange
+	err := propagateToChildren(root, nil, options, &changes)
+	return changes, err
+}
+
+// propagateToChildren updates the descriptors of parent's children and then
+// of their own children
+func propagateToChildren(parent *SecurityDescriptorNode, path []string,
+	options *InheritanceOptions, changes *[]PropagationChange,
+) error {
+	for _, node := range parent.Children {
+		childPath := append(append([]string(nil), path...), node.Name)
+		if node.Descriptor == nil {
+			return fmt.Errorf("node %q has no security descriptor", node.Name)
+		}
+
+		change, err := propagateToNode(parent.Descriptor, node, options)
+		if err != nil {
+			return fmt.Errorf("propagating to %q: %w", node.Name, err)
+		}
+		change.Path = childPath
+		*changes = append(*changes, change)
+
+		if err := propagateToChildren(node, childPath, options, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// propagateToNode recomputes a node's inherited ACEs from its parent's
+// descriptor
+func propagateToNode(parent *NtSecurityDescriptor, node *SecurityDescriptorNode,
+	options *InheritanceOptions,
+) (PropagationChange, error) {
+	mapping := options.GenericMapping
+	if mapping == nil && options.ObjectType != SecurableGeneric {
+		mapping = GenericMappingFor(options.ObjectType)
+	}
+
+	before := *node.Descriptor
+	after := before
+	inherit := aceInheritance{
+		isContainer: node.IsContainer,
+		objectType:  node.ObjectType,
+		owner:       before.Owner,
+		group:       before.Group,
+		mapping:     mapping,
+	}
+
+	dacl := aclInheritance{
+		present:       DACLPresent,
+		protected:     DACLProtected,
+		autoInherited: DACLAutoInherited,
+		autoInherit:   options.Flags&AutoInheritDACL != 0,
+	}
+	if err := dacl.propagate(&after, parent, inherit); err != nil {
+		return PropagationChange{}, fmt.Errorf("computing DACL: %w", err)
+	}
+
+	sacl := aclInheritance{
+		present:       SACLPresent,
+		protected:     SACLProtected,
+		autoInherited: SACLAutoInherited,
+		autoInherit:   options.Flags&AutoInheritSACL != 0,
+		sacl:          true,
+	}
+	if err := sacl.propagate(&after, parent, inherit); err != nil {
+		return PropagationChange{}, fmt.Errorf("computing SACL: %w", err)
+	}
+
+	node.Descriptor = &after
+	return PropagationChange{
+		Node:          node,
+		Before:        before,
+		After:         after,
+		DACL:          diffACL(before.DACL, after.DACL),
+		SACL:          diffACL(before.SACL, after.SACL),
+		DACLProtected: before.Header.HasControl(DACLProtected),
+		SACLProtected: before.Header.HasControl(SACLProtected),
+	}, nil
+}
+
+// propagate replaces the inherited ACEs of the node's ACL with those it
+// inherits from the parent. Protected ACLs are left alone, as are absent
+// and NULL ACLs that would not inherit anything
+func (ai aclInheritance) propagate(node, parent *NtSecurityDescriptor, inherit aceInheritance) error {
+	if node.Header.Control&ai.protected != 0 {
+		return nil
+	}
+
+	var inherited []ACE
+	if parentACL, _ := ai.acl(parent); parentACL != nil {
+		var err error
+		if inherited, err = inherit.fromParent(parentACL.Aces); err != nil {
+			return err
+		}
+	}
+
+	current, _ := ai.acl(node)
+	var aces []ACE
+	if current != nil {
+		for _, ace := range current.Aces {
+			if ace.Header.Flags&ACEHeaderFlagsInheritedAce == 0 {
+				aces = append(aces, ace)
+			}
+		}
+	}
+	aces = append(aces, inherited...)
+	if current == nil && len(aces) == 0 {
+		return nil
+	}
+
+	acl := ACL{Aces: aces}
+	if err := acl.recomputeHeader(); err != nil {
+		return err
+	}
+	node.Header.Control |= ai.present
+	if ai.autoInherit {
+		node.Header.Control |= ai.autoInherited
+	}
+	if ai.sacl {
+		node.SACL = &acl
+	} else {
+		node.DACL = &acl
+	}
+	return nil
+}
+
+// diffACL returns the ACEs in after that are not in before, and those in
+// before that are not in after. ACEs are compared by their binary form and
+// counted, so duplicates are accounted for
+func diffACL(before, after *ACL) ACLDiff {
+	var beforeAces, afterAces []ACE
+	if before != nil {
+		beforeAces = before.Aces
+	}
+	if after != nil {
+		afterAces = after.Aces
+	}
+
+	return ACLDiff{
+		Added:   subtractACEs(afterAces, beforeAces),
+		Removed: subtractACEs(beforeAces, afterAces),
+	}
+}
+
+// subtractACEs returns the ACEs of a that remain after removing one
+// matching ACE for each ACE of b
+func subtractACEs(a, b []ACE) []ACE {
+	remaining := make(map[string]int, len(b))
+	for _, ace := range b {
+		remaining[aceKey(ace)]++
+	}
+
+	var out []ACE
+	for _, ace := range a {
+		key := aceKey(ace)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		out = append(out, ace)
+	}
+	return out
+}
+
+// aceKey returns a key that is equal for ACEs with the same binary form
+func aceKey(ace ACE) string {
+	data, err := ace.MarshalBinary()
+	if err != nil {
+		// Fall back on the SDDL form, which holds every field that matters
+		return ace.ToSDDL()
+	}
+	return string(data)
+}
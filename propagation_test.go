@@ -0,0 +1,86 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagateInheritance(t *testing.T) {
+	r := require.New(t)
+
+	// root
+	// ├── docs (explicit ACE for WD)
+	// │   └── report.txt
+	// └── private (protected)
+	//     └── secret.txt
+	report := &winacl.SecurityDescriptorNode{
+		Name:       "report.txt",
+		Descriptor: mustParseSDDL(t, "O:BAG:SYD:AI(A;ID;CC;;;BU)"),
+	}
+	docs := &winacl.SecurityDescriptorNode{
+		Name:        "docs",
+		IsContainer: true,
+		Descriptor:  mustParseSDDL(t, "O:BAG:SYD:AI(A;;CC;;;WD)(A;OICIID;CC;;;BU)"),
+		Children:    []*winacl.SecurityDescriptorNode{report},
+	}
+	secret := &winacl.SecurityDescriptorNode{
+		Name:       "secret.txt",
+		Descriptor: mustParseSDDL(t, "O:BAG:SYD:AI(A;ID;FA;;;BA)"),
+	}
+	private := &winacl.SecurityDescriptorNode{
+		Name:        "private",
+		IsContainer: true,
+		Descriptor:  mustParseSDDL(t, "O:BAG:SYD:PAI(A;OICI;FA;;;BA)"),
+		Children:    []*winacl.SecurityDescriptorNode{secret},
+	}
+	root := &winacl.SecurityDescriptorNode{
+		Name:        "root",
+		IsContainer: true,
+		// BU's ACE was changed from CC and AU's added
+		Descriptor: mustParseSDDL(t, "O:BAG:SYD:AI(A;OICI;DC;;;BU)(A;CI;CC;;;AU)"),
+		Children:   []*winacl.SecurityDescriptorNode{docs, private},
+	}
+
+	const owner = "O:S-1-5-32-544G:S-1-5-18"
+
+	changes, err := winacl.PropagateInheritance(root, nil)
+	r.NoError(err)
+	r.Len(changes, 4)
+
+	t.Run("Explicit ACEs are kept and inherited ACEs replaced", func(t *testing.T) {
+		change := changes[0]
+		r.Equal([]string{"docs"}, change.Path)
+		r.Same(docs, change.Node)
+		r.Equal(owner+"D:AI(A;;CC;;;WD)(A;OICIID;DC;;;BU)(A;CIID;CC;;;AU)",
+			docs.Descriptor.ToSDDL())
+
+		r.True(change.Changed())
+		r.Len(change.DACL.Added, 2)
+		r.Len(change.DACL.Removed, 1)
+		r.Equal("(A;OICIID;CC;;;BU)", change.DACL.Removed[0].ToSDDL())
+		r.False(change.SACL.Changed())
+	})
+
+	t.Run("Changes reach grandchildren", func(t *testing.T) {
+		r.Equal([]string{"docs", "report.txt"}, changes[1].Path)
+		r.Equal(owner+"D:AI(A;ID;DC;;;BU)", report.Descriptor.ToSDDL())
+	})
+
+	t.Run("Protected DACLs stop inheritance", func(t *testing.T) {
+		r.True(changes[2].DACLProtected)
+		r.False(changes[2].Changed())
+		r.False(changes[3].Changed())
+		r.Equal(owner+"D:AI(A;ID;FA;;;BA)", secret.Descriptor.ToSDDL())
+	})
+
+	t.Run("Missing descriptors are reported", func(t *testing.T) {
+		_, err := winacl.PropagateInheritance(&winacl.SecurityDescriptorNode{
+			Name:       "root",
+			Descriptor: mustParseSDDL(t, "D:(A;OICI;CC;;;BU)"),
+			Children:   []*winacl.SecurityDescriptorNode{{Name: "orphan"}},
+		}, nil)
+		r.ErrorContains(err, "orphan")
+	})
+}
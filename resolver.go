@@ -0,0 +1,456 @@
+package winacl
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SIDResolver resolves SIDs to account names, such as CORP\svc_backup
+type SIDResolver interface {
+	// ResolveSID returns the name of a SID, and false when it does not
+	// know the SID
+	ResolveSID(sid SID) (string, bool)
+}
+
+// SIDResolverFunc adapts a function to a SIDResolver
+type SIDResolverFunc func(sid SID) (string, bool)
+
+// ResolveSID calls f
+func (f SIDResolverFunc) ResolveSID(sid SID) (string, bool) {
+	return f(sid)
+}
+
+// ChainResolver asks each of its resolvers in turn and returns the first
+// name found
+type ChainResolver []SIDResolver
+
+// ResolveSID implements SIDResolver
+func (c ChainResolver) ResolveSID(sid SID) (string, bool) {
+	for _, resolver := range c {
+		if resolver == nil {
+			continue
+		}
+		if name, ok := resolver.ResolveSID(sid); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// WellKnownResolver resolves the SIDs in WellKnownSIDs and the patterns in
+// WellKnownSIDsRE, which SID.Resolve uses
+type WellKnownResolver struct{}
+
+// wellKnownPattern is a compiled entry of WellKnownSIDsRE
+type wellKnownPattern struct {
+	re   *regexp.Regexp
+	name string
+}
+
+var (
+	wellKnownPatternsOnce sync.Once
+	wellKnownPatterns     []wellKnownPattern
+)
+
+// compiledWellKnownPatterns compiles WellKnownSIDsRE the first time it is
+// needed. Patterns are anchored and tried in sorted order, so SIDs that
+// match more than one always resolve to the same name
+func compiledWellKnownPatterns() []wellKnownPattern {
+	wellKnownPatternsOnce.Do(func() {
+		patterns := make([]string, 0, len(WellKnownSIDsRE))
+		for pattern := range WellKnownSIDsRE {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		for _, pattern := range patterns {
+			re, err := regexp.Compile("^" + pattern + "$")
+			if err != nil {
+				continue
+			}
+			wellKnownPatterns = append(wellKnownPatterns, wellKnownPattern{re, WellKnownSIDsRE[pattern]})
+		}
+	})
+	return wellKnownPatterns
+}
+
+// ResolveSID implements SIDResolver
+func (WellKnownResolver) ResolveSID(sid SID) (string, bool) {
+	s := sid.String()
+	if name, ok := WellKnownSIDs[s]; ok {
+		return name, true
+	}
+	for _, pattern := range compiledWellKnownPatterns() {
+		if pattern.re.MatchString(s) {
+			return pattern.name, true
+		}
+	}
+	return "", false
+}
+
+// DomainRIDs maps the well-known relative identifiers of domain accounts
+// and groups to their names
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/well-known-sids
+var DomainRIDs = map[uint32]string{
+	498: "Enterprise Read-only Domain Controllers",
+	500: "Administrator",
+	501: "Guest",
+	502: "krbtgt",
+	512: "Domain Admins",
+	513: "Domain Users",
+	514: "Domain Guests",
+	515: "Domain Computers",
+	516: "Domain Controllers",
+	517: "Cert Publishers",
+	518: "Schema Admins",
+	519: "Enterprise Admins",
+	520: "Group Policy Creator Owners",
+	521: "Read-only Domain Controllers",
+	522: "Cloneable Domain Controllers",
+	525: "Protected Users",
+	526: "Key Admins",
+	527: "Enterprise Key Admins",
+	553: "RAS and IAS Servers",
+	571: "Allowed RODC Password Replication Group",
+	572: "Denied RODC Password Replication Group",
+}
+
+// DomainResolver resolves the well-known RIDs of one domain, or of a
+// machine's local accounts, as DOMAIN\name
+type DomainResolver struct {
+	DomainSID  SID
+	DomainName string            // NetBIOS name, such as CORP
+	RIDs       map[uint32]string // Names of RIDs; nil uses DomainRIDs
+}
+
+// ResolveSID implements SIDResolver
+func (d DomainResolver) ResolveSID(sid SID) (string, bool) {
+	if !sid.IsDomainRelative() || !sid.Domain().Equal(d.DomainSID) {
+		return "", false
+	}
+
+	rids := d.RIDs
+	if rids == nil {
+		rids = DomainRIDs
+	}
+	name, ok := rids[sid.RID()]
+	if !ok {
+		return "", false
+	}
+	return qualifiedName(d.DomainName, name), true
+}
+
+// qualifiedName returns DOMAIN\name, or name alone without a domain
+func qualifiedName(domain, name string) string {
+	if domain == "" {
+		return name
+	}
+	return domain + `\` + name
+}
+
+// AccountResolver resolves the SIDs of a list of accounts, such as one
+// loaded from a dump of a directory or of a machine's SAM
+type AccountResolver struct {
+	names map[SIDKey]string
+}
+
+// NewAccountResolver returns an empty AccountResolver
+func NewAccountResolver() *AccountResolver {
+	return &AccountResolver{names: make(map[SIDKey]string)}
+}
+
+// Add records the name of a SID, replacing any name it had
+func (a *AccountResolver) Add(sid SID, name string) {
+	a.names[sid.Key()] = name
+}
+
+// Len returns the number of accounts the resolver knows
+func (a *AccountResolver) Len() int {
+	return len(a.names)
+}
+
+// ResolveSID implements SIDResolver
+func (a *AccountResolver) ResolveSID(sid SID) (string, bool) {
+	name, ok := a.names[sid.Key()]
+	return name, ok
+}
+
+// LoadLDIFAccounts reads the objectSid and sAMAccountName attributes of
+// the entries of an LDIF dump, such as ldapsearch writes. objectSid may be
+// base64-encoded binary or a SID string. Names are qualified with domain,
+// or when it is empty with the first DC= component of each entry's DN
+func LoadLDIFAccounts(r io.Reader, domain string) (*AccountResolver, error) {
+	accounts := NewAccountResolver()
+	entry := make(map[string]string)
+
+	flush := func() error {
+		defer func() { entry = make(map[string]string) }()
+		sidValue, name := entry["objectsid"], entry["samaccountname"]
+		if sidValue == "" || name == "" {
+			return nil
+		}
+
+		sid, err := NewSIDFromString(sidValue)
+		if err != nil {
+			return fmt.Errorf("entry %q: %w", entry["dn"], err)
+		}
+		entryDomain := domain
+		if entryDomain == "" {
+			entryDomain = netBIOSNameFromDN(entry["dn"])
+		}
+		accounts.Add(sid, qualifiedName(entryDomain, name))
+		return nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Lines starting with a space continue the previous one
+		if strings.HasPrefix(line, " ") && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading LDIF: %w", err)
+	}
+
+	for i, line := range lines {
+		if line == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		attr, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("LDIF line %d: expected attribute: value", i+1)
+		}
+		attr = strings.ToLower(attr)
+
+		if strings.HasPrefix(value, ":") {
+			data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("LDIF line %d: %w", i+1, err)
+			}
+			if attr == "objectsid" {
+				var sid SID
+				if err := sid.UnmarshalBinary(data); err != nil {
+					return nil, fmt.Errorf("LDIF line %d: %w", i+1, err)
+				}
+				value = sid.String()
+			} else {
+				value = string(data)
+			}
+		}
+		entry[attr] = strings.TrimSpace(value)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// netBIOSNameFromDN guesses a domain's NetBIOS name from the first DC=
+// component of a DN, as CORP for CN=svc,DC=corp,DC=local
+func netBIOSNameFromDN(dn string) string {
+	for _, rdn := range strings.Split(dn, ",") {
+		attr, value, ok := strings.Cut(strings.TrimSpace(rdn), "=")
+		if ok && strings.EqualFold(attr, "DC") {
+			return strings.ToUpper(value)
+		}
+	}
+	return ""
+}
+
+// accountRecord is an account in a CSV or JSON dump
+type accountRecord struct {
+	SID    string `json:"sid"`
+	Name   string `json:"name"`
+	Domain string `json:"domain"`
+}
+
+// accountColumns are the CSV headers accepted for each field of an
+// accountRecord, compared case-insensitively
+var accountColumns = map[string]string{
+	"sid":            "sid",
+	"objectsid":      "sid",
+	"name":           "name",
+	"samaccountname": "name",
+	"domain":         "domain",
+}
+
+// LoadCSVAccounts reads accounts from CSV with a header row. The sid (or
+// objectSid) and name (or sAMAccountName) columns are required; a domain
+// column qualifies the names
+func LoadCSVAccounts(r io.Reader) (*AccountResolver, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		if field, ok := accountColumns[strings.ToLower(strings.TrimSpace(column))]; ok {
+			columns[field] = i
+		}
+	}
+	if _, ok := columns["sid"]; !ok {
+		return nil, fmt.Errorf("CSV has no sid column")
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV has no name column")
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []accountRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+		records = append(records, accountRecord{
+			SID:    field(row, "sid"),
+			Name:   field(row, "name"),
+			Domain: field(row, "domain"),
+		})
+	}
+	return accountResolverFromRecords(records)
+}
+
+// LoadJSONAccounts reads accounts from a JSON array of objects with sid,
+// name and, optionally, domain fields
+func LoadJSONAccounts(r io.Reader) (*AccountResolver, error) {
+	var records []accountRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("reading JSON accounts: %w", err)
+	}
+	return accountResolverFromRecords(records)
+}
+
+func accountResolverFromRecords(records []accountRecord) (*AccountResolver, error) {
+	accounts := NewAccountResolver()
+	for i, record := range records {
+		sid, err := NewSIDFromString(record.SID)
+		if err != nil {
+			return nil, fmt.Errorf("account %d: %w", i+1, err)
+		}
+		accounts.Add(sid, qualifiedName(record.Domain, record.Name))
+	}
+	return accounts, nil
+}
+
+// LoadSAMAccounts reads a machine's local accounts from a SAM dump in the
+// pwdump format, name:RID:LM hash:NT hash:::, of which only the name and
+// RID are used. The accounts' SIDs are machineSID followed by their RID,
+// and their names are qualified with machineName
+func LoadSAMAccounts(r io.Reader, machineSID SID, machineName string) (*AccountResolver, error) {
+	accounts := NewAccountResolver()
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, ":")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("SAM line %d: expected name:RID", line)
+		}
+		rid, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("SAM line %d: invalid RID %q", line, fields[1])
+		}
+		accounts.Add(machineSID.WithRID(uint32(rid)), qualifiedName(machineName, fields[0]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading SAM: %w", err)
+	}
+	return accounts, nil
+}
+
+// CachingResolver remembers the answers of another resolver, which may be
+// slow, such as one that queries a directory. It is safe for concurrent use
+type CachingResolver struct {
+	resolver SIDResolver
+
+	mu    sync.RWMutex
+	cache map[SIDKey]cachedName
+}
+
+type cachedName struct {
+	name string
+	ok   bool
+}
+
+// NewCachingResolver returns a CachingResolver in front of resolver
+func NewCachingResolver(resolver SIDResolver) *CachingResolver {
+	return &CachingResolver{
+		resolver: resolver,
+		cache:    make(map[SIDKey]cachedName),
+	}
+}
+
+// ResolveSID implements SIDResolver. Unknown SIDs are cached too
+func (c *CachingResolver) ResolveSID(sid SID) (string, bool) {
+	key := sid.Key()
+
+	c.mu.RLock()
+	cached, found := c.cache[key]
+	c.mu.RUnlock()
+	if found {
+		return cached.name, cached.ok
+	}
+
+	name, ok := c.resolver.ResolveSID(sid)
+	c.mu.Lock()
+	c.cache[key] = cachedName{name, ok}
+	c.mu.Unlock()
+	return name, ok
+}
+
+// Reset empties the cache
+func (c *CachingResolver) Reset() {
+	c.mu.Lock()
+	c.cache = make(map[SIDKey]cachedName)
+	c.mu.Unlock()
+}
+
+// ResolveWith returns the name resolver gives the SID, or its string form
+// when the resolver does not know it. A nil resolver resolves nothing
+func (s SID) ResolveWith(resolver SIDResolver) string {
+	if resolver != nil {
+		if name, ok := resolver.ResolveSID(s); ok {
+			return name
+		}
+	}
+	return s.String()
+}
@@ -0,0 +1,155 @@
+package winacl_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSIDResolvers(t *testing.T) {
+	r := require.New(t)
+
+	domainSID := mustSID(t, "S-1-5-21-1-2-3")
+	svcBackup := mustSID(t, "S-1-5-21-1-2-3-1105")
+
+	t.Run("Well-known SIDs", func(t *testing.T) {
+		name, ok := winacl.WellKnownResolver{}.ResolveSID(mustSID(t, "S-1-5-18"))
+		r.True(ok)
+		r.Equal("Local System", name)
+
+		name, ok = winacl.WellKnownResolver{}.ResolveSID(mustSID(t, "S-1-5-21-1-2-3-512"))
+		r.True(ok)
+		r.Equal("Domain Admins", name)
+
+		// Patterns match whole SIDs
+		_, ok = winacl.WellKnownResolver{}.ResolveSID(mustSID(t, "S-1-5-21-1-2-3-5001"))
+		r.False(ok)
+	})
+
+	t.Run("Domain RIDs", func(t *testing.T) {
+		domain := winacl.DomainResolver{DomainSID: domainSID, DomainName: "CORP"}
+
+		name, ok := domain.ResolveSID(domainSID.WithRID(512))
+		r.True(ok)
+		r.Equal(`CORP\Domain Admins`, name)
+
+		_, ok = domain.ResolveSID(mustSID(t, "S-1-5-21-9-9-9-512"))
+		r.False(ok)
+		_, ok = domain.ResolveSID(svcBackup)
+		r.False(ok)
+	})
+
+	t.Run("LDIF", func(t *testing.T) {
+		binarySID, err := svcBackup.MarshalBinary()
+		r.NoError(err)
+
+		ldif := "# search result\n" +
+			"dn: CN=svc_backup,OU=Service Accounts,DC=corp,DC=local\n" +
+			"objectSid:: " + base64.StdEncoding.EncodeToString(binarySID) + "\n" +
+			"sAMAccountName: svc_back\n" +
+			" up\n" +
+			"\n" +
+			"dn: CN=Helpdesk,DC=corp,DC=local\n" +
+			"objectSid: S-1-5-21-1-2-3-1200\n" +
+			"sAMAccountName: Helpdesk\n" +
+			"\n" +
+			"dn: OU=Service Accounts,DC=corp,DC=local\n"
+
+		accounts, err := winacl.LoadLDIFAccounts(strings.NewReader(ldif), "")
+		r.NoError(err)
+		r.Equal(2, accounts.Len())
+		r.Equal(`CORP\svc_backup`, svcBackup.ResolveWith(accounts))
+		r.Equal(`CORP\Helpdesk`, mustSID(t, "S-1-5-21-1-2-3-1200").ResolveWith(accounts))
+
+		accounts, err = winacl.LoadLDIFAccounts(strings.NewReader(ldif), "EXAMPLE")
+		r.NoError(err)
+		r.Equal(`EXAMPLE\svc_backup`, svcBackup.ResolveWith(accounts))
+
+		_, err = winacl.LoadLDIFAccounts(strings.NewReader("objectSid: S-1-x\nsAMAccountName: a\n"), "")
+		r.Error(err)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		csv := "Domain,sAMAccountName,objectSid\n" +
+			"CORP,svc_backup,S-1-5-21-1-2-3-1105\n"
+
+		accounts, err := winacl.LoadCSVAccounts(strings.NewReader(csv))
+		r.NoError(err)
+		r.Equal(`CORP\svc_backup`, svcBackup.ResolveWith(accounts))
+
+		_, err = winacl.LoadCSVAccounts(strings.NewReader("name\nsvc_backup\n"))
+		r.ErrorContains(err, "sid column")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		accounts, err := winacl.LoadJSONAccounts(strings.NewReader(
+			`[{"sid": "S-1-5-21-1-2-3-1105", "name": "svc_backup", "domain": "CORP"}]`))
+		r.NoError(err)
+		r.Equal(`CORP\svc_backup`, svcBackup.ResolveWith(accounts))
+
+		_, err = winacl.LoadJSONAccounts(strings.NewReader(`[{"sid": "bad"}]`))
+		r.Error(err)
+	})
+
+	t.Run("SAM", func(t *testing.T) {
+		machineSID := mustSID(t, "S-1-5-21-7-8-9")
+		sam := "Administrator:500:aad3b435b51404eeaad3b435b51404ee:31d6cfe0d16ae931b73c59d7e0c089c0:::\n" +
+			"backup_op:1001:aad3b435b51404eeaad3b435b51404ee:31d6cfe0d16ae931b73c59d7e0c089c0:::\n"
+
+		accounts, err := winacl.LoadSAMAccounts(strings.NewReader(sam), machineSID, "WS01")
+		r.NoError(err)
+		r.Equal(`WS01\backup_op`, machineSID.WithRID(1001).ResolveWith(accounts))
+		r.Equal(`WS01\Administrator`, machineSID.WithRID(500).ResolveWith(accounts))
+
+		_, err = winacl.LoadSAMAccounts(strings.NewReader("nobody:x:::"), machineSID, "WS01")
+		r.Error(err)
+	})
+
+	t.Run("Chains and caches", func(t *testing.T) {
+		accounts := winacl.NewAccountResolver()
+		accounts.Add(svcBackup, `CORP\svc_backup`)
+
+		calls := 0
+		counting := winacl.SIDResolverFunc(func(s winacl.SID) (string, bool) {
+			calls++
+			return accounts.ResolveSID(s)
+		})
+
+		resolver := winacl.NewCachingResolver(winacl.ChainResolver{
+			counting,
+			winacl.DomainResolver{DomainSID: domainSID, DomainName: "CORP"},
+			winacl.WellKnownResolver{},
+		})
+
+		r.Equal(`CORP\svc_backup`, svcBackup.ResolveWith(resolver))
+		r.Equal(`CORP\Domain Users`, domainSID.WithRID(513).ResolveWith(resolver))
+		r.Equal("Everyone", mustSID(t, "S-1-1-0").ResolveWith(resolver))
+		r.Equal("S-1-5-21-4-5-6-1", mustSID(t, "S-1-5-21-4-5-6-1").ResolveWith(resolver))
+		r.Equal(4, calls)
+
+		r.Equal(`CORP\svc_backup`, svcBackup.ResolveWith(resolver))
+		r.Equal("S-1-5-21-4-5-6-1", mustSID(t, "S-1-5-21-4-5-6-1").ResolveWith(resolver))
+		r.Equal(4, calls)
+
+		resolver.Reset()
+		svcBackup.ResolveWith(resolver)
+		r.Equal(5, calls)
+	})
+
+	t.Run("Outputs", func(t *testing.T) {
+		accounts := winacl.NewAccountResolver()
+		accounts.Add(svcBackup, `CORP\svc_backup`)
+
+		ntsd := mustParseSDDL(t, "O:S-1-5-21-1-2-3-1105G:SYD:(A;;FA;;;S-1-5-21-1-2-3-1105)(A;;FR;;;BA)")
+
+		r.Equal(`O:CORP\svc_backupG:S-1-5-18D:(A;;FA;;;CORP\svc_backup)(A;;FR;;;BA)`,
+			ntsd.ToSDDLWith(winacl.SDDLOptions{Resolver: accounts}))
+		r.Equal("O:S-1-5-21-1-2-3-1105G:S-1-5-18D:(A;;FA;;;S-1-5-21-1-2-3-1105)(A;;FR;;;BA)", ntsd.ToSDDL())
+
+		r.Contains(ntsd.DACL.Aces[0].StringWith(accounts), `SID: CORP\svc_backup (S-1-5-21-1-2-3-1105)`)
+		r.Contains(ntsd.DACL.Aces[0].String(), "SID: S-1-5-21-1-2-3-1105\n")
+	})
+}
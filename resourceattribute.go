@@ -0,0 +1,423 @@
+package winacl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ClaimValueType is the type of the values of a claim security attribute
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/6ef7da2b-b2e0-4ea6-a43b-7ba2a8bef4e1
+type ClaimValueType uint16
+
+// ClaimValueType constants
+const (
+	ClaimValueTypeInt64       ClaimValueType = 0x0001
+	ClaimValueTypeUint64      ClaimValueType = 0x0002
+	ClaimValueTypeString      ClaimValueType = 0x0003
+	ClaimValueTypeSID         ClaimValueType = 0x0005
+	ClaimValueTypeBoolean     ClaimValueType = 0x0006
+	ClaimValueTypeOctetString ClaimValueType = 0x0010
+)
+
+// ClaimValueTypeSDDL maps ClaimValueTypes to the codes SDDL uses for them
+// in resource attribute ACEs
+var ClaimValueTypeSDDL = map[ClaimValueType]string{
+	ClaimValueTypeInt64:       "TI",
+	ClaimValueTypeUint64:      "TU",
+	ClaimValueTypeString:      "TS",
+	ClaimValueTypeSID:         "TD",
+	ClaimValueTypeBoolean:     "TB",
+	ClaimValueTypeOctetString: "TX",
+}
+
+// Claim security attribute flag constants
+const (
+	ClaimFlagNonInheritable     = 0x0001
+	ClaimFlagValueCaseSensitive = 0x0002
+	ClaimFlagUseForDenyOnly     = 0x0004
+	ClaimFlagDisabledByDefault  = 0x0008
+	ClaimFlagDisabled           = 0x0010
+	ClaimFlagMandatory          = 0x0020
+)
+
+// ClaimSecurityAttribute is the CLAIM_SECURITY_ATTRIBUTE_RELATIVE_V1
+// payload of a resource attribute ACE
+//
+// Values hold int64, uint64, string, SID, bool or []byte elements,
+// according to ValueType
+type ClaimSecurityAttribute struct {
+	Name      string
+	ValueType ClaimValueType
+	Flags     uint32
+	Values    []interface{}
+}
+
+// NewClaimSecurityAttribute is a constructor that will parse out a
+// ClaimSecurityAttribute from its self-relative binary form
+func NewClaimSecurityAttribute(data []byte) (ClaimSecurityAttribute, error) {
+	attr := ClaimSecurityAttribute{}
+	if len(data) < 16 {
+		return attr, fmt.Errorf("claim attribute too short: %d bytes", len(data))
+	}
+
+	nameOffset := binary.LittleEndian.Uint32(data[0:4])
+	attr.ValueType = ClaimValueType(binary.LittleEndian.Uint16(data[4:6]))
+	attr.Flags = binary.LittleEndian.Uint32(data[8:12])
+	count := int(binary.LittleEndian.Uint32(data[12:16]))
+
+	if count > (len(data)-16)/4 {
+		return attr, fmt.Errorf("claim attribute value count %d exceeds data", count)
+	}
+
+	var err error
+	attr.Name, err = utf16StringAtOffset(data, nameOffset)
+	if err != nil {
+		return attr, fmt.Errorf("reading claim attribute name: %w", err)
+	}
+
+	attr.Values = make([]interface{}, count)
+	for i := range attr.Values {
+		offset := binary.LittleEndian.Uint32(data[16+i*4:])
+		attr.Values[i], err = claimValueAtOffset(data, offset, attr.ValueType)
+		if err != nil {
+			return attr, fmt.Errorf("reading claim attribute value %d: %w", i, err)
+		}
+	}
+	return attr, nil
+}
+
+// utf16StringAtOffset reads a null-terminated UTF-16 string
+func utf16StringAtOffset(data []byte, offset uint32) (string, error) {
+	if int64(offset) >= int64(len(data)) {
+		return "", fmt.Errorf("offset %d exceeds data", offset)
+	}
+
+	end := int(offset)
+	for ; end+1 < len(data); end += 2 {
+		if data[end] == 0 && data[end+1] == 0 {
+			return decodeUTF16(data[offset:end])
+		}
+	}
+	return "", fmt.Errorf("unterminated string at offset %d", offset)
+}
+
+func claimValueAtOffset(data []byte, offset uint32, valueType ClaimValueType) (interface{}, error) {
+	if int64(offset) >= int64(len(data)) {
+		return nil, fmt.Errorf("offset %d exceeds data", offset)
+	}
+	value := data[offset:]
+
+	switch valueType {
+	case ClaimValueTypeInt64, ClaimValueTypeUint64, ClaimValueTypeBoolean:
+		if len(value) < 8 {
+			return nil, fmt.Errorf("truncated integer at offset %d", offset)
+		}
+		raw := binary.LittleEndian.Uint64(value)
+		switch valueType {
+		case ClaimValueTypeInt64:
+			return int64(raw), nil
+		case ClaimValueTypeUint64:
+			return raw, nil
+		}
+		return raw != 0, nil
+
+	case ClaimValueTypeString:
+		return utf16StringAtOffset(data, offset)
+
+	case ClaimValueTypeSID, ClaimValueTypeOctetString:
+		if len(value) < 4 {
+			return nil, fmt.Errorf("truncated octet string at offset %d", offset)
+		}
+		length := binary.LittleEndian.Uint32(value)
+		if int64(length) > int64(len(value)-4) {
+			return nil, fmt.Errorf("octet string length %d at offset %d exceeds data", length, offset)
+		}
+		octets := append([]byte(nil), value[4:4+length]...)
+		if valueType == ClaimValueTypeOctetString {
+			return octets, nil
+		}
+
+		sid := SID{}
+		err := sid.UnmarshalBinary(octets)
+		return sid, err
+	}
+	return nil, fmt.Errorf("unknown claim value type 0x%04x", uint16(valueType))
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, returning the
+// attribute in its self-relative binary form
+func (a ClaimSecurityAttribute) MarshalBinary() ([]byte, error) {
+	headerSize := 16 + 4*len(a.Values)
+	offsets := make([]uint32, len(a.Values))
+
+	payload := bytes.Buffer{}
+	payload.Write(encodeUTF16(a.Name))
+	payload.Write([]byte{0, 0})
+
+	for i, v := range a.Values {
+		offsets[i] = uint32(headerSize + payload.Len())
+		if err := writeClaimValue(&payload, a.ValueType, v); err != nil {
+			return nil, fmt.Errorf("writing claim attribute value %d: %w", i, err)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	buf.Grow(headerSize + payload.Len())
+	binary.Write(&buf, binary.LittleEndian, uint32(headerSize))
+	binary.Write(&buf, binary.LittleEndian, uint16(a.ValueType))
+	binary.Write(&buf, binary.LittleEndian, uint16(0))
+	binary.Write(&buf, binary.LittleEndian, a.Flags)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(a.Values)))
+	binary.Write(&buf, binary.LittleEndian, offsets)
+	buf.Write(payload.Bytes())
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, parsing an
+// attribute from its self-relative binary form
+func (a *ClaimSecurityAttribute) UnmarshalBinary(data []byte) error {
+	attr, err := NewClaimSecurityAttribute(data)
+	if err != nil {
+		return err
+	}
+	*a = attr
+	return nil
+}
+
+func writeClaimValue(buf *bytes.Buffer, valueType ClaimValueType, v interface{}) error {
+	switch val := v.(type) {
+	case int64:
+		if valueType == ClaimValueTypeInt64 {
+			return binary.Write(buf, binary.LittleEndian, val)
+		}
+	case uint64:
+		if valueType == ClaimValueTypeUint64 {
+			return binary.Write(buf, binary.LittleEndian, val)
+		}
+	case bool:
+		if valueType == ClaimValueTypeBoolean {
+			raw := uint64(0)
+			if val {
+				raw = 1
+			}
+			return binary.Write(buf, binary.LittleEndian, raw)
+		}
+	case string:
+		if valueType == ClaimValueTypeString {
+			buf.Write(encodeUTF16(val))
+			buf.Write([]byte{0, 0})
+			return nil
+		}
+	case SID:
+		if valueType == ClaimValueTypeSID {
+			sid, err := val.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			binary.Write(buf, binary.LittleEndian, uint32(len(sid)))
+			buf.Write(sid)
+			return nil
+		}
+	case []byte:
+		if valueType == ClaimValueTypeOctetString {
+			binary.Write(buf, binary.LittleEndian, uint32(len(val)))
+			buf.Write(val)
+			return nil
+		}
+	}
+	return fmt.Errorf("value of type %T does not match claim value type 0x%04x", v, uint16(valueType))
+}
+
+// ClaimValues converts the attribute's values to the form conditional
+// expressions are evaluated against
+func (a ClaimSecurityAttribute) ClaimValues() ClaimValues {
+	claim := make(ClaimValues, 0, len(a.Values))
+	for _, v := range a.Values {
+		switch val := v.(type) {
+		case int64:
+			claim = append(claim, NewIntClaim(val)...)
+		case uint64:
+			claim = append(claim, NewIntClaim(int64(val))...)
+		case bool:
+			claim = append(claim, NewBoolClaim(val)...)
+		case string:
+			claim = append(claim, NewStringClaim(val)...)
+		case SID:
+			claim = append(claim, NewSIDClaim(val)...)
+		case []byte:
+			claim = append(claim, NewOctetClaim(val)...)
+		}
+	}
+	return claim
+}
+
+// ResourceAttributes returns the claims held by the resource attribute
+// ACEs of the descriptor's SACL, keyed by attribute name, for use as
+// AccessCheckOptions.ResourceAttributes
+func (s NtSecurityDescriptor) ResourceAttributes() map[string]ClaimValues {
+	attrs := make(map[string]ClaimValues)
+	if s.SACL == nil {
+		return attrs
+	}
+
+	for _, ace := range s.SACL.Aces {
+		if ace.Header.Type != AceTypeSystemResourceAttribute {
+			continue
+		}
+		if ba, ok := ace.ObjectAce.(BasicAce); ok && ba.Attribute != nil {
+			attrs[ba.Attribute.Name] = ba.Attribute.ClaimValues()
+		}
+	}
+	return attrs
+}
+
+// ToSDDL will convert the attribute into the SDDL form used as the last
+// field of a resource attribute ACE string, such as
+// ("Project",TS,0x0,"Apollo","Gemini")
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/ace-strings
+func (a ClaimSecurityAttribute) ToSDDL() string {
+	fields := []string{
+		`"` + a.Name + `"`,
+		ClaimValueTypeSDDL[a.ValueType],
+		fmt.Sprintf("0x%x", a.Flags),
+	}
+
+	for _, v := range a.Values {
+		switch val := v.(type) {
+		case int64:
+			fields = append(fields, strconv.FormatInt(val, 10))
+		case uint64:
+			fields = append(fields, strconv.FormatUint(val, 10))
+		case bool:
+			if val {
+				fields = append(fields, "1")
+			} else {
+				fields = append(fields, "0")
+			}
+		case string:
+			// SDDL strings have no escape sequences
+			fields = append(fields, `"`+val+`"`)
+		case SID:
+			sid := val.String()
+			if alias := WellKnownSIDsSSDL[sid]; alias != "" {
+				sid = alias
+			}
+			fields = append(fields, fmt.Sprintf("SID(%s)", sid))
+		case []byte:
+			fields = append(fields, hex.EncodeToString(val))
+		}
+	}
+	return "(" + strings.Join(fields, ",") + ")"
+}
+
+// parseResourceAttribute parses the SDDL form of a claim security
+// attribute, which starts at pos
+func (p *sddlParser) parseResourceAttribute(field string, pos int) (*ClaimSecurityAttribute, error) {
+	if !strings.HasPrefix(field, "(") || !strings.HasSuffix(field, ")") {
+		return nil, p.errorAt(pos, "resource attribute must be parenthesised")
+	}
+
+	// Split on commas outside quoted strings
+	var (
+		parts   []string
+		offsets []int
+		quoted  = false
+		from    = 1
+	)
+	for i := 1; i < len(field); i++ {
+		c := field[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ',' || i == len(field)-1):
+			parts = append(parts, strings.TrimSpace(field[from:i]))
+			offsets = append(offsets, pos+from)
+			from = i + 1
+		}
+	}
+	if len(parts) < 3 {
+		return nil, p.errorAt(pos, "resource attribute has %d fields, expected at least 3", len(parts))
+	}
+
+	attr := &ClaimSecurityAttribute{}
+
+	name, err := unquoteSDDLString(parts[0])
+	if err != nil {
+		return nil, p.errorAt(offsets[0], "resource attribute name: %v", err)
+	}
+	attr.Name = name
+
+	found := false
+	for valueType, code := range ClaimValueTypeSDDL {
+		if code == parts[1] {
+			attr.ValueType, found = valueType, true
+		}
+	}
+	if !found {
+		return nil, p.errorAt(offsets[1], "unknown claim value type %q", parts[1])
+	}
+
+	flags, err := strconv.ParseUint(parts[2], 0, 32)
+	if err != nil {
+		return nil, p.errorAt(offsets[2], "invalid claim flags %q", parts[2])
+	}
+	attr.Flags = uint32(flags)
+
+	attr.Values = make([]interface{}, 0, len(parts)-3)
+	for i, part := range parts[3:] {
+		value, err := p.parseClaimValue(attr.ValueType, part, offsets[i+3])
+		if err != nil {
+			return nil, err
+		}
+		attr.Values = append(attr.Values, value)
+	}
+	return attr, nil
+}
+
+func (p *sddlParser) parseClaimValue(valueType ClaimValueType, part string, pos int) (interface{}, error) {
+	var (
+		value interface{}
+		err   error
+	)
+
+	switch valueType {
+	case ClaimValueTypeInt64:
+		value, err = strconv.ParseInt(part, 0, 64)
+	case ClaimValueTypeUint64:
+		value, err = strconv.ParseUint(part, 0, 64)
+	case ClaimValueTypeBoolean:
+		var raw uint64
+		raw, err = strconv.ParseUint(part, 0, 64)
+		value = raw != 0
+	case ClaimValueTypeString:
+		value, err = unquoteSDDLString(part)
+	case ClaimValueTypeOctetString:
+		value, err = hex.DecodeString(strings.TrimPrefix(part, "#"))
+	case ClaimValueTypeSID:
+		sidStr := part
+		if strings.HasPrefix(sidStr, "SID(") && strings.HasSuffix(sidStr, ")") {
+			sidStr = strings.TrimSpace(sidStr[len("SID(") : len(sidStr)-1])
+		}
+		return p.parseSID(sidStr, pos)
+	}
+
+	if err != nil {
+		return nil, p.errorAt(pos, "invalid %s value %q", ClaimValueTypeSDDL[valueType], part)
+	}
+	return value, nil
+}
+
+// unquoteSDDLString strips the double quotes around an SDDL string, which
+// has no escape sequences
+func unquoteSDDLString(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("expected quoted string, got %q", s)
+	}
+	return s[1 : len(s)-1], nil
+}
@@ -0,0 +1,132 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimSecurityAttribute(t *testing.T) {
+	r := require.New(t)
+
+	sid, _ := winacl.NewSIDFromString("S-1-5-32-544")
+	attrs := []winacl.ClaimSecurityAttribute{
+		{Name: "Project", ValueType: winacl.ClaimValueTypeString, Values: []interface{}{"Apollo", "Gemini"}},
+		{Name: "Secrecy", ValueType: winacl.ClaimValueTypeUint64, Flags: winacl.ClaimFlagNonInheritable, Values: []interface{}{uint64(3)}},
+		{Name: "Offset", ValueType: winacl.ClaimValueTypeInt64, Values: []interface{}{int64(-7)}},
+		{Name: "Reviewed", ValueType: winacl.ClaimValueTypeBoolean, Values: []interface{}{true}},
+		{Name: "Owners", ValueType: winacl.ClaimValueTypeSID, Values: []interface{}{sid}},
+		{Name: "Hash", ValueType: winacl.ClaimValueTypeOctetString, Values: []interface{}{[]byte{0xde, 0xad}}},
+	}
+
+	t.Run("Round-trips through binary", func(t *testing.T) {
+		for _, attr := range attrs {
+			data, err := attr.MarshalBinary()
+			r.NoError(err, attr.Name)
+
+			var parsed winacl.ClaimSecurityAttribute
+			r.NoError(parsed.UnmarshalBinary(data), attr.Name)
+			r.Equal(attr, parsed)
+		}
+	})
+
+	t.Run("Rejects values that do not match the value type", func(t *testing.T) {
+		attr := winacl.ClaimSecurityAttribute{Name: "x", ValueType: winacl.ClaimValueTypeString, Values: []interface{}{int64(1)}}
+		_, err := attr.MarshalBinary()
+		r.Error(err)
+	})
+
+	t.Run("Rejects truncated data", func(t *testing.T) {
+		data, err := attrs[0].MarshalBinary()
+		r.NoError(err)
+		_, err = winacl.NewClaimSecurityAttribute(data[:len(data)-4])
+		r.Error(err)
+		_, err = winacl.NewClaimSecurityAttribute(data[:8])
+		r.Error(err)
+	})
+
+	t.Run("Renders SDDL", func(t *testing.T) {
+		r.Equal(`("Project",TS,0x0,"Apollo","Gemini")`, attrs[0].ToSDDL())
+		r.Equal(`("Secrecy",TU,0x1,3)`, attrs[1].ToSDDL())
+		r.Equal(`("Owners",TD,0x0,SID(BA))`, attrs[4].ToSDDL())
+		r.Equal(`("Hash",TX,0x0,dead)`, attrs[5].ToSDDL())
+
+		attr := winacl.ClaimSecurityAttribute{Name: `Dept\Ünit`, ValueType: winacl.ClaimValueTypeString, Values: []interface{}{"x"}}
+		r.Equal(`("Dept\Ünit",TS,0x0,"x")`, attr.ToSDDL())
+	})
+}
+
+func TestResourceAttributeACE(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Round-trips SDDL and binary", func(t *testing.T) {
+		for _, sddl := range []string{
+			`(RA;CI;;;;WD;("Project",TS,0x0,"Apollo","Gemini"))`,
+			`(RA;;;;;WD;("Secrecy",TU,0x1,3))`,
+			`(RA;;;;;WD;("Reviewed",TB,0x0,1))`,
+			`(RA;;;;;WD;("Owners",TD,0x0,SID(BA),SID(S-1-5-21-1-2-3-1001)))`,
+			`(RA;;;;;WD;("Dept\Ünit",TS,0x0,"C:\Share"))`,
+		} {
+			ace, err := winacl.NewACEFromSDDL(sddl)
+			r.NoError(err, sddl)
+			r.Equal(winacl.AceTypeSystemResourceAttribute, ace.GetType())
+			r.Equal(sddl, ace.ToSDDL())
+
+			data, err := ace.MarshalBinary()
+			r.NoError(err)
+
+			var parsed winacl.ACE
+			r.NoError(parsed.UnmarshalBinary(data))
+			r.Equal(sddl, parsed.ToSDDL())
+		}
+	})
+
+	t.Run("Rejects malformed attributes", func(t *testing.T) {
+		for _, sddl := range []string{
+			`(RA;;;;;WD)`,
+			`(RA;;;;;WD;(Project,TS,0x0,"A"))`,
+			`(RA;;;;;WD;("Project",TZ,0x0,"A"))`,
+			`(RA;;;;;WD;("Project",TI,0x0,"A"))`,
+		} {
+			_, err := winacl.NewACEFromSDDL(sddl)
+			r.Error(err, sddl)
+		}
+	})
+
+	t.Run("Feeds resource attributes to AccessCheck", func(t *testing.T) {
+		ntsd := mustParseSDDL(t, `O:BAD:(XA;;0x1;;;WD;(@Resource.Project Contains "Gemini"))S:(RA;;;;;WD;("Project",TS,0x0,"Apollo","Gemini"))`)
+		r.Equal(winacl.NewStringClaim("Apollo", "Gemini"), ntsd.ResourceAttributes()["Project"])
+
+		result := winacl.AccessCheck(ntsd, winacl.NewTokenUser(mustSID(t, "S-1-5-21-1-2-3-1001"), nil), 0x1, nil)
+		r.True(result.Granted, result.Reason)
+	})
+}
+
+func TestSystemACETypesSDDL(t *testing.T) {
+	r := require.New(t)
+
+	for _, sddl := range []string{
+		"S:(ML;;NWNR;;;LW)",
+		"S:(ML;OICI;NW;;;HI)",
+		"S:(SP;;;;;S-1-17-1)",
+		"S:(TL;;0x20;;;S-1-19-512-8192)",
+		`S:(FL;;0x1;;;WD;(WIN://SYSAPPID Contains "AB12"))`,
+	} {
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDL(sddl)
+		r.NoError(err, sddl)
+		r.Equal(sddl, ntsd.ToSDDL())
+
+		data, err := ntsd.MarshalBinary()
+		r.NoError(err, sddl)
+		parsed, err := winacl.NewNtSecurityDescriptor(data)
+		r.NoError(err, sddl)
+		r.Equal(sddl, parsed.ToSDDL())
+	}
+
+	ace, err := winacl.NewACEFromSDDL("(ML;;NWNRNX;;;ME)")
+	r.NoError(err)
+	r.Equal(winacl.AceTypeSystemMandatoryLabel, ace.GetType())
+	r.Equal(uint32(0x7), ace.AccessMask.Raw())
+	r.Equal("SYSTEM_MANDATORY_LABEL", ace.GetTypeString())
+}
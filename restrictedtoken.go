@@ -0,0 +1,159 @@
+package winacl
+
+import (
+	"fmt"
+)
+
+// tokenPass selects which of a token's SIDs an access check pass matches.
+// Restricted and AppContainer tokens are checked once against their
+// normal SIDs and again for each of the other passes that applies
+type tokenPass int
+
+const (
+	tokenPassNormal tokenPass = iota
+	tokenPassRestricted
+	tokenPassAppContainer
+)
+
+// tokenPassInfo describes how a pass is reported
+var tokenPassInfo = map[tokenPass]struct {
+	name   string // Name of the SIDs the pass matches
+	step   string // Prefix of the pass' CheckDetails steps
+	source string // AccessRightDecision source of rights the pass removes
+}{
+	tokenPassNormal:       {"token", "", DecisionSourceACE},
+	tokenPassRestricted:   {"restricting", "Restricted", DecisionSourceRestrictedSIDs},
+	tokenPassAppContainer: {"AppContainer", "AppContainer", DecisionSourceAppContainer},
+}
+
+func (p tokenPass) String() string {
+	return tokenPassInfo[p].name
+}
+
+// passSIDs returns the SIDs matched by the token's current pass
+func (t *TokenUser) passSIDs() []SID {
+	switch t.pass {
+	case tokenPassRestricted:
+		return t.RestrictedSIDs
+	case tokenPassAppContainer:
+		return t.appContainerSIDs()
+	}
+	return nil
+}
+
+// view returns a copy of the token that only matches ACEs, the owner and
+// Member_of conditions against the SIDs of the given pass
+func (t *TokenUser) view(pass tokenPass) *TokenUser {
+	view := *t
+	view.pass = pass
+	return &view
+}
+
+// IsRestricted reports whether the token has restricting SIDs
+func (t *TokenUser) IsRestricted() bool {
+	return len(t.RestrictedSIDs) > 0
+}
+
+// restrictedWriteAccess returns the rights a write-restricted token checks
+// against its restricting SIDs
+func restrictedWriteAccess(options *AccessCheckOptions) uint32 {
+	return MapGenericAccess(AccessMaskGenericWrite, options.GenericMapping) |
+		AccessMaskWriteDACL | AccessMaskWriteOwner | AccessMaskDelete
+}
+
+// restrictedAccessCheck runs the second pass of a restricted token's access
+// check against its restricting SIDs and intersects it with the first. For
+// write-restricted tokens only write access goes through the second pass
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/restricted-tokens
+func restrictedAccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser,
+	desiredAccess uint32, options *AccessCheckOptions, first *AccessCheckResult,
+) *AccessCheckResult {
+	restricted := ^uint32(0)
+	if token.WriteRestricted {
+		restricted = restrictedWriteAccess(resolveAccessCheckOptions(securityDescriptor, options))
+	}
+	return intersectAccessCheck(securityDescriptor, token, tokenPassRestricted, restricted, desiredAccess, options, first)
+}
+
+// intersectAccessCheck checks the rights in restricted again for the SIDs
+// of another pass, and removes those it does not grant from the result of
+// the earlier passes
+func intersectAccessCheck(securityDescriptor *NtSecurityDescriptor, token *TokenUser, pass tokenPass,
+	restricted uint32, desiredAccess uint32, options *AccessCheckOptions, first *AccessCheckResult,
+) *AccessCheckResult {
+	options = resolveAccessCheckOptions(securityDescriptor, options)
+	mappedAccess := MapGenericAccess(desiredAccess, options.GenericMapping)
+	maximumAllowed := desiredAccess&AccessMaskMaximumAllowed != 0
+	info := tokenPassInfo[pass]
+
+	result := *first
+	result.Details = append([]CheckDetails(nil), first.Details...)
+
+	passDesired := mappedAccess & restricted
+	if maximumAllowed {
+		passDesired |= AccessMaskMaximumAllowed
+	}
+	if passDesired == 0 {
+		result.Details = append(result.Details, CheckDetails{
+			Step:        info.step + "SIDs",
+			Description: fmt.Sprintf("No requested access applies to %s SIDs; not checked", pass),
+			Outcome:     true,
+		})
+		return &result
+	}
+
+	second := accessCheck(securityDescriptor, token.view(pass), passDesired, options)
+	result.Details = append(result.Details, CheckDetails{
+		Step:        info.step + "SIDs",
+		Description: fmt.Sprintf("Checking access mask 0x%08X against %s SIDs", passDesired, pass),
+		Outcome:     second.Granted,
+	})
+	for _, detail := range second.Details {
+		detail.Step = info.step + detail.Step
+		result.Details = append(result.Details, detail)
+	}
+
+	if !maximumAllowed {
+		if first.Granted && !second.Granted {
+			result.Granted = false
+			result.Reason = fmt.Sprintf("Access denied by %s SIDs: %s", pass, second.Reason)
+			result.Ace = second.Ace
+			result.Access = first.Access &^ (restricted &^ second.Access)
+		}
+		return &result
+	}
+
+	// Rights the second pass did not grant are removed
+	removed := first.Access & restricted &^ second.Access
+	result.Access = first.Access &^ removed
+
+	secondRights := make(map[uint32]AccessRightDecision, len(second.Rights))
+	for _, decision := range second.Rights {
+		secondRights[decision.Right] = decision
+	}
+
+	result.Rights = make([]AccessRightDecision, len(first.Rights))
+	for i, decision := range first.Rights {
+		if decision.Granted && removed&decision.Right != 0 {
+			decision = AccessRightDecision{
+				Right:    decision.Right,
+				Granted:  false,
+				Source:   info.source,
+				AceIndex: -1,
+			}
+			if denied, ok := secondRights[decision.Right]; ok {
+				decision.Ace, decision.AceIndex = denied.Ace, denied.AceIndex
+			}
+		}
+		result.Rights[i] = decision
+	}
+
+	required := mappedAccess &^ AccessMaskMaximumAllowed
+	result.Granted = result.Access != 0 && result.Access&required == required
+	if removed != 0 {
+		result.Reason = fmt.Sprintf("Maximum allowed access is 0x%08X after %s SIDs removed 0x%08X",
+			result.Access, pass, removed)
+	}
+	return &result
+}
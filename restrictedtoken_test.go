@@ -0,0 +1,87 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestrictedTokens(t *testing.T) {
+	r := require.New(t)
+
+	userSID := mustSID(t, "S-1-5-21-1-2-3-1001")
+	users := mustSID(t, "S-1-5-32-545")
+	restrictedCode := mustSID(t, "S-1-5-12")
+	writeRestricted := mustSID(t, "S-1-5-33")
+
+	restricted := winacl.NewTokenUser(userSID, []winacl.SID{users})
+	restricted.RestrictedSIDs = []winacl.SID{restrictedCode}
+
+	t.Run("Unrestricted tokens are checked once", func(t *testing.T) {
+		token := winacl.NewTokenUser(userSID, []winacl.SID{users})
+		r.False(token.IsRestricted())
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BU)", token, 0x1, nil).Granted)
+	})
+
+	t.Run("Both passes must grant access", func(t *testing.T) {
+		r.True(restricted.IsRestricted())
+
+		// Only the normal SIDs are granted access
+		result := accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BU)", restricted, 0x1, nil)
+		r.False(result.Granted)
+		r.Contains(result.Reason, "restricting SIDs")
+
+		// Only the restricting SIDs are granted access
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;RC)", restricted, 0x1, nil).Granted)
+
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BU)(A;;0x1;;;RC)", restricted, 0x1, nil).Granted)
+	})
+
+	t.Run("Everyone only matches when it is a restricting SID", func(t *testing.T) {
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;WD)", restricted, 0x1, nil).Granted)
+
+		token := winacl.NewTokenUser(userSID, nil)
+		token.RestrictedSIDs = []winacl.SID{mustSID(t, "S-1-1-0")}
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;WD)", token, 0x1, nil).Granted)
+	})
+
+	t.Run("Deny ACEs for restricting SIDs apply", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:SYD:(D;;0x1;;;RC)(A;;0x1;;;BU)(A;;0x1;;;RC)", restricted, 0x1, nil)
+		r.False(result.Granted)
+		r.NotNil(result.Ace)
+		r.Equal(winacl.AceTypeAccessDenied, result.Ace.Header.Type)
+	})
+
+	t.Run("Write-restricted tokens only restrict write access", func(t *testing.T) {
+		token := winacl.NewTokenUser(userSID, []winacl.SID{users})
+		token.RestrictedSIDs = []winacl.SID{writeRestricted}
+		token.WriteRestricted = true
+
+		sddl := "O:SYD:(A;;GA;;;BU)"
+		r.True(accessCheckSDDL(t, sddl, token, winacl.AccessMaskReadControl, nil).Granted)
+		r.False(accessCheckSDDL(t, sddl, token, winacl.AccessMaskWriteDACL, nil).Granted)
+
+		r.True(accessCheckSDDL(t, sddl+"(A;;WD;;;WR)", token, winacl.AccessMaskWriteDACL|winacl.AccessMaskReadControl, nil).Granted)
+	})
+
+	t.Run("Maximum allowed is the intersection of both passes", func(t *testing.T) {
+		result := accessCheckSDDL(t, "O:SYD:(A;;0x7;;;BU)(A;;0x3;;;RC)", restricted, winacl.AccessMaskMaximumAllowed, nil)
+		r.True(result.Granted)
+		r.Equal(uint32(0x3), result.Access)
+
+		for _, decision := range result.Rights {
+			if decision.Right == 0x4 {
+				r.False(decision.Granted)
+				r.Equal(winacl.DecisionSourceRestrictedSIDs, decision.Source)
+			}
+		}
+
+		token := winacl.NewTokenUser(userSID, []winacl.SID{users})
+		token.RestrictedSIDs = []winacl.SID{writeRestricted}
+		token.WriteRestricted = true
+
+		result = accessCheckSDDL(t, "O:SYD:(A;;0x7;;;BU)(A;;WO;;;BU)", token, winacl.AccessMaskMaximumAllowed, nil)
+		r.Equal(uint32(0x7), result.Access)
+	})
+}
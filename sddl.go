@@ -21,12 +21,17 @@ var AceHeaderTypeSDDL = map[AceType]string{
 	AceTypeSystemAlarmObject:           "OL",
 	AceTypeAccessAllowedCallback:       "XA",
 	AceTypeAccessDeniedCallback:        "XD",
-	AceTypeAccessAllowedCallbackObject: "",
+	AceTypeAccessAllowedCallbackObject: "ZA",
 	AceTypeAccessDeniedCallbackObject:  "",
 	AceTypeSystemAuditCallback:         "XU",
 	AceTypeSystemAlarmCallback:         "",
 	AceTypeSystemAuditCallbackObject:   "",
 	AceTypeSystemAlarmCallbackObject:   "",
+	AceTypeSystemMandatoryLabel:        "ML",
+	AceTypeSystemResourceAttribute:     "RA",
+	AceTypeSystemScopedPolicyID:        "SP",
+	AceTypeSystemProcessTrustLabel:     "TL",
+	AceTypeSystemAccessFilter:          "FL",
 }
 
 // AceHeaderFlagsSDDL is a map of AceHeaderFlags matched to
@@ -65,13 +70,47 @@ var AceRightsSDDL = map[uint32]string{
 	ADSRightDSControlAccess: "CR",
 }
 
+// MandatoryLabelRightsSDDL maps the access mask bits of mandatory label
+// ACEs, which reuse the low bits for their policy, to their SDDL
+// abbreviations
+var MandatoryLabelRightsSDDL = map[uint32]string{
+	uint32(PolicyNoWriteUp):   "NW",
+	uint32(PolicyNoReadUp):    "NR",
+	uint32(PolicyNoExecuteUp): "NX",
+}
+
+// AceCompositeRightsSDDL maps the SDDL abbreviations that stand for a
+// whole access mask, rather than a single bit, to that mask
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/ace-strings
+var AceCompositeRightsSDDL = map[string]uint32{
+	"FA": 0x001F01FF, // FILE_ALL_ACCESS
+	"FR": 0x00120089, // FILE_GENERIC_READ
+	"FW": 0x00120116, // FILE_GENERIC_WRITE
+	"FX": 0x001200A0, // FILE_GENERIC_EXECUTE
+	"KA": 0x000F003F, // KEY_ALL_ACCESS
+	"KR": 0x00020019, // KEY_READ
+	"KW": 0x00020006, // KEY_WRITE
+	"KX": 0x00020019, // KEY_EXECUTE
+}
+
+// compositeRightsSDDLOrder is the order the encoder tries the composite
+// abbreviations in. KR comes before KX, which has the same mask
+var compositeRightsSDDLOrder = []string{"FA", "FR", "FW", "FX", "KA", "KR", "KW", "KX"}
+
 // https://docs.microsoft.com/en-us/windows/win32/secauthz/security-descriptor-control
 const (
 	ControlDACLAutoInheritReq = 0x100
+	ControlSACLAutoInheritReq = 0x200
 	ControlDACLAutoInherit    = 0x400
+	ControlSACLAutoInherit    = 0x800
 	ControlDACLProtected      = 0x1000
+	ControlSACLProtected      = 0x2000
 )
 
+// SDDLNoAccessControl is the ACL flag SDDL uses to denote a NULL ACL
+const SDDLNoAccessControl = "NO_ACCESS_CONTROL"
+
 // NtSecurityDescriptorHeaderSDDL holds the Security Descriptor
 // Control property mapped to its corresponding SDDL abbreviations
 // NOTE: ntsd.ToSDDL() relies on these being the only 3 entries
@@ -82,8 +121,21 @@ var NtSecurityDescriptorHeaderSDDL = map[int]string{
 	ControlDACLProtected:      "P",
 }
 
+// NtSecurityDescriptorHeaderSACLSDDL holds the SACL-related Security
+// Descriptor Control properties mapped to the flags SDDL writes after "S:".
+// SDDL reuses the DACL abbreviations, so SE_SACL_PROTECTED (SP),
+// SE_SACL_AUTO_INHERIT_REQ (SR) and SE_SACL_AUTO_INHERITED (SA) are
+// written as P, AR and AI respectively.
+var NtSecurityDescriptorHeaderSACLSDDL = map[int]string{
+	ControlSACLAutoInheritReq: "AR",
+	ControlSACLAutoInherit:    "AI",
+	ControlSACLProtected:      "P",
+}
+
 // WellKnownSIDsSSDL is a map of common Windows SIDs mapped to
-// their corresponding abbreviations
+// their corresponding abbreviations. The S-1-5-21-0-0-0-RID entries are
+// placeholders for the domain-relative abbreviations in
+// DomainRelativeSIDsSDDL, used when no SDDLDomains are given
 var WellKnownSIDsSSDL = map[string]string{
 	"S-1-1-0":            "WD",
 	"S-1-3-0":            "CO",
@@ -138,9 +190,14 @@ var WellKnownSIDsSSDL = map[string]string{
 	"S-1-5-21-0-0-0-519": "EA",
 	"S-1-5-21-0-0-0-520": "PA",
 	"S-1-5-21-0-0-0-522": "CN",
+	"S-1-5-21-0-0-0-525": "AP",
+	"S-1-5-21-0-0-0-526": "KA",
+	"S-1-5-21-0-0-0-527": "EK",
 	"S-1-5-21-0-0-0-553": "RS",
 	"S-1-5-84-0-0-0-0-0": "UD",
 	"S-1-15-2-1":         "AC",
+	"S-1-18-1":           "AS",
+	"S-1-18-2":           "SS",
 	"S-1-16-4096":        "LW",
 	"S-1-16-8192":        "ME",
 	"S-1-16-8448":        "MP",
@@ -148,14 +205,113 @@ var WellKnownSIDsSSDL = map[string]string{
 	"S-1-16-16384":       "SI",
 }
 
+// SDDLOptions configures how ToSDDLWith writes descriptors and ACEs
+type SDDLOptions struct {
+	ObjectType SecurableObjectType // Selects the composite rights abbreviations, as in ToSDDLFor
+
+	// Resolver, when set, writes the SIDs it knows that have no SDDL
+	// abbreviation as their names, for display. SDDL written with a
+	// resolver cannot be parsed back
+	Resolver SIDResolver
+
+	// Domains writes the well-known principals of these domains as their
+	// abbreviations, such as DA for the domain's Domain Admins
+	Domains SDDLDomains
+}
+
+// sid returns how an owner or group SID is written: its abbreviation for
+// one of the Domains, the Resolver's name for it or its string form
+func (o SDDLOptions) sid(sid SID) string {
+	if alias, ok := o.Domains.alias(sid); ok {
+		return alias
+	}
+	if o.Resolver != nil {
+		if name, ok := o.Resolver.ResolveSID(sid); ok {
+			return name
+		}
+	}
+	return sid.String()
+}
+
+// aceSID returns how an ACE's SID is written: its SDDL abbreviation, if it
+// has one, or as sid does
+func (o SDDLOptions) aceSID(sid SID) string {
+	if wellKnown := WellKnownSIDsSSDL[sid.String()]; wellKnown != "" {
+		return wellKnown
+	}
+	return o.sid(sid)
+}
+
+// compositeRightsObjectTypes lists the object types each composite
+// abbreviation in AceCompositeRightsSDDL applies to
+var compositeRightsObjectTypes = map[string][]SecurableObjectType{
+	"FA": {SecurableFile, SecurableDirectory, SecurableNamedPipe},
+	"FR": {SecurableFile, SecurableDirectory, SecurableNamedPipe},
+	"FW": {SecurableFile, SecurableDirectory, SecurableNamedPipe},
+	"FX": {SecurableFile, SecurableDirectory, SecurableNamedPipe},
+	"KA": {SecurableRegistryKey},
+	"KR": {SecurableRegistryKey},
+	"KW": {SecurableRegistryKey},
+	"KX": {SecurableRegistryKey},
+}
+
+// compositeRightsApply reports whether a composite abbreviation may be
+// used for an object type. Any may be used for SecurableGeneric
+func compositeRightsApply(code string, objectType SecurableObjectType) bool {
+	if objectType == SecurableGeneric {
+		return true
+	}
+	for _, t := range compositeRightsObjectTypes[code] {
+		if t == objectType {
+			return true
+		}
+	}
+	return false
+}
+
 // RightsString returns the representation of an ACE's permissions,
 // in SDDL format
 func (s ACE) RightsString() string {
+	return s.RightsStringFor(SecurableGeneric)
+}
+
+// RightsStringFor returns the representation of an ACE's permissions, in
+// SDDL format, for an object type. Composite abbreviations such as FA or
+// KR are only used for the object types they belong to. Masks with bits
+// that have no abbreviation are written in hexadecimal
+func (s ACE) RightsStringFor(objectType SecurableObjectType) string {
+	switch s.Header.Type {
+	case AceTypeSystemMandatoryLabel:
+		sb := strings.Builder{}
+		flags, _ := bamflags.ParseInt(int64(s.AccessMask.Value))
+		for _, flag := range flags {
+			sb.WriteString(MandatoryLabelRightsSDDL[uint32(flag)])
+		}
+		return sb.String()
+
+	case AceTypeSystemResourceAttribute, AceTypeSystemScopedPolicyID,
+		AceTypeSystemProcessTrustLabel, AceTypeSystemAccessFilter:
+		// These masks have no SDDL abbreviations
+		if s.AccessMask.Value == 0 {
+			return ""
+		}
+		return fmt.Sprintf("0x%x", s.AccessMask.Value)
+	}
+
+	for _, code := range compositeRightsSDDLOrder {
+		if s.AccessMask.Value == AceCompositeRightsSDDL[code] && compositeRightsApply(code, objectType) {
+			return code
+		}
+	}
+
 	sb := strings.Builder{}
 	flags, _ := bamflags.ParseInt(int64(s.AccessMask.Value))
 
 	for _, flag := range flags {
-		symbol := AceRightsSDDL[uint32(flag)]
+		symbol, ok := AceRightsSDDL[uint32(flag)]
+		if !ok {
+			return fmt.Sprintf("0x%x", s.AccessMask.Value)
+		}
 		sb.WriteString(symbol)
 	}
 	return sb.String()
@@ -178,6 +334,16 @@ func (s ACEHeader) SDDLFlags() string {
 //
 // https://docs.microsoft.com/en-us/windows/win32/secauthz/ace-strings
 func (s ACE) ToSDDL() string {
+	return s.ToSDDLFor(SecurableGeneric)
+}
+
+// ToSDDLFor is ToSDDL with the rights encoded for an object type
+func (s ACE) ToSDDLFor(objectType SecurableObjectType) string {
+	return s.ToSDDLWith(SDDLOptions{ObjectType: objectType})
+}
+
+// ToSDDLWith is ToSDDL with the rights and SID written as options say
+func (s ACE) ToSDDLWith(options SDDLOptions) string {
 	format := "(%s;%s;%s;%s;%s;%s)"
 
 	var (
@@ -192,32 +358,46 @@ func (s ACE) ToSDDL() string {
 		inheritedObjGUID = aa.InheritedObjectType.String()
 	}
 
-	accountSID := s.ObjectAce.GetPrincipal().String()
-	if wellKnown := WellKnownSIDsSSDL[accountSID]; wellKnown != "" {
-		accountSID = wellKnown
-	}
+	accountSID := options.aceSID(s.ObjectAce.GetPrincipal())
 
 	sddlString := fmt.Sprintf(format,
-		AceHeaderTypeSDDL[s.Header.Type], // AceType
-		s.Header.SDDLFlags(),             // AceFlags
-		s.RightsString(),                 // Rights
-		objGUID,                          // ObjectGUID
-		inheritedObjGUID,                 // Inherited Object GUID
-		accountSID,                       // Account SID
-		// "(attrs)",                        // Resource Attrs
+		AceHeaderTypeSDDL[s.Header.Type],      // AceType
+		s.Header.SDDLFlags(),                  // AceFlags
+		s.RightsStringFor(options.ObjectType), // Rights
+		objGUID,                               // ObjectGUID
+		inheritedObjGUID,                      // Inherited Object GUID
+		accountSID,                            // Account SID
 	)
+
+	// Callback and access filter ACEs append their conditional expression
+	// as a 7th field, resource attribute ACEs their attribute
+	if cond := s.GetCondition(); cond != nil {
+		sddlString = fmt.Sprintf("%s;%s)", strings.TrimSuffix(sddlString, ")"), cond.ToSDDL())
+	}
+	if ba, ok := s.ObjectAce.(BasicAce); ok && ba.Attribute != nil {
+		sddlString = fmt.Sprintf("%s;%s)", strings.TrimSuffix(sddlString, ")"), ba.Attribute.ToSDDL())
+	}
 	return sddlString
 }
 
-// ToSDDL will convert the individual components of an ACD
-// into an SDDL compliant string
+// ToSDDL will convert the individual components of an ACL
+// into an SDDL compliant DACL string
 func (a ACL) ToSDDL(flags string) string {
+	return a.toSDDL("D:", flags, SDDLOptions{})
+}
+
+// SACLToSDDL will convert the individual components of an ACL
+// into an SDDL compliant SACL string
+func (a ACL) SACLToSDDL(flags string) string {
+	return a.toSDDL("S:", flags, SDDLOptions{})
+}
+
+func (a ACL) toSDDL(prefix, flags string, options SDDLOptions) string {
 	sb := strings.Builder{}
-	// TODO Change when SACLs are implemented
-	sb.WriteString("D:")
+	sb.WriteString(prefix)
 	sb.WriteString(flags)
 	for _, ace := range a.Aces {
-		sb.WriteString(ace.ToSDDL())
+		sb.WriteString(ace.ToSDDLWith(options))
 	}
 	return sb.String()
 }
@@ -239,14 +419,56 @@ func (ndh NtSecurityDescriptorHeader) ToSDDL() string {
 	return sb.String()
 }
 
+// SACLToSDDL will convert the SACL-related Control value of an
+// NtSecurityDescriptorHeader into an SDDL compliant string
+func (ndh NtSecurityDescriptorHeader) SACLToSDDL() string {
+	sb := strings.Builder{}
+	flags, _ := bamflags.ParseInt(int64(ndh.Control))
+
+	for _, flag := range flags {
+		symbol := NtSecurityDescriptorHeaderSACLSDDL[flag]
+		sb.WriteString(symbol)
+	}
+
+	return sb.String()
+}
+
 // ToSDDL will convert the individual components of a NtSecurityDescriptor
 // into an SDDL compliant string
 //
 // https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/2918391b-75b9-4eeb-83f0-7fdc04a5c6c9
 func (s NtSecurityDescriptor) ToSDDL() string {
+	return s.ToSDDLFor(SecurableGeneric)
+}
+
+// ToSDDLFor is ToSDDL with the rights of each ACE encoded for the type of
+// the object the descriptor protects
+func (s NtSecurityDescriptor) ToSDDLFor(objectType SecurableObjectType) string {
+	return s.ToSDDLWith(SDDLOptions{ObjectType: objectType})
+}
+
+// ToSDDLWith is ToSDDL with the rights and SIDs written as options say
+func (s NtSecurityDescriptor) ToSDDLWith(options SDDLOptions) string {
 	sb := strings.Builder{}
-	fmt.Fprintf(&sb, "O:%s", s.Owner.String())
-	fmt.Fprintf(&sb, "G:%s", s.Group.String())
-	sb.WriteString(s.DACL.ToSDDL(s.Header.ToSDDL()))
+	if s.Owner != nil {
+		fmt.Fprintf(&sb, "O:%s", options.sid(*s.Owner))
+	}
+	if s.Group != nil {
+		fmt.Fprintf(&sb, "G:%s", options.sid(*s.Group))
+	}
+
+	switch s.DACLState() {
+	case ACLStateNull:
+		fmt.Fprintf(&sb, "D:%s%s", s.Header.ToSDDL(), SDDLNoAccessControl)
+	case ACLStateEmpty, ACLStatePopulated:
+		sb.WriteString(s.DACL.toSDDL("D:", s.Header.ToSDDL(), options))
+	}
+
+	switch s.SACLState() {
+	case ACLStateNull:
+		fmt.Fprintf(&sb, "S:%s%s", s.Header.SACLToSDDL(), SDDLNoAccessControl)
+	case ACLStateEmpty, ACLStatePopulated:
+		sb.WriteString(s.SACL.toSDDL("S:", s.Header.SACLToSDDL(), options))
+	}
 	return sb.String()
 }
@@ -192,9 +192,9 @@ func TestNtSecurityDescriptorToSDDL(t *testing.T) {
 	// Create security descriptor
 	sd := winacl.NtSecurityDescriptor{
 		Header: header,
-		Owner: ownerSid,
-		Group: groupSid,
-		DACL: acl,
+		Owner: &ownerSid,
+		Group: &groupSid,
+		DACL: &acl,
 	}
 
 	// Test ToSDDL method
@@ -203,3 +203,54 @@ func TestNtSecurityDescriptorToSDDL(t *testing.T) {
 	r.Contains(sddl, "G:")  // Group
 	r.Contains(sddl, "D:")  // DACL
 }
+
+func TestNtSecurityDescriptorToSDDLACLStates(t *testing.T) {
+	r := require.New(t)
+
+	sid := winacl.SID{
+		Revision:       1,
+		NumAuthorities: 1,
+		Authority:      []byte{0, 0, 0, 0, 0, 1},
+		SubAuthorities: []uint32{0},
+	}
+	audit := winacl.ACE{
+		Header: winacl.ACEHeader{
+			Type:  winacl.AceTypeSystemAudit,
+			Flags: winacl.ACEHeaderFlagsSuccessfulAccessAceFlag | winacl.ACEHeaderFlagsFailedAccessAceFlag,
+		},
+		AccessMask: winacl.ACEAccessMask{Value: winacl.AccessMaskGenericAll},
+		ObjectAce:  winacl.BasicAce{SecurityIdentifier: sid},
+	}
+
+	t.Run("Omits absent ACLs", func(t *testing.T) {
+		sd := winacl.NtSecurityDescriptor{}
+		r.Equal("", sd.ToSDDL())
+	})
+
+	t.Run("Renders NULL ACLs as NO_ACCESS_CONTROL", func(t *testing.T) {
+		sd := winacl.NtSecurityDescriptor{
+			Header: winacl.NtSecurityDescriptorHeader{
+				Control: winacl.DACLPresent | winacl.SACLPresent,
+			},
+		}
+		r.Equal("D:NO_ACCESS_CONTROLS:NO_ACCESS_CONTROL", sd.ToSDDL())
+	})
+
+	t.Run("Renders empty ACLs", func(t *testing.T) {
+		sd := winacl.NtSecurityDescriptor{
+			DACL: &winacl.ACL{},
+			SACL: &winacl.ACL{},
+		}
+		r.Equal("D:S:", sd.ToSDDL())
+	})
+
+	t.Run("Renders the SACL with its control flags", func(t *testing.T) {
+		sd := winacl.NtSecurityDescriptor{
+			Header: winacl.NtSecurityDescriptorHeader{
+				Control: winacl.SACLPresent | winacl.SACLProtected | winacl.SACLAutoInherited,
+			},
+			SACL: &winacl.ACL{Aces: []winacl.ACE{audit}},
+		}
+		r.Equal("S:AIP(AU;SAFA;GA;;;WD)", sd.ToSDDL())
+	})
+}
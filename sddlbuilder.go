@@ -280,11 +280,11 @@ func formatSDFlags(flags uint16) string {
 }
 
 // Parse parses an SDDL string into a security descriptor
+// See also: NewNtSecurityDescriptorFromSDDL()
 func (sb *SDDLBuilder) Parse(sddl string) (*NtSecurityDescriptor, error) {
-	// This is a placeholder - implementing a full SDDL parser would be quite involved
-	// and would duplicate functionality that might already exist elsewhere in the library
-	// We'd need to integrate with existing parsing logic in the library
-	
-	// Placeholder error for now
-	return nil, fmt.Errorf("SDDL parsing not implemented in the builder yet")
+	ntsd, err := NewNtSecurityDescriptorFromSDDL(sddl)
+	if err != nil {
+		return nil, err
+	}
+	return &ntsd, nil
 }
@@ -0,0 +1,107 @@
+package winacl
+
+// SDDLDomainScope says which domain a domain-relative SDDL abbreviation
+// is relative to
+type SDDLDomainScope int
+
+const (
+	SDDLScopeDomain     SDDLDomainScope = iota // The object's domain
+	SDDLScopeRootDomain                        // The forest root domain
+	SDDLScopeMachine                           // The local machine's account domain
+)
+
+// DomainSIDAlias is a domain-relative SDDL abbreviation: the SID of a
+// well-known account or group of a domain
+type DomainSIDAlias struct {
+	RID   uint32
+	Scope SDDLDomainScope
+}
+
+// DomainRelativeSIDsSDDL maps the SDDL abbreviations of domain-relative
+// SIDs to their RIDs and the domain they belong to
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/f4296d69-1c0f-491f-9587-a960b292d070
+var DomainRelativeSIDsSDDL = map[string]DomainSIDAlias{
+	"RO": {498, SDDLScopeRootDomain}, // Enterprise Read-only Domain Controllers
+	"LA": {500, SDDLScopeMachine},    // Administrator
+	"LG": {501, SDDLScopeMachine},    // Guest
+	"DA": {512, SDDLScopeDomain},     // Domain Admins
+	"DU": {513, SDDLScopeDomain},     // Domain Users
+	"DG": {514, SDDLScopeDomain},     // Domain Guests
+	"DC": {515, SDDLScopeDomain},     // Domain Computers
+	"DD": {516, SDDLScopeDomain},     // Domain Controllers
+	"CA": {517, SDDLScopeDomain},     // Cert Publishers
+	"SA": {518, SDDLScopeRootDomain}, // Schema Admins
+	"EA": {519, SDDLScopeRootDomain}, // Enterprise Admins
+	"PA": {520, SDDLScopeDomain},     // Group Policy Creator Owners
+	"CN": {522, SDDLScopeDomain},     // Cloneable Domain Controllers
+	"AP": {525, SDDLScopeDomain},     // Protected Users
+	"KA": {526, SDDLScopeDomain},     // Key Admins
+	"EK": {527, SDDLScopeRootDomain}, // Enterprise Key Admins
+	"RS": {553, SDDLScopeDomain},     // RAS and IAS Servers
+}
+
+// ridToDomainAlias is the reverse of DomainRelativeSIDsSDDL, used when
+// writing SDDL
+var ridToDomainAlias map[uint32]string
+
+func init() {
+	ridToDomainAlias = make(map[uint32]string, len(DomainRelativeSIDsSDDL))
+	for code, alias := range DomainRelativeSIDsSDDL {
+		ridToDomainAlias[alias.RID] = code
+	}
+}
+
+// SDDLDomains holds the domain SIDs that domain-relative abbreviations such
+// as DA, EA and LA stand for. Abbreviations whose domain is not set are
+// read as the placeholder SIDs in WellKnownSIDsSSDL, S-1-5-21-0-0-0-RID
+type SDDLDomains struct {
+	Domain     *SID // DA, DU, DC, CA, AP, KA, CN, PA, RS and the like
+	RootDomain *SID // EA, SA, RO and EK. Domain is used when it is nil
+	Machine    *SID // LA and LG
+}
+
+// scopeSID returns the SID of the domain an abbreviation of the given scope
+// is relative to, or nil if it is not set
+func (d SDDLDomains) scopeSID(scope SDDLDomainScope) *SID {
+	switch scope {
+	case SDDLScopeRootDomain:
+		if d.RootDomain != nil {
+			return d.RootDomain
+		}
+		return d.Domain
+	case SDDLScopeMachine:
+		return d.Machine
+	}
+	return d.Domain
+}
+
+// expand returns the SID a domain-relative abbreviation stands for
+func (d SDDLDomains) expand(code string) (SID, bool) {
+	alias, ok := DomainRelativeSIDsSDDL[code]
+	if !ok {
+		return SID{}, false
+	}
+	domain := d.scopeSID(alias.Scope)
+	if domain == nil {
+		return SID{}, false
+	}
+	return domain.WithRID(alias.RID), true
+}
+
+// alias returns the domain-relative abbreviation for a SID, if it is a
+// well-known principal of one of the domains
+func (d SDDLDomains) alias(sid SID) (string, bool) {
+	if !sid.IsDomainRelative() {
+		return "", false
+	}
+	code, ok := ridToDomainAlias[sid.RID()]
+	if !ok {
+		return "", false
+	}
+	domain := d.scopeSID(DomainRelativeSIDsSDDL[code].Scope)
+	if domain == nil || !domain.Equal(sid.Domain()) {
+		return "", false
+	}
+	return code, true
+}
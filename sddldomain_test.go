@@ -0,0 +1,97 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSDDLDomainAliases(t *testing.T) {
+	r := require.New(t)
+
+	domain := mustSID(t, "S-1-5-21-1-2-3")
+	root := mustSID(t, "S-1-5-21-7-8-9")
+	machine := mustSID(t, "S-1-5-21-4-5-6")
+	domains := winacl.SDDLDomains{Domain: &domain, RootDomain: &root, Machine: &machine}
+
+	t.Run("Expands aliases to the principals of their domains", func(t *testing.T) {
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDLWith(
+			"O:DAG:DUD:(A;;GA;;;EA)(A;;GA;;;SA)(A;;GR;;;DC)(A;;GR;;;AP)(A;;GA;;;LA)(A;;GR;;;SY)", domains)
+		r.NoError(err)
+		r.Equal("S-1-5-21-1-2-3-512", ntsd.Owner.String())
+		r.Equal("S-1-5-21-1-2-3-513", ntsd.Group.String())
+
+		expected := []string{
+			"S-1-5-21-7-8-9-519",
+			"S-1-5-21-7-8-9-518",
+			"S-1-5-21-1-2-3-515",
+			"S-1-5-21-1-2-3-525",
+			"S-1-5-21-4-5-6-500",
+			"S-1-5-18",
+		}
+		r.Len(ntsd.DACL.Aces, len(expected))
+		for i, sid := range expected {
+			r.Equal(sid, ntsd.DACL.Aces[i].ObjectAce.GetPrincipal().String())
+		}
+	})
+
+	t.Run("Writes the principals of the domains as aliases", func(t *testing.T) {
+		for _, code := range []string{"RO", "DA", "DU", "DC", "CA", "RS", "AP", "KA", "EK", "CN", "PA", "EA", "SA", "LA", "LG"} {
+			ace, err := winacl.NewACEFromSDDLWith("(A;;GA;;;"+code+")", domains)
+			r.NoError(err, code)
+			r.Equal("(A;;GA;;;"+code+")", ace.ToSDDLWith(winacl.SDDLOptions{Domains: domains}), code)
+			r.NotContains(ace.ToSDDL(), ";"+code+")", code)
+		}
+	})
+
+	t.Run("Round-trips owner and group aliases", func(t *testing.T) {
+		for _, sddl := range []string{"O:DAG:DUD:(A;;GA;;;EA)", "O:LAG:DUD:(A;;GA;;;DA)"} {
+			ntsd, err := winacl.NewNtSecurityDescriptorFromSDDLWith(sddl, domains)
+			r.NoError(err, sddl)
+			r.Equal(sddl, ntsd.ToSDDLWith(winacl.SDDLOptions{Domains: domains}))
+		}
+
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDLWith("O:DAG:LA", domains)
+		r.NoError(err)
+		r.Equal("O:S-1-5-21-1-2-3-512G:S-1-5-21-4-5-6-500", ntsd.ToSDDL())
+	})
+
+	t.Run("Leaves principals of other domains as SIDs", func(t *testing.T) {
+		ace, err := winacl.NewACEFromSDDL("(A;;GA;;;S-1-5-21-4-5-6-512)")
+		r.NoError(err)
+		r.Equal("(A;;GA;;;S-1-5-21-4-5-6-512)", ace.ToSDDLWith(winacl.SDDLOptions{Domains: domains}))
+
+		ace, err = winacl.NewACEFromSDDL("(A;;GA;;;S-1-5-21-1-2-3-519)")
+		r.NoError(err)
+		r.Equal("(A;;GA;;;S-1-5-21-1-2-3-519)", ace.ToSDDLWith(winacl.SDDLOptions{Domains: domains}))
+	})
+
+	t.Run("Uses the domain for root domain aliases when no root domain is set", func(t *testing.T) {
+		ace, err := winacl.NewACEFromSDDLWith("(A;;GA;;;EA)", winacl.SDDLDomains{Domain: &domain})
+		r.NoError(err)
+		r.Equal("S-1-5-21-1-2-3-519", ace.ObjectAce.GetPrincipal().String())
+	})
+
+	t.Run("Falls back on placeholder SIDs without domains", func(t *testing.T) {
+		ace, err := winacl.NewACEFromSDDL("(A;;GA;;;DA)")
+		r.NoError(err)
+		r.Equal("S-1-5-21-0-0-0-512", ace.ObjectAce.GetPrincipal().String())
+		r.Equal("(A;;GA;;;DA)", ace.ToSDDL())
+
+		ace, err = winacl.NewACEFromSDDLWith("(A;;GA;;;LA)", winacl.SDDLDomains{Domain: &domain})
+		r.NoError(err)
+		r.Equal("S-1-5-21-0-0-0-500", ace.ObjectAce.GetPrincipal().String())
+	})
+
+	t.Run("Parses the fixed aliases", func(t *testing.T) {
+		ace, err := winacl.NewACEFromSDDL("(A;;GA;;;AS)")
+		r.NoError(err)
+		r.Equal("S-1-18-1", ace.ObjectAce.GetPrincipal().String())
+
+		ace, err = winacl.NewACEFromSDDL("(A;;GA;;;SS)")
+		r.NoError(err)
+		r.Equal("S-1-18-2", ace.ObjectAce.GetPrincipal().String())
+		r.Equal("(A;;GA;;;SS)", ace.ToSDDL())
+	})
+}
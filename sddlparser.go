@@ -0,0 +1,485 @@
+package winacl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reverse maps of the SDDL abbreviation tables, used when parsing
+var (
+	sddlToAceType  map[string]AceType
+	sddlToAceFlag  map[string]ACEHeaderFlags
+	sddlToAceRight map[string]uint32
+	sddlToSID      map[string]string
+	sddlToDACLFlag map[string]uint16
+	sddlToSACLFlag map[string]uint16
+)
+
+func init() {
+	sddlToAceType = make(map[string]AceType, len(AceHeaderTypeSDDL))
+	for aceType, code := range AceHeaderTypeSDDL {
+		if code != "" {
+			sddlToAceType[code] = aceType
+		}
+	}
+
+	sddlToAceFlag = make(map[string]ACEHeaderFlags, len(AceHeaderFlagsSDDL))
+	for flag, code := range AceHeaderFlagsSDDL {
+		sddlToAceFlag[code] = flag
+	}
+
+	sddlToAceRight = make(map[string]uint32, len(AceRightsSDDL))
+	for right, code := range AceRightsSDDL {
+		sddlToAceRight[code] = right
+	}
+
+	sddlToSID = make(map[string]string, len(WellKnownSIDsSSDL))
+	for sid, code := range WellKnownSIDsSSDL {
+		sddlToSID[code] = sid
+	}
+
+	sddlToDACLFlag = make(map[string]uint16, len(NtSecurityDescriptorHeaderSDDL))
+	for control, code := range NtSecurityDescriptorHeaderSDDL {
+		sddlToDACLFlag[code] = uint16(control)
+	}
+
+	sddlToSACLFlag = make(map[string]uint16, len(NtSecurityDescriptorHeaderSACLSDDL))
+	for control, code := range NtSecurityDescriptorHeaderSACLSDDL {
+		sddlToSACLFlag[code] = uint16(control)
+	}
+}
+
+// SDDLParseError represents errors that occur when parsing an invalid
+// SDDL string. Offset is the 0-based byte position of the problem
+type SDDLParseError struct {
+	Offset int
+	msg    string
+}
+
+// Error implements the error interface for SDDLParseError
+func (e SDDLParseError) Error() string {
+	return fmt.Sprintf("SDDL: %s at position %d", e.msg, e.Offset)
+}
+
+// NewNtSecurityDescriptorFromSDDL is a constructor that will parse out an
+// NtSecurityDescriptor from an SDDL string
+//
+// The O:, G:, D: and S: sections may appear in any order, each at most once.
+// Owner and group may be raw SIDs or the two-letter aliases in
+// WellKnownSIDsSSDL.
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/security-descriptor-string-format
+func NewNtSecurityDescriptorFromSDDL(sddl string) (NtSecurityDescriptor, error) {
+	return NewNtSecurityDescriptorFromSDDLWith(sddl, SDDLDomains{})
+}
+
+// NewNtSecurityDescriptorFromSDDLWith is NewNtSecurityDescriptorFromSDDL,
+// reading domain-relative aliases such as DA and EA as principals of the
+// given domains
+func NewNtSecurityDescriptorFromSDDLWith(sddl string, domains SDDLDomains) (NtSecurityDescriptor, error) {
+	p := sddlParser{input: sddl, domains: domains}
+	return p.parseSecurityDescriptor()
+}
+
+// NewACEFromSDDL is a constructor that will parse out an ACE from a
+// single SDDL ACE string, such as "(A;CI;RPWP;;;AU)"
+//
+// https://docs.microsoft.com/en-us/windows/win32/secauthz/ace-strings
+func NewACEFromSDDL(aceStr string) (ACE, error) {
+	return NewACEFromSDDLWith(aceStr, SDDLDomains{})
+}
+
+// NewACEFromSDDLWith is NewACEFromSDDL, reading domain-relative aliases
+// such as DA and EA as principals of the given domains
+func NewACEFromSDDLWith(aceStr string, domains SDDLDomains) (ACE, error) {
+	p := sddlParser{input: aceStr, domains: domains}
+	ace, err := p.parseACE()
+	if err != nil {
+		return ace, err
+	}
+	if !p.done() {
+		return ace, p.errorf("unexpected trailing characters")
+	}
+	return ace, nil
+}
+
+// sddlParser holds the state of a single SDDL parse
+type sddlParser struct {
+	input   string
+	pos     int
+	domains SDDLDomains
+}
+
+func (p *sddlParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *sddlParser) errorf(format string, args ...interface{}) SDDLParseError {
+	return p.errorAt(p.pos, format, args...)
+}
+
+func (p *sddlParser) errorAt(pos int, format string, args ...interface{}) SDDLParseError {
+	return SDDLParseError{Offset: pos, msg: fmt.Sprintf(format, args...)}
+}
+
+// atSection reports whether a section tag such as "D:" starts at pos
+func (p *sddlParser) atSection(pos int) bool {
+	return pos+1 < len(p.input) &&
+		strings.IndexByte("OGDS", p.input[pos]) >= 0 &&
+		p.input[pos+1] == ':'
+}
+
+func (p *sddlParser) parseSecurityDescriptor() (NtSecurityDescriptor, error) {
+	ntsd := NtSecurityDescriptor{}
+	ntsd.Header.Revision = 1
+	seen := make(map[byte]bool, 4)
+
+	for !p.done() {
+		if !p.atSection(p.pos) {
+			return ntsd, p.errorf("expected O:, G:, D: or S: section")
+		}
+
+		tag := p.input[p.pos]
+		if seen[tag] {
+			return ntsd, p.errorf("duplicate %c: section", tag)
+		}
+		seen[tag] = true
+		p.pos += 2
+
+		switch tag {
+		case 'O', 'G':
+			sid, err := p.parseSectionSID()
+			if err != nil {
+				return ntsd, err
+			}
+			if tag == 'O' {
+				ntsd.Owner = &sid
+			} else {
+				ntsd.Group = &sid
+			}
+
+		case 'D':
+			acl, control, err := p.parseACL(sddlToDACLFlag)
+			if err != nil {
+				return ntsd, err
+			}
+			ntsd.DACL = acl
+			ntsd.Header.Control |= control | DACLPresent
+
+		case 'S':
+			acl, control, err := p.parseACL(sddlToSACLFlag)
+			if err != nil {
+				return ntsd, err
+			}
+			ntsd.SACL = acl
+			ntsd.Header.Control |= control | SACLPresent
+		}
+	}
+
+	return ntsd, nil
+}
+
+// parseSectionSID reads the SID of an O: or G: section, which runs until
+// the next section tag or the end of the input
+func (p *sddlParser) parseSectionSID() (SID, error) {
+	start := p.pos
+	for !p.done() && !p.atSection(p.pos) {
+		p.pos++
+	}
+	if start == p.pos {
+		return SID{}, p.errorAt(start, "missing SID")
+	}
+	return p.parseSID(p.input[start:p.pos], start)
+}
+
+// parseSID converts a raw SID string or a two-letter SDDL alias to a SID.
+// Domain-relative aliases expand to the parser's domains when they are set
+func (p *sddlParser) parseSID(sidStr string, pos int) (SID, error) {
+	if sid, ok := p.domains.expand(sidStr); ok {
+		return sid, nil
+	}
+	if expanded, ok := sddlToSID[sidStr]; ok {
+		sidStr = expanded
+	}
+
+	sid, err := NewSIDFromString(sidStr)
+	if err != nil {
+		return sid, p.errorAt(pos, "invalid SID %q: %v", sidStr, err)
+	}
+	return sid, nil
+}
+
+// parseACL reads the flags and ACEs of a D: or S: section. A nil ACL is
+// returned for NO_ACCESS_CONTROL
+func (p *sddlParser) parseACL(flagCodes map[string]uint16) (*ACL, uint16, error) {
+	control := uint16(0)
+	null := false
+
+	for !p.done() && p.input[p.pos] != '(' && !p.atSection(p.pos) {
+		rest := p.input[p.pos:]
+		if strings.HasPrefix(rest, SDDLNoAccessControl) {
+			null = true
+			p.pos += len(SDDLNoAccessControl)
+			continue
+		}
+
+		matched := false
+		for code, bit := range flagCodes {
+			if strings.HasPrefix(rest, code) {
+				// No flag code is a prefix of another, so the first match
+				// is the only match
+				control |= bit
+				p.pos += len(code)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, 0, p.errorf("unknown ACL flag")
+		}
+	}
+
+	aces := make([]ACE, 0)
+	for !p.done() && p.input[p.pos] == '(' {
+		ace, err := p.parseACE()
+		if err != nil {
+			return nil, 0, err
+		}
+		aces = append(aces, ace)
+	}
+
+	if !p.done() && !p.atSection(p.pos) {
+		return nil, 0, p.errorf("expected ACE or section")
+	}
+
+	if null {
+		if len(aces) > 0 {
+			return nil, 0, p.errorf("%s ACL cannot contain ACEs", SDDLNoAccessControl)
+		}
+		return nil, control, nil
+	}
+
+	acl := ACL{Aces: aces}
+	if err := acl.recomputeHeader(); err != nil {
+		return nil, 0, p.errorf("%v", err)
+	}
+	return &acl, control, nil
+}
+
+// parseACE reads a single parenthesised ACE string
+func (p *sddlParser) parseACE() (ACE, error) {
+	ace := ACE{}
+	start := p.pos
+
+	if p.done() || p.input[p.pos] != '(' {
+		return ace, p.errorf("expected '('")
+	}
+
+	// Split the ACE into its fields, tracking nesting and quoted strings so
+	// that separators inside a trailing expression are not mistaken for
+	// field boundaries
+	var (
+		fields    []string
+		offsets   []int
+		depth     = 0
+		quoted    = false
+		fieldFrom = start + 1
+		end       = -1
+	)
+	for i := start + 1; i < len(p.input) && end < 0; i++ {
+		c := p.input[i]
+		switch {
+		case quoted:
+			quoted = c != '"'
+		case c == '"':
+			quoted = true
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')':
+			end = i
+			fallthrough
+		case c == ';' && depth == 0:
+			fields = append(fields, p.input[fieldFrom:i])
+			offsets = append(offsets, fieldFrom)
+			fieldFrom = i + 1
+		}
+	}
+	if end < 0 {
+		return ace, p.errorAt(start, "unterminated ACE")
+	}
+	p.pos = end + 1
+
+	if len(fields) != 6 && len(fields) != 7 {
+		return ace, p.errorAt(start, "ACE has %d fields, expected 6 or 7", len(fields))
+	}
+
+	// ACE type
+	aceType, ok := sddlToAceType[fields[0]]
+	if !ok {
+		return ace, p.errorAt(offsets[0], "unknown ACE type %q", fields[0])
+	}
+	ace.Header.Type = aceType
+
+	// ACE flags
+	flags, err := p.parseCodes(fields[1], offsets[1], "ACE flag", func(code string) (uint32, bool) {
+		flag, ok := sddlToAceFlag[code]
+		return uint32(flag), ok
+	})
+	if err != nil {
+		return ace, err
+	}
+	ace.Header.Flags = ACEHeaderFlags(flags)
+
+	// Rights
+	ace.AccessMask.Value, err = p.parseRights(aceType, fields[2], offsets[2])
+	if err != nil {
+		return ace, err
+	}
+
+	// Object and inherited object GUIDs
+	objectType, objectPresent, err := p.parseGUID(fields[3], offsets[3])
+	if err != nil {
+		return ace, err
+	}
+	inheritedType, inheritedPresent, err := p.parseGUID(fields[4], offsets[4])
+	if err != nil {
+		return ace, err
+	}
+
+	// Account SID
+	sid, err := p.parseSID(fields[5], offsets[5])
+	if err != nil {
+		return ace, err
+	}
+
+	// Conditional expression of callback and access filter ACEs, or the
+	// attribute of resource attribute ACEs
+	var (
+		cond *ConditionalExpression
+		attr *ClaimSecurityAttribute
+	)
+	switch {
+	case len(fields) < 7:
+		if aceType == AceTypeSystemResourceAttribute {
+			return ace, p.errorAt(start, "resource attribute ACE is missing its attribute")
+		}
+	case aceType == AceTypeSystemResourceAttribute:
+		attr, err = p.parseResourceAttribute(fields[6], offsets[6])
+		if err != nil {
+			return ace, err
+		}
+	case isConditionalAceType(aceType):
+		p.pos = offsets[6]
+		cond, err = p.parseConditionalExpression(offsets[6] + len(fields[6]))
+		if err != nil {
+			return ace, err
+		}
+		p.pos = end + 1
+	default:
+		return ace, p.errorAt(offsets[6], "conditional expressions are only valid on callback ACEs")
+	}
+
+	if isObjectAceType(aceType) {
+		aa := AdvancedAce{SecurityIdentifier: sid, Condition: cond}
+		if objectPresent {
+			aa.Flags |= ACEInheritanceFlagsObjectTypePresent
+			aa.ObjectType = objectType
+		}
+		if inheritedPresent {
+			aa.Flags |= ACEInheritanceFlagsInheritedObjectTypePresent
+			aa.InheritedObjectType = inheritedType
+		}
+		ace.ObjectAce = aa
+	} else {
+		if objectPresent || inheritedPresent {
+			return ace, p.errorAt(offsets[3], "object GUIDs are only valid on object ACEs")
+		}
+		ace.ObjectAce = BasicAce{SecurityIdentifier: sid, Condition: cond, Attribute: attr}
+	}
+
+	if err := ace.recomputeSize(); err != nil {
+		return ace, p.errorAt(start, "%v", err)
+	}
+	return ace, nil
+}
+
+// parseCodes splits a field into two-letter codes and ORs their values
+func (p *sddlParser) parseCodes(field string, pos int, kind string, lookup func(string) (uint32, bool)) (uint32, error) {
+	if len(field)%2 != 0 {
+		return 0, p.errorAt(pos, "malformed %s string %q", kind, field)
+	}
+
+	value := uint32(0)
+	for i := 0; i < len(field); i += 2 {
+		code := field[i : i+2]
+		bits, ok := lookup(code)
+		if !ok {
+			return 0, p.errorAt(pos+i, "unknown %s %q", kind, code)
+		}
+		value |= bits
+	}
+	return value, nil
+}
+
+// parseRights reads an access mask given as rights codes, a hex mask such
+// as 0x1F01FF, or a decimal mask. Mandatory label ACEs use their own codes
+func (p *sddlParser) parseRights(aceType AceType, field string, pos int) (uint32, error) {
+	if field == "" {
+		return 0, nil
+	}
+
+	if field[0] >= '0' && field[0] <= '9' {
+		mask, err := strconv.ParseUint(field, 0, 32)
+		if err != nil {
+			return 0, p.errorAt(pos, "invalid access mask %q", field)
+		}
+		return uint32(mask), nil
+	}
+
+	if aceType == AceTypeSystemMandatoryLabel {
+		return p.parseCodes(field, pos, "mandatory label right", func(code string) (uint32, bool) {
+			for right, symbol := range MandatoryLabelRightsSDDL {
+				if symbol == code {
+					return right, true
+				}
+			}
+			return 0, false
+		})
+	}
+
+	return p.parseCodes(field, pos, "access right", func(code string) (uint32, bool) {
+		if right, ok := sddlToAceRight[code]; ok {
+			return right, true
+		}
+		right, ok := AceCompositeRightsSDDL[code]
+		return right, ok
+	})
+}
+
+// parseGUID reads an optional object type GUID
+func (p *sddlParser) parseGUID(field string, pos int) (GUID, bool, error) {
+	if field == "" {
+		return GUID{}, false, nil
+	}
+
+	guid, err := NewGUIDFromString(field)
+	if err != nil {
+		return guid, false, p.errorAt(pos, "%v", err)
+	}
+	return guid, true, nil
+}
+
+// isObjectAceType reports whether ACEs of this type carry object GUIDs
+func isObjectAceType(aceType AceType) bool {
+	switch aceType {
+	case AceTypeAccessAllowedObject, AceTypeAccessDeniedObject,
+		AceTypeSystemAuditObject, AceTypeSystemAlarmObject,
+		AceTypeAccessAllowedCallbackObject, AceTypeAccessDeniedCallbackObject,
+		AceTypeSystemAuditCallbackObject, AceTypeSystemAlarmCallbackObject:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNtSecurityDescriptorFromSDDL(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Round-trips the test descriptor's SDDL", func(t *testing.T) {
+		sddl, err := getTestNtsdSDDLTestString()
+		r.NoError(err)
+
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDL(sddl)
+		r.NoError(err)
+		r.Equal(sddl, ntsd.ToSDDL())
+
+		expected := newTestSD()
+		r.Equal(len(expected.DACL.Aces), len(ntsd.DACL.Aces))
+		r.Equal(expected.DACL.Header.Size, ntsd.DACL.Header.Size)
+		r.Equal(expected.DACL.Header.Revision, ntsd.DACL.Header.Revision)
+	})
+
+	t.Run("Produces the same binary form as the parsed descriptor", func(t *testing.T) {
+		sddl, err := getTestNtsdSDDLTestString()
+		r.NoError(err)
+
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDL(sddl)
+		r.NoError(err)
+
+		expected := newTestSD()
+		expectedBytes, err := expected.DACL.MarshalBinary()
+		r.NoError(err)
+		actualBytes, err := ntsd.DACL.MarshalBinary()
+		r.NoError(err)
+		r.Equal(expectedBytes, actualBytes)
+	})
+
+	t.Run("Accepts sections in any order and expands aliases", func(t *testing.T) {
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDL("D:P(A;OICI;0x1200a9;;;BU)G:SYO:BA")
+		r.NoError(err)
+		r.Equal("S-1-5-32-544", ntsd.Owner.String())
+		r.Equal("S-1-5-18", ntsd.Group.String())
+		r.True(ntsd.Header.HasControl(winacl.DACLPresent | winacl.DACLProtected))
+
+		ace := ntsd.DACL.Aces[0]
+		r.Equal(winacl.AceTypeAccessAllowed, ace.GetType())
+		r.Equal(winacl.ACEHeaderFlags(winacl.ACEHeaderFlagsObjectInheritAce|winacl.ACEHeaderFlagsContainerInheritAce), ace.Header.Flags)
+		r.Equal(uint32(0x1200a9), ace.AccessMask.Raw())
+		r.Equal("S-1-5-32-545", ace.ObjectAce.GetPrincipal().String())
+		r.Equal(uint16(24), ace.Header.Size)
+	})
+
+	t.Run("Parses SACLs and their flags", func(t *testing.T) {
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDL("S:PAI(AU;SAFA;GA;;;WD)")
+		r.NoError(err)
+		r.Nil(ntsd.DACL)
+		r.Equal(winacl.ACLStateAbsent, ntsd.DACLState())
+		r.Equal(winacl.ACLStatePopulated, ntsd.SACLState())
+		r.True(ntsd.Header.HasControl(winacl.SACLPresent | winacl.SACLProtected | winacl.SACLAutoInherited))
+		r.Equal(winacl.AceTypeSystemAudit, ntsd.SACL.Aces[0].GetType())
+	})
+
+	t.Run("Distinguishes NULL and empty DACLs", func(t *testing.T) {
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDL("D:NO_ACCESS_CONTROL")
+		r.NoError(err)
+		r.Equal(winacl.ACLStateNull, ntsd.DACLState())
+
+		ntsd, err = winacl.NewNtSecurityDescriptorFromSDDL("D:")
+		r.NoError(err)
+		r.Equal(winacl.ACLStateEmpty, ntsd.DACLState())
+	})
+
+	t.Run("Parses object ACEs", func(t *testing.T) {
+		ntsd, err := winacl.NewNtSecurityDescriptorFromSDDL(
+			"D:(OA;CIIO;RP;4c164200-20c0-11d0-a768-00aa006e0529;bf967aba-0de6-11d0-a285-00aa003049e2;RU)")
+		r.NoError(err)
+
+		aa, ok := ntsd.DACL.Aces[0].ObjectAce.(winacl.AdvancedAce)
+		r.True(ok)
+		r.Equal(winacl.ACEInheritanceFlagsObjectTypePresent|winacl.ACEInheritanceFlagsInheritedObjectTypePresent, aa.Flags)
+		r.Equal("4c164200-20c0-11d0-a768-00aa006e0529", aa.ObjectType.String())
+		r.Equal("bf967aba-0de6-11d0-a285-00aa003049e2", aa.InheritedObjectType.String())
+		r.Equal(byte(winacl.ACLRevisionDS), ntsd.DACL.Header.Revision)
+	})
+
+	t.Run("Reports the position of errors", func(t *testing.T) {
+		cases := []struct {
+			sddl   string
+			offset int
+		}{
+			{"X:BA", 0},
+			{"O:BAO:SY", 4},
+			{"O:ZZ", 2},
+			{"D:(Q;;GA;;;WD)", 3},
+			{"D:(A;OIXX;GA;;;WD)", 7},
+			{"D:(A;;GAZZ;;;WD)", 8},
+			{"D:(A;;GA;not-a-guid;;WD)", 9},
+			{"D:(A;;GA;;;WD", 2},
+			{"D:(A;;GA;;WD)", 2},
+			{"D:(A;;GA;;;WD)junk", 14},
+		}
+
+		for _, c := range cases {
+			_, err := winacl.NewNtSecurityDescriptorFromSDDL(c.sddl)
+			r.Error(err, c.sddl)
+
+			var parseErr winacl.SDDLParseError
+			r.ErrorAs(err, &parseErr, c.sddl)
+			r.Equal(c.offset, parseErr.Offset, c.sddl)
+		}
+	})
+}
+
+func TestNewACEFromSDDL(t *testing.T) {
+	r := require.New(t)
+
+	ace, err := winacl.NewACEFromSDDL("(D;ID;WPWD;;;S-1-5-21-1-2-3-1104)")
+	r.NoError(err)
+	r.Equal(winacl.AceTypeAccessDenied, ace.GetType())
+	r.Equal(winacl.ACEHeaderFlags(winacl.ACEHeaderFlagsInheritedAce), ace.Header.Flags)
+	r.Equal(uint32(winacl.ADSRightDSWriteProp|winacl.AccessMaskWriteDACL), ace.AccessMask.Raw())
+	r.Equal("(D;ID;WPWD;;;S-1-5-21-1-2-3-1104)", ace.ToSDDL())
+
+	_, err = winacl.NewACEFromSDDL("(D;;GA;;;WD)(A;;GA;;;WD)")
+	r.Error(err)
+}
+
+func TestSDDLBuilderParse(t *testing.T) {
+	r := require.New(t)
+
+	builder := winacl.NewSDDLBuilder()
+	sddl := builder.
+		WithOwner("S-1-5-18").
+		WithGroup("S-1-5-32-544").
+		WithFlag("P").
+		AccessAllowedACE("S-1-1-0", winacl.AccessMaskGenericRead, 0).
+		Build()
+
+	ntsd, err := builder.Parse(sddl)
+	r.NoError(err)
+	r.Equal("O:S-1-5-18G:S-1-5-32-544D:P(A;;GR;;;WD)", ntsd.ToSDDL())
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 )
@@ -149,7 +148,13 @@ func (s SID) String() string {
 	// Typical SID has around 50 chars
 	sb.Grow(50)
 
-	fmt.Fprintf(&sb, "S-%v-%v", s.Revision, int(s.Authority[5]))
+	// Authorities of 2^32 and above are written in hexadecimal, as Windows does
+	authority := s.IdentifierAuthority()
+	if authority >= 1<<32 {
+		fmt.Fprintf(&sb, "S-%v-0x%012x", s.Revision, authority)
+	} else {
+		fmt.Fprintf(&sb, "S-%v-%v", s.Revision, authority)
+	}
 	for i := 0; i < int(s.NumAuthorities); i++ {
 		fmt.Fprintf(&sb, "-%v", s.SubAuthorities[i])
 	}
@@ -157,6 +162,19 @@ func (s SID) String() string {
 	return sb.String()
 }
 
+// IdentifierAuthority returns the SID's 48-bit identifier authority, which
+// is stored big-endian
+func (s SID) IdentifierAuthority() uint64 {
+	var authority uint64
+	for _, b := range s.Authority {
+		authority = authority<<8 | uint64(b)
+	}
+	return authority
+}
+
+// maxSubAuthorities is the most sub-authorities a SID can hold
+const maxSubAuthorities = 15
+
 // NewSID is a constructor that will parse out a SID from a byte buffer
 func NewSID(buf *bytes.Buffer, sidLength int) (SID, error) {
 	sid := SID{}
@@ -173,7 +191,7 @@ func NewSID(buf *bytes.Buffer, sidLength int) (SID, error) {
 	}
 
 	numAuth := data[1]
-	if numAuth > 15 {
+	if numAuth > maxSubAuthorities {
 		return sid, SIDInvalidError{"invalid number of subauthorities"}
 	}
 
@@ -198,8 +216,64 @@ func NewSID(buf *bytes.Buffer, sidLength int) (SID, error) {
 	return sid, nil
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler, returning the SID in
+// its binary form
+func (s SID) MarshalBinary() ([]byte, error) {
+	if s.Revision != 1 {
+		return nil, SIDInvalidError{"invalid SID revision"}
+	}
+	if len(s.SubAuthorities) > maxSubAuthorities {
+		return nil, SIDInvalidError{"invalid number of subauthorities"}
+	}
+	if len(s.Authority) > 6 {
+		return nil, SIDInvalidError{"invalid authority length"}
+	}
+
+	data := make([]byte, 8+(len(s.SubAuthorities)*4))
+	data[0] = s.Revision
+	data[1] = byte(len(s.SubAuthorities))
+
+	// The authority is big-endian, so shorter slices are right-aligned
+	copy(data[8-len(s.Authority):8], s.Authority)
+
+	for i, subAuth := range s.SubAuthorities {
+		offset := 8 + (i * 4)
+		binary.LittleEndian.PutUint32(data[offset:offset+4], subAuth)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, parsing a SID
+// from its binary form
+func (s *SID) UnmarshalBinary(data []byte) error {
+	sid, err := NewSID(bytes.NewBuffer(data), len(data))
+	if err != nil {
+		return err
+	}
+	*s = sid
+	return nil
+}
+
+// sidLengthFromBytes returns the length, in bytes, of the binary SID at the
+// start of data, as declared by its sub-authority count
+func sidLengthFromBytes(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, SIDInvalidError{"SID data too short"}
+	}
+
+	length := 8 + (int(data[1]) * 4)
+	if len(data) < length {
+		return 0, SIDInvalidError{"SID data too short for subauthorities"}
+	}
+	return length, nil
+}
+
 // NewSIDFromString creates a SID from its string representation
 // Format: S-1-5-21-1234567890-1234567890-1234567890-1001
+//
+// The identifier authority may be decimal or, as Windows writes authorities
+// of 2^32 and above, hexadecimal with a 0x prefix. Values that overflow
+// their field and SIDs with more than 15 sub-authorities are rejected
 func NewSIDFromString(sidStr string) (SID, error) {
 	parts := strings.Split(sidStr, "-")
 	if len(parts) < 3 {
@@ -212,20 +286,32 @@ func NewSIDFromString(sidStr string) (SID, error) {
 	}
 	
 	// Parse revision
-	revision, err := strconv.Atoi(parts[1])
+	revision, err := strconv.ParseUint(parts[1], 10, 8)
 	if err != nil {
 		return SID{}, SIDInvalidError{"invalid revision"}
 	}
 	
-	// Parse authority
-	authority, err := strconv.Atoi(parts[2])
+	// Parse the 48-bit authority
+	var authority uint64
+	if strings.HasPrefix(parts[2], "0x") || strings.HasPrefix(parts[2], "0X") {
+		authority, err = strconv.ParseUint(parts[2][2:], 16, 48)
+	} else {
+		authority, err = strconv.ParseUint(parts[2], 10, 48)
+	}
 	if err != nil {
 		return SID{}, SIDInvalidError{"invalid authority"}
 	}
 	
-	// Create authority byte array
+	// Create authority byte array, big-endian
 	authorityBytes := make([]byte, 6)
-	authorityBytes[5] = byte(authority) // Only using the last byte as that's how we print it
+	for i := 5; i >= 0; i-- {
+		authorityBytes[i] = byte(authority)
+		authority >>= 8
+	}
+	
+	if len(parts)-3 > maxSubAuthorities {
+		return SID{}, SIDInvalidError{"invalid number of subauthorities"}
+	}
 	
 	// Parse sub-authorities
 	subAuthorities := make([]uint32, len(parts)-3)
@@ -247,23 +333,10 @@ func NewSIDFromString(sidStr string) (SID, error) {
 
 // Resolve will return the human readable description of a SID
 // If one does not exist, it will return in the normal "S-!-" notation
+//
+// Only well-known SIDs are resolved; use ResolveWith for other sources
 func (s SID) Resolve() string {
-	s1 := s.String()
-	
-	// First check direct matches in the map
-	if resolved, ok := WellKnownSIDs[s1]; ok {
-		return resolved
-	}
-
-	// Then check regex patterns
-	for pattern, name := range WellKnownSIDsRE {
-		match, err := regexp.MatchString(pattern, s1)
-		if err == nil && match {
-			return name
-		}
-	}
-	
-	return s1
+	return s.ResolveWith(WellKnownResolver{})
 }
 
 // SIDInvalidError represents errors that occur when parsing invalid SID data
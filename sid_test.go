@@ -159,3 +159,75 @@ func TestSIDResolve(t *testing.T) {
 		r.Equal(sidString, sid.Resolve())
 	})
 }
+
+func TestSIDMarshalBinary(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Round-trips a SID", func(t *testing.T) {
+		sid, err := winacl.NewSIDFromString("S-1-5-21-1004336348-1177238915-682003330-512")
+		r.NoError(err)
+
+		out, err := sid.MarshalBinary()
+		r.NoError(err)
+		r.Len(out, 28)
+
+		var parsed winacl.SID
+		r.NoError(parsed.UnmarshalBinary(out))
+		r.Equal(sid.String(), parsed.String())
+	})
+
+	t.Run("Returns an error for an invalid revision", func(t *testing.T) {
+		_, err := winacl.SID{Revision: 2}.MarshalBinary()
+		r.IsType(winacl.SIDInvalidError{}, err)
+	})
+}
+
+func TestSIDIdentifierAuthority(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Parses and formats authorities of 256 and above", func(t *testing.T) {
+		sid, err := winacl.NewSIDFromString("S-1-300-1")
+		r.NoError(err)
+		r.Equal([]byte{0, 0, 0, 0, 1, 44}, sid.Authority)
+		r.Equal(uint64(300), sid.IdentifierAuthority())
+		r.Equal("S-1-300-1", sid.String())
+	})
+
+	t.Run("Uses hexadecimal for authorities of 2^32 and above", func(t *testing.T) {
+		sid, err := winacl.NewSIDFromString("S-1-0x123456789abc-7")
+		r.NoError(err)
+		r.Equal([]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc}, sid.Authority)
+		r.Equal("S-1-0x123456789abc-7", sid.String())
+
+		out, err := sid.MarshalBinary()
+		r.NoError(err)
+		var parsed winacl.SID
+		r.NoError(parsed.UnmarshalBinary(out))
+		r.Equal(sid.String(), parsed.String())
+
+		sid, err = winacl.NewSIDFromString("S-1-0x5-18")
+		r.NoError(err)
+		r.Equal("S-1-5-18", sid.String())
+	})
+
+	t.Run("Rejects overflow", func(t *testing.T) {
+		for _, s := range []string{
+			"S-1-0x1000000000000-1", // 49-bit authority
+			"S-1-281474976710656-1", // 2^48
+			"S-256-5-18",            // Revision
+			"S-1-5-4294967296",      // Sub-authority
+			"S-1-0x-1",
+		} {
+			_, err := winacl.NewSIDFromString(s)
+			r.IsType(winacl.SIDInvalidError{}, err, s)
+		}
+	})
+
+	t.Run("Enforces the sub-authority limit", func(t *testing.T) {
+		_, err := winacl.NewSIDFromString("S-1-5-1-2-3-4-5-6-7-8-9-10-11-12-13-14-15")
+		r.NoError(err)
+
+		_, err = winacl.NewSIDFromString("S-1-5-1-2-3-4-5-6-7-8-9-10-11-12-13-14-15-16")
+		r.IsType(winacl.SIDInvalidError{}, err)
+	})
+}
@@ -0,0 +1,166 @@
+package winacl
+
+import (
+	"fmt"
+)
+
+// SIDKey is a comparable form of a SID. Unlike SID, which holds slices, it
+// can be compared with == and used as a map key
+type SIDKey struct {
+	revision       byte
+	count          byte
+	authority      [6]byte
+	subAuthorities [maxSubAuthorities]uint32
+}
+
+// Key returns the SID's comparable form. Sub-authorities past the fifteenth
+// are dropped, as no valid SID holds them
+func (s SID) Key() SIDKey {
+	key := SIDKey{revision: s.Revision}
+
+	authority := s.Authority
+	if len(authority) > len(key.authority) {
+		authority = authority[len(authority)-len(key.authority):]
+	}
+	// The authority is big-endian, so shorter slices are right-aligned
+	copy(key.authority[len(key.authority)-len(authority):], authority)
+
+	key.count = byte(copy(key.subAuthorities[:], s.SubAuthorities))
+	return key
+}
+
+// SID returns the SID the key was made from
+func (k SIDKey) SID() SID {
+	return SID{
+		Revision:       k.revision,
+		NumAuthorities: k.count,
+		Authority:      append([]byte(nil), k.authority[:]...),
+		SubAuthorities: append([]uint32(nil), k.subAuthorities[:k.count]...),
+	}
+}
+
+// String returns the SID's string form
+func (k SIDKey) String() string {
+	return k.SID().String()
+}
+
+// MarshalText implements encoding.TextMarshaler, so keys can be used in
+// JSON objects
+func (k SIDKey) MarshalText() ([]byte, error) {
+	return k.SID().MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (k *SIDKey) UnmarshalText(text []byte) error {
+	var sid SID
+	if err := sid.UnmarshalText(text); err != nil {
+		return err
+	}
+	*k = sid.Key()
+	return nil
+}
+
+// Equal reports whether two SIDs are the same, like RtlEqualSid
+func (s SID) Equal(other SID) bool {
+	return s.Key() == other.Key()
+}
+
+// Compare orders SIDs by revision, identifier authority and then
+// sub-authorities, with a SID sorting before those it is a prefix of. It
+// returns -1, 0 or 1 as s sorts before, the same as or after other
+func (s SID) Compare(other SID) int {
+	a, b := s.Key(), other.Key()
+	if a.revision != b.revision {
+		return compareUint64(uint64(a.revision), uint64(b.revision))
+	}
+	if s.IdentifierAuthority() != other.IdentifierAuthority() {
+		return compareUint64(s.IdentifierAuthority(), other.IdentifierAuthority())
+	}
+	for i := 0; i < int(a.count) && i < int(b.count); i++ {
+		if a.subAuthorities[i] != b.subAuthorities[i] {
+			return compareUint64(uint64(a.subAuthorities[i]), uint64(b.subAuthorities[i]))
+		}
+	}
+	return compareUint64(uint64(a.count), uint64(b.count))
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// EqualPrefix reports whether two SIDs are the same but for their last
+// sub-authority, like RtlEqualPrefixSid. Principals of the same domain have
+// equal prefixes
+func (s SID) EqualPrefix(other SID) bool {
+	a, b := s.Key(), other.Key()
+	if a.count != b.count || a.count == 0 {
+		return false
+	}
+	a.subAuthorities[a.count-1], b.subAuthorities[b.count-1] = 0, 0
+	return a == b
+}
+
+// Domain returns the SID without its last sub-authority, which for a
+// domain-relative SID is the domain's SID. A SID without sub-authorities
+// is returned unchanged
+func (s SID) Domain() SID {
+	key := s.Key()
+	if key.count > 0 {
+		key.count--
+		key.subAuthorities[key.count] = 0
+	}
+	return key.SID()
+}
+
+// RID returns the SID's last sub-authority, which for a domain-relative SID
+// is the principal's relative identifier. It is 0 for a SID without
+// sub-authorities
+func (s SID) RID() uint32 {
+	key := s.Key()
+	if key.count == 0 {
+		return 0
+	}
+	return key.subAuthorities[key.count-1]
+}
+
+// IsDomainRelative reports whether the SID is a principal of a Windows
+// domain or machine, S-1-5-21-X-Y-Z-RID
+func (s SID) IsDomainRelative() bool {
+	key := s.Key()
+	return s.IdentifierAuthority() == 5 && key.count == 5 && key.subAuthorities[0] == 21
+}
+
+// WithRID returns a copy of the SID with rid appended, such as a domain's
+// SID with a well-known RID
+func (s SID) WithRID(rid uint32) SID {
+	sid := s.Key().SID()
+	sid.SubAuthorities = append(sid.SubAuthorities, rid)
+	sid.NumAuthorities = byte(len(sid.SubAuthorities))
+	return sid
+}
+
+// MarshalText implements encoding.TextMarshaler, returning the SID's
+// string form. It also makes SIDs marshal to JSON strings
+func (s SID) MarshalText() ([]byte, error) {
+	if len(s.Authority) < 6 || int(s.NumAuthorities) > len(s.SubAuthorities) {
+		return nil, SIDInvalidError{"invalid SID"}
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing a SID from
+// its string form
+func (s *SID) UnmarshalText(text []byte) error {
+	sid, err := NewSIDFromString(string(text))
+	if err != nil {
+		return fmt.Errorf("unmarshaling SID %q: %w", text, err)
+	}
+	*s = sid
+	return nil
+}
@@ -0,0 +1,99 @@
+package winacl_test
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSIDKey(t *testing.T) {
+	r := require.New(t)
+
+	t.Run("Keys are comparable map keys", func(t *testing.T) {
+		set := map[winacl.SIDKey]bool{mustSID(t, "S-1-5-18").Key(): true}
+		r.True(set[mustSID(t, "S-1-5-18").Key()])
+		r.False(set[mustSID(t, "S-1-5-19").Key()])
+
+		// Short authorities are right-aligned, as in the binary form
+		short := winacl.SID{Revision: 1, NumAuthorities: 1, Authority: []byte{5}, SubAuthorities: []uint32{18}}
+		r.True(set[short.Key()])
+
+		r.Equal("S-1-5-18", mustSID(t, "S-1-5-18").Key().String())
+		r.Equal(mustSID(t, "S-1-5-18"), mustSID(t, "S-1-5-18").Key().SID())
+	})
+
+	t.Run("Token group attributes are keyed by SID", func(t *testing.T) {
+		token := winacl.NewTokenUser(mustSID(t, "S-1-5-21-1-2-3-1001"), nil)
+		token.AddGroup(mustSID(t, "S-1-5-32-544"), winacl.GroupUseForDenyOnly)
+		r.Equal(winacl.GroupUseForDenyOnly, token.GroupAttributes[mustSID(t, "S-1-5-32-544").Key()])
+
+		short := winacl.SID{Revision: 1, NumAuthorities: 2, Authority: []byte{5}, SubAuthorities: []uint32{32, 544}}
+		r.Equal(winacl.GroupUseForDenyOnly, token.GroupAttributesOf(short))
+	})
+
+	t.Run("Equal and Compare", func(t *testing.T) {
+		r.True(mustSID(t, "S-1-5-32-544").Equal(mustSID(t, "S-1-5-32-544")))
+		r.False(mustSID(t, "S-1-5-32-544").Equal(mustSID(t, "S-1-5-32-545")))
+
+		sids := []winacl.SID{
+			mustSID(t, "S-1-5-32-545"),
+			mustSID(t, "S-1-16-8192"),
+			mustSID(t, "S-1-5-32"),
+			mustSID(t, "S-1-1-0"),
+			mustSID(t, "S-1-5-32-544"),
+			mustSID(t, "S-1-5-18"),
+		}
+		sort.Slice(sids, func(i, j int) bool { return sids[i].Compare(sids[j]) < 0 })
+
+		var sorted []string
+		for _, s := range sids {
+			sorted = append(sorted, s.String())
+		}
+		r.Equal([]string{"S-1-1-0", "S-1-5-18", "S-1-5-32", "S-1-5-32-544", "S-1-5-32-545", "S-1-16-8192"}, sorted)
+		r.Equal(0, mustSID(t, "S-1-5-18").Compare(mustSID(t, "S-1-5-18")))
+	})
+
+	t.Run("Domain helpers", func(t *testing.T) {
+		user := mustSID(t, "S-1-5-21-1-2-3-1001")
+		r.True(user.IsDomainRelative())
+		r.False(mustSID(t, "S-1-5-32-544").IsDomainRelative())
+		r.False(mustSID(t, "S-1-5-21-1-2-3").IsDomainRelative())
+
+		r.Equal("S-1-5-21-1-2-3", user.Domain().String())
+		r.Equal(uint32(1001), user.RID())
+		r.Equal("S-1-5-21-1-2-3-512", user.Domain().WithRID(512).String())
+		r.Equal(uint32(0), mustSID(t, "S-1-5").RID())
+
+		r.True(user.EqualPrefix(mustSID(t, "S-1-5-21-1-2-3-500")))
+		r.False(user.EqualPrefix(mustSID(t, "S-1-5-21-1-2-4-1001")))
+		r.False(user.EqualPrefix(user.Domain()))
+	})
+
+	t.Run("Text and JSON", func(t *testing.T) {
+		type config struct {
+			Owner  winacl.SID
+			Groups []winacl.SID
+			Names  map[winacl.SIDKey]string
+		}
+		in := config{
+			Owner:  mustSID(t, "S-1-5-21-1-2-3-1001"),
+			Groups: []winacl.SID{mustSID(t, "S-1-5-32-544")},
+			Names:  map[winacl.SIDKey]string{mustSID(t, "S-1-5-18").Key(): "SYSTEM"},
+		}
+
+		data, err := json.Marshal(in)
+		r.NoError(err)
+		r.JSONEq(`{"Owner":"S-1-5-21-1-2-3-1001","Groups":["S-1-5-32-544"],"Names":{"S-1-5-18":"SYSTEM"}}`, string(data))
+
+		var out config
+		r.NoError(json.Unmarshal(data, &out))
+		r.True(in.Owner.Equal(out.Owner))
+		r.True(in.Groups[0].Equal(out.Groups[0]))
+		r.Equal("SYSTEM", out.Names[mustSID(t, "S-1-5-18").Key()])
+
+		r.Error(json.Unmarshal([]byte(`{"Owner":"not a SID"}`), &out))
+	})
+}
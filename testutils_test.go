@@ -5,8 +5,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"testing"
 
 	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
 )
 
 func getTestDataDir() string {
@@ -33,3 +35,28 @@ func newTestSD() winacl.NtSecurityDescriptor {
 	ntsd, _ := winacl.NewNtSecurityDescriptor(ntsdBytes)
 	return ntsd
 }
+
+// mustSID parses a SID string, failing the test if it is invalid
+func mustSID(t testing.TB, s string) winacl.SID {
+	t.Helper()
+	sid, err := winacl.NewSIDFromString(s)
+	require.NoError(t, err)
+	return sid
+}
+
+// mustParseSDDL parses an SDDL security descriptor, failing the test if it
+// is invalid
+func mustParseSDDL(t testing.TB, sddl string) *winacl.NtSecurityDescriptor {
+	t.Helper()
+	ntsd, err := winacl.NewNtSecurityDescriptorFromSDDL(sddl)
+	require.NoError(t, err)
+	return &ntsd
+}
+
+// accessCheckSDDL runs AccessCheck against the descriptor sddl describes
+func accessCheckSDDL(t testing.TB, sddl string, token *winacl.TokenUser, desired uint32,
+	options *winacl.AccessCheckOptions,
+) *winacl.AccessCheckResult {
+	t.Helper()
+	return winacl.AccessCheck(mustParseSDDL(t, sddl), token, desired, options)
+}
@@ -0,0 +1,88 @@
+package winacl
+
+import (
+	"fmt"
+)
+
+// GroupAttributes are the SE_GROUP_* attributes of a group in a token
+//
+// https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-token_groups
+type GroupAttributes uint32
+
+// GroupAttributes constants
+const (
+	GroupMandatory        GroupAttributes = 0x00000001
+	GroupEnabledByDefault GroupAttributes = 0x00000002
+	GroupEnabled          GroupAttributes = 0x00000004
+	GroupOwner            GroupAttributes = 0x00000008
+	GroupUseForDenyOnly   GroupAttributes = 0x00000010
+	GroupIntegrity        GroupAttributes = 0x00000020
+	GroupIntegrityEnabled GroupAttributes = 0x00000040
+	GroupResource         GroupAttributes = 0x20000000
+	GroupLogonID          GroupAttributes = 0xC0000000
+)
+
+// GroupAttributesLookup maps GroupAttributes to their SE_GROUP_* names
+var GroupAttributesLookup = map[GroupAttributes]string{
+	GroupMandatory:        "SE_GROUP_MANDATORY",
+	GroupEnabledByDefault: "SE_GROUP_ENABLED_BY_DEFAULT",
+	GroupEnabled:          "SE_GROUP_ENABLED",
+	GroupOwner:            "SE_GROUP_OWNER",
+	GroupUseForDenyOnly:   "SE_GROUP_USE_FOR_DENY_ONLY",
+	GroupIntegrity:        "SE_GROUP_INTEGRITY",
+	GroupIntegrityEnabled: "SE_GROUP_INTEGRITY_ENABLED",
+	GroupResource:         "SE_GROUP_RESOURCE",
+	GroupLogonID:          "SE_GROUP_LOGON_ID",
+}
+
+// DefaultGroupAttributes are the attributes of a group added without any,
+// that of an ordinary group enabled in the token
+const DefaultGroupAttributes = GroupMandatory | GroupEnabledByDefault | GroupEnabled
+
+// AddGroup adds a group with the given attributes to the token
+func (t *TokenUser) AddGroup(sid SID, attributes GroupAttributes) {
+	t.Groups = append(t.Groups, sid)
+	if t.GroupAttributes == nil {
+		t.GroupAttributes = make(map[SIDKey]GroupAttributes)
+	}
+	t.GroupAttributes[sid.Key()] = attributes
+}
+
+// GroupAttributesOf returns the attributes of one of the token's groups.
+// Groups without recorded attributes have DefaultGroupAttributes
+func (t *TokenUser) GroupAttributesOf(sid SID) GroupAttributes {
+	if attributes, ok := t.GroupAttributes[sid.Key()]; ok {
+		return attributes
+	}
+	return DefaultGroupAttributes
+}
+
+// IntegrityLevel returns the integrity level held by the token's group
+// with the SE_GROUP_INTEGRITY attribute, if it has one
+func (t *TokenUser) IntegrityLevel() (IntegrityLevel, bool) {
+	for _, group := range t.Groups {
+		if t.GroupAttributesOf(group)&GroupIntegrity == 0 {
+			continue
+		}
+		if level, err := IntegrityLevelFromSID(group); err == nil {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// groupMatches reports whether a token group counts when matching an ACE.
+// Deny-only groups only match deny ACEs, and disabled groups match nothing
+func (t *TokenUser) groupMatches(sid SID, deny bool) (bool, string) {
+	attributes := t.GroupAttributesOf(sid)
+	switch {
+	case attributes&GroupUseForDenyOnly != 0:
+		if deny {
+			return true, "Matches a deny-only group SID"
+		}
+		return false, "Group SID is deny-only"
+	case attributes&GroupEnabled == 0:
+		return false, fmt.Sprintf("Group SID is disabled (attributes 0x%08X)", uint32(attributes))
+	}
+	return true, "Matches a group SID"
+}
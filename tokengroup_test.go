@@ -0,0 +1,70 @@
+package winacl_test
+
+import (
+	"testing"
+
+	"github.com/audibleblink/go-winacl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenGroupAttributes(t *testing.T) {
+	r := require.New(t)
+
+	admins := mustSID(t, "S-1-5-32-544")
+
+	// A UAC-filtered token holds BUILTIN\Administrators for deny only
+	filtered := winacl.NewTokenUser(mustSID(t, "S-1-5-21-1-2-3-1001"), nil)
+	filtered.AddGroup(admins, winacl.GroupUseForDenyOnly)
+	filtered.AddGroup(mustSID(t, "S-1-16-8192"), winacl.GroupIntegrity|winacl.GroupIntegrityEnabled)
+
+	elevated := winacl.NewTokenUser(mustSID(t, "S-1-5-21-1-2-3-1001"), []winacl.SID{admins})
+
+	t.Run("Deny-only groups do not match allow ACEs", func(t *testing.T) {
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BA)", filtered, 0x1, nil).Granted)
+		r.True(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BA)", elevated, 0x1, nil).Granted)
+	})
+
+	t.Run("Deny-only groups match deny ACEs", func(t *testing.T) {
+		r.False(accessCheckSDDL(t, "O:SYD:(D;;0x1;;;BA)(A;;0x1;;;WD)", filtered, 0x1, nil).Granted)
+	})
+
+	t.Run("Disabled groups match nothing", func(t *testing.T) {
+		token := winacl.NewTokenUser(mustSID(t, "S-1-5-21-1-2-3-1001"), nil)
+		token.AddGroup(admins, winacl.GroupMandatory)
+
+		r.False(accessCheckSDDL(t, "O:SYD:(A;;0x1;;;BA)", token, 0x1, nil).Granted)
+		r.True(accessCheckSDDL(t, "O:SYD:(D;;0x1;;;BA)(A;;0x1;;;WD)", token, 0x1, nil).Granted)
+	})
+
+	t.Run("Groups without attributes are enabled", func(t *testing.T) {
+		r.Equal(winacl.DefaultGroupAttributes, elevated.GroupAttributesOf(admins))
+		r.Equal(winacl.GroupUseForDenyOnly, filtered.GroupAttributesOf(admins))
+	})
+
+	t.Run("Member_of honours group attributes", func(t *testing.T) {
+		allow := `O:SYD:(XA;;0x1;;;WD;(Member_of {SID(BA)}))`
+		r.False(accessCheckSDDL(t, allow, filtered, 0x1, nil).Granted)
+		r.True(accessCheckSDDL(t, allow, elevated, 0x1, nil).Granted)
+
+		deny := `O:SYD:(XD;;0x1;;;WD;(Member_of {SID(BA)}))(A;;0x1;;;WD)`
+		r.False(accessCheckSDDL(t, deny, filtered, 0x1, nil).Granted)
+	})
+
+	t.Run("Integrity level comes from the integrity group", func(t *testing.T) {
+		level, ok := filtered.IntegrityLevel()
+		r.True(ok)
+		r.Equal(winacl.IntegrityLevelMedium, level)
+
+		_, ok = elevated.IntegrityLevel()
+		r.False(ok)
+
+		// The integrity group overrides the High subject integrity in the options
+		options := winacl.DefaultAccessCheckOptions()
+		options.CheckIntegrity = true
+		options.SubjectIntegrity = winacl.IntegrityLevelHigh
+
+		sddl := "O:SYD:(A;;GA;;;WD)S:(ML;;NW;;;HI)"
+		r.False(accessCheckSDDL(t, sddl, filtered, winacl.AccessMaskWriteDACL, options).Granted)
+		r.True(accessCheckSDDL(t, sddl, elevated, winacl.AccessMaskWriteDACL, options).Granted)
+	})
+}